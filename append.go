@@ -0,0 +1,119 @@
+package rrule
+
+import (
+	"sync"
+	"time"
+)
+
+// timeCursor yields the values of a slice in order.
+type timeCursor struct {
+	s []time.Time
+	i int
+}
+
+func (c *timeCursor) next() (time.Time, bool) {
+	if c.i >= len(c.s) {
+		return time.Time{}, false
+	}
+	c.i++
+	return c.s[c.i-1], true
+}
+
+// expansion holds the iterators of one bounded expansion. Expansions are
+// pooled, together with their buffers and the generator funcs bound to them,
+// so that AppendBetween and AppendN do not allocate in steady state.
+type expansion struct {
+	set        setIterator
+	rules      []*rIterator // one per RRULE and EXRULE, grown on demand
+	ruleNexts  []Next
+	rdates     timeCursor
+	exdates    timeCursor
+	rdateNext  Next
+	exdateNext Next
+}
+
+var expansionPool = sync.Pool{
+	New: func() any {
+		e := &expansion{}
+		e.rdateNext = e.rdates.next
+		e.exdateNext = e.exdates.next
+		return e
+	},
+}
+
+// getExpansion returns a pooled expansion of set that starts near dt, like
+// iteratorFrom. It must be returned with expansionPool.Put once done.
+func (set *Recurrence) getExpansion(dt time.Time) *expansion {
+	e := expansionPool.Get().(*expansion)
+	e.set.allDay = set.allDay
+	e.set.desc = false
+	e.set.lastdt = time.Time{}
+	e.set.rlist.items = e.set.rlist.items[:0]
+	e.set.exlist.items = e.set.exlist.items[:0]
+
+	e.rdates = timeCursor{s: set.sortedRDate[searchTimes(set.sortedRDate, dt):]}
+	e.set.rlist.add(e.rdateNext)
+	if set.hasRule {
+		e.addRule(&e.set.rlist, 0, &set.compiledRule, dt, set.limits)
+		for i, r := range set.extraRules {
+			e.addRule(&e.set.rlist, i+1, r, dt, set.limits)
+		}
+	}
+	e.exdates = timeCursor{s: set.sortedExDate[searchTimes(set.sortedExDate, dt):]}
+	e.set.exlist.add(e.exdateNext)
+	for i, r := range set.exrules {
+		e.addRule(&e.set.exlist, 1+len(set.extraRules)+i, r, dt, set.limits)
+	}
+	return e
+}
+
+// addRule merges the rule into list using the i-th rule iterator of the
+// expansion.
+func (e *expansion) addRule(list *genHeap, i int, r *compiledRule, dt time.Time, limits Limits) {
+	for len(e.rules) <= i {
+		iterator := &rIterator{}
+		e.rules = append(e.rules, iterator)
+		e.ruleNexts = append(e.ruleNexts, iterator.next)
+	}
+	if e.rules[i].reset(r, dt, limits) {
+		list.add(e.ruleNexts[i])
+	}
+}
+
+// AppendBetween appends the occurrences between after and before to dst and
+// returns the extended slice. The inc keyword has the same meaning as in Between.
+// The iteration state is pooled, so expanding many recurrences into a reused
+// buffer does not allocate once the buffer is large enough.
+func (set *Recurrence) AppendBetween(dst []time.Time, after, before time.Time, inc bool) []time.Time {
+	e := set.getExpansion(after)
+	defer expansionPool.Put(e)
+	for dt, ok := e.set.next(); ok; dt, ok = e.set.next() {
+		if inc && dt.After(before) || !inc && !dt.Before(before) {
+			break
+		}
+		if inc && !dt.Before(after) || !inc && dt.After(after) {
+			dst = append(dst, dt)
+		}
+	}
+	return dst
+}
+
+// AppendN appends at most n occurrences at or after from to dst and returns
+// the extended slice. Like AppendBetween, it does not allocate in steady state.
+func (set *Recurrence) AppendN(dst []time.Time, from time.Time, n int) []time.Time {
+	if n <= 0 {
+		return dst
+	}
+	e := set.getExpansion(from)
+	defer expansionPool.Put(e)
+	for dt, ok := e.set.next(); ok; dt, ok = e.set.next() {
+		if dt.Before(from) {
+			continue
+		}
+		dst = append(dst, dt)
+		if n--; n == 0 {
+			break
+		}
+	}
+	return dst
+}
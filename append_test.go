@@ -0,0 +1,107 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func appendTestRecurrences(t testing.TB) []*Recurrence {
+	var result []*Recurrence
+	for i, opt := range []ROption{
+		{Freq: DAILY, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{Freq: HOURLY, Interval: 5, Byhour: []int{1, 13}, Dtstart: time.Date(2005, 3, 2, 1, 0, 0, 0, time.UTC)},
+		{Freq: WEEKLY, Byweekday: []Weekday{MO, WE, FR}, Dtstart: time.Date(2010, 1, 4, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Byweekday: []Weekday{TU.Nth(2), FR.Nth(-1)}, Dtstart: time.Date(2001, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: YEARLY, Byweekno: []int{1, 20}, Byweekday: []Weekday{MO}, Dtstart: time.Date(2001, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: MINUTELY, Interval: 90, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: DAILY, Interval: 2, Dtstart: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), AllDay: true},
+	} {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		if i%2 == 0 {
+			r.RDate(time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+			r.ExDate(r.After(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), true))
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func TestAppendBetween(t *testing.T) {
+	after, before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	buf := make([]time.Time, 0, 1024)
+	// Alternating recurrences exercises the reuse of pooled iterators
+	// across rules of different frequencies.
+	for round := 0; round < 2; round++ {
+		for _, r := range appendTestRecurrences(t) {
+			for _, inc := range []bool{true, false} {
+				want := between(r.occurrencesNear(after), after, before, inc)
+				buf = r.AppendBetween(buf[:0], after, before, inc)
+				if !timesEqual(buf, want) {
+					t.Errorf("%s: AppendBetween get %v, want %v", r.RRuleString(), buf, want)
+				}
+			}
+		}
+	}
+
+	r := appendTestRecurrences(t)[0]
+	prefix := []time.Time{time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)}
+	res := r.AppendBetween(prefix, after, before, true)
+	if !res[0].Equal(prefix[0]) || !timesEqual(res[1:], r.Between(after, before, true)) {
+		t.Errorf("AppendBetween should keep the contents of dst, get %v", res)
+	}
+}
+
+func TestAppendN(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range appendTestRecurrences(t) {
+		var want []time.Time
+		for v := range r.OccurrencesFrom(from) {
+			if len(want) == 25 {
+				break
+			}
+			want = append(want, v)
+		}
+		if res := r.AppendN(nil, from, 25); !timesEqual(res, want) {
+			t.Errorf("%s: AppendN get %v, want %v", r.RRuleString(), res, want)
+		}
+		if res := r.AppendN(nil, from, 0); len(res) != 0 {
+			t.Errorf("%s: AppendN(0) get %v, want nothing", r.RRuleString(), res)
+		}
+	}
+
+	r, _ := newRecurrence(ROption{Freq: DAILY, Count: 3, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	if res := r.AppendN(nil, time.Time{}, 10); len(res) != 3 {
+		t.Errorf("AppendN get %v, want the 3 occurrences", res)
+	}
+}
+
+func TestAppendBetweenAllocations(t *testing.T) {
+	recurrences := appendTestRecurrences(t)
+	after, before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)
+	buf := make([]time.Time, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, r := range recurrences {
+			buf = r.AppendBetween(buf[:0], after, before, true)
+			buf = r.AppendN(buf[:0], after, 10)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("get %v allocations per run, want 0", allocs)
+	}
+}
+
+func BenchmarkAppendBetween(b *testing.B) {
+	recurrences := appendTestRecurrences(b)
+	after, before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)
+	buf := make([]time.Time, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range recurrences {
+			buf = r.AppendBetween(buf[:0], after, before, true)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package rrule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calendar is a non-Gregorian calendar selected by the RFC 7529 RSCALE rule
+// part. Rules with a calendar evaluate BYMONTH, BYMONTHDAY, BYYEARDAY and the
+// nth weekdays of BYDAY, and step YEARLY and MONTHLY periods, in calendar
+// dates, while their occurrences stay Gregorian time.Time values.
+type Calendar interface {
+	// Name returns the RSCALE value of the calendar, such as "HEBREW".
+	Name() string
+	// Months returns the months of year in order.
+	Months(year int) []CalendarMonth
+	// DaysInMonth returns the length of month in year, or 0 if year has no
+	// such month.
+	DaysInMonth(year int, month CalendarMonth) int
+	// DaysInYear returns the length of year.
+	DaysInYear(year int) int
+	// FromCivil returns the calendar date of a proleptic Gregorian date, or
+	// the zero CalendarDate if the calendar does not cover it.
+	FromCivil(year int, month time.Month, day int) CalendarDate
+	// ToCivil returns the proleptic Gregorian date of a valid calendar date.
+	ToCivil(date CalendarDate) (year int, month time.Month, day int)
+}
+
+// CalendarMonth is a month of a Calendar. Leap months are numbered after the
+// month they follow, like BYMONTH=5L.
+type CalendarMonth struct {
+	Number int
+	Leap   bool
+}
+
+func (m CalendarMonth) String() string {
+	if m.Leap {
+		return fmt.Sprintf("%dL", m.Number)
+	}
+	return fmt.Sprint(m.Number)
+}
+
+// CalendarDate is a date of a Calendar.
+type CalendarDate struct {
+	Year  int
+	Month CalendarMonth
+	Day   int
+}
+
+var (
+	calendarsMu sync.RWMutex
+	calendars   = map[string]Calendar{
+		HebrewCalendar.Name():       HebrewCalendar,
+		IslamicCivilCalendar.Name(): IslamicCivilCalendar,
+		ChineseCalendar.Name():      ChineseCalendar,
+	}
+)
+
+// RegisterCalendar makes cal available to rules with RSCALE set to its name.
+// It replaces any calendar registered with the same name.
+func RegisterCalendar(cal Calendar) {
+	calendarsMu.Lock()
+	defer calendarsMu.Unlock()
+	calendars[strings.ToUpper(cal.Name())] = cal
+}
+
+// calendarFor returns the calendar of an RSCALE value, or nil for the
+// Gregorian calendar.
+func calendarFor(rscale string) (Calendar, error) {
+	rscale = strings.ToUpper(rscale)
+	if rscale == "" || rscale == "GREGORIAN" {
+		return nil, nil
+	}
+	calendarsMu.RLock()
+	defer calendarsMu.RUnlock()
+	cal, ok := calendars[rscale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported RSCALE %q", rscale)
+	}
+	return cal, nil
+}
+
+// calendarLastDay returns the civilDays of the last day covered by the
+// calendar of the rule, for calendars that end, such as table-driven ones.
+func (r *compiledRule) calendarLastDay() (int, bool) {
+	if cal, ok := r.calendar.(interface{ lastDay() int }); ok {
+		return cal.lastDay(), true
+	}
+	return 0, false
+}
+
+// rataDie is the number of days from 0001-01-01, day 1, to 1970-01-01.
+const rataDie = 719163
+
+// calendarDays returns the civilDays of a calendar date.
+func calendarDays(cal Calendar, date CalendarDate) int {
+	year, month, day := cal.ToCivil(date)
+	return civilDays(year, month, day)
+}
+
+// calendarDate returns the calendar date of a civilDays value.
+func calendarDate(cal Calendar, days int) CalendarDate {
+	year, month, day := civilDate(days)
+	return cal.FromCivil(year, month, day)
+}
+
+// monthIndex returns the position of month in the months of year, or -1.
+func monthIndex(cal Calendar, year int, month CalendarMonth) int {
+	for i, m := range cal.Months(year) {
+		if m == month {
+			return i
+		}
+	}
+	return -1
+}
+
+// HebrewCalendar is the arithmetic Hebrew calendar, RSCALE=HEBREW. Years
+// start with Tishri, month 1; Adar I of leap years is month 5L and Adar, or
+// Adar II, is month 6.
+var HebrewCalendar Calendar = hebrewCalendar{}
+
+type hebrewCalendar struct{}
+
+// hebrewEpoch is the rata die of 1 Tishri AM 1.
+const hebrewEpoch = -1373427
+
+func (hebrewCalendar) Name() string { return "HEBREW" }
+
+func (hebrewCalendar) isLeap(year int) bool {
+	return pymod(7*year+1, 19) < 7
+}
+
+// elapsedDays returns the days from the epoch to the molad of Tishri of year,
+// delayed when it falls on a Sunday, Wednesday or Friday.
+func (hebrewCalendar) elapsedDays(year int) int {
+	months, _ := divmod(235*year-234, 19)
+	parts := 12084 + 13753*months
+	div, _ := divmod(parts, 25920)
+	days := 29*months + div
+	if pymod(3*(days+1), 7) < 3 {
+		days++
+	}
+	return days
+}
+
+// newYear returns the rata die of 1 Tishri of year.
+func (c hebrewCalendar) newYear(year int) int {
+	ny0, ny1, ny2 := c.elapsedDays(year-1), c.elapsedDays(year), c.elapsedDays(year+1)
+	delay := 0
+	if ny2-ny1 == 356 {
+		delay = 2
+	} else if ny1-ny0 == 382 {
+		delay = 1
+	}
+	return hebrewEpoch + ny1 + delay
+}
+
+func (c hebrewCalendar) DaysInYear(year int) int {
+	return c.newYear(year+1) - c.newYear(year)
+}
+
+func (c hebrewCalendar) Months(year int) []CalendarMonth {
+	months := make([]CalendarMonth, 0, 13)
+	for n := 1; n <= 12; n++ {
+		months = append(months, CalendarMonth{Number: n})
+		if n == 5 && c.isLeap(year) {
+			months = append(months, CalendarMonth{Number: 5, Leap: true})
+		}
+	}
+	return months
+}
+
+func (c hebrewCalendar) DaysInMonth(year int, month CalendarMonth) int {
+	if month.Leap {
+		if month.Number == 5 && c.isLeap(year) {
+			return 30
+		}
+		return 0
+	}
+	switch month.Number {
+	case 2: // Heshvan is long in complete years.
+		if c.DaysInYear(year)%10 == 5 {
+			return 30
+		}
+		return 29
+	case 3: // Kislev is short in deficient years.
+		if c.DaysInYear(year)%10 == 3 {
+			return 29
+		}
+		return 30
+	case 1, 5, 7, 9, 11:
+		return 30
+	case 4, 6, 8, 10, 12:
+		return 29
+	}
+	return 0
+}
+
+func (c hebrewCalendar) FromCivil(year int, month time.Month, day int) CalendarDate {
+	rd := civilDays(year, month, day) + rataDie
+	y := year + 3761
+	if c.newYear(y) > rd {
+		y--
+	}
+	return monthDayOf(c, y, rd-c.newYear(y))
+}
+
+func (c hebrewCalendar) ToCivil(date CalendarDate) (int, time.Month, int) {
+	return civilDate(c.newYear(date.Year) + dayOfYear(c, date) - 1 - rataDie)
+}
+
+// IslamicCivilCalendar is the arithmetic Islamic calendar, RSCALE=ISLAMIC-CIVIL,
+// counted from the civil epoch of July 16, 622 (Julian) with 11 leap years in
+// each cycle of 30 years.
+var IslamicCivilCalendar Calendar = islamicCivilCalendar{}
+
+type islamicCivilCalendar struct{}
+
+// islamicEpoch is the rata die of 1 Muharram AH 1.
+const islamicEpoch = 227015
+
+func (islamicCivilCalendar) Name() string { return "ISLAMIC-CIVIL" }
+
+func (islamicCivilCalendar) isLeap(year int) bool {
+	return pymod(14+11*year, 30) < 11
+}
+
+// newYear returns the rata die of 1 Muharram of year.
+func (islamicCivilCalendar) newYear(year int) int {
+	div, _ := divmod(3+11*year, 30)
+	return islamicEpoch + (year-1)*354 + div
+}
+
+func (c islamicCivilCalendar) DaysInYear(year int) int {
+	if c.isLeap(year) {
+		return 355
+	}
+	return 354
+}
+
+func (islamicCivilCalendar) Months(int) []CalendarMonth {
+	months := make([]CalendarMonth, 12)
+	for i := range months {
+		months[i].Number = i + 1
+	}
+	return months
+}
+
+func (c islamicCivilCalendar) DaysInMonth(year int, month CalendarMonth) int {
+	switch {
+	case month.Leap || month.Number < 1 || month.Number > 12:
+		return 0
+	case month.Number == 12 && c.isLeap(year):
+		return 30
+	case month.Number%2 == 1:
+		return 30
+	}
+	return 29
+}
+
+func (c islamicCivilCalendar) FromCivil(year int, month time.Month, day int) CalendarDate {
+	rd := civilDays(year, month, day) + rataDie
+	y, _ := divmod(30*(rd-islamicEpoch)+10646, 10631)
+	return monthDayOf(c, y, rd-c.newYear(y))
+}
+
+func (c islamicCivilCalendar) ToCivil(date CalendarDate) (int, time.Month, int) {
+	return civilDate(c.newYear(date.Year) + dayOfYear(c, date) - 1 - rataDie)
+}
+
+// monthDayOf returns the date lying offset days after the first day of year.
+func monthDayOf(cal Calendar, year, offset int) CalendarDate {
+	for _, month := range cal.Months(year) {
+		n := cal.DaysInMonth(year, month)
+		if offset < n {
+			return CalendarDate{Year: year, Month: month, Day: offset + 1}
+		}
+		offset -= n
+	}
+	// offset is past the end of year; callers only pass valid offsets.
+	return CalendarDate{Year: year + 1, Month: cal.Months(year + 1)[0], Day: offset + 1}
+}
+
+// dayOfYear returns the day of year of date, from 1.
+func dayOfYear(cal Calendar, date CalendarDate) int {
+	days := date.Day
+	for _, month := range cal.Months(date.Year) {
+		if month == date.Month {
+			break
+		}
+		days += cal.DaysInMonth(date.Year, month)
+	}
+	return days
+}
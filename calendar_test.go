@@ -0,0 +1,220 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarConversion(t *testing.T) {
+	tests := []struct {
+		cal   Calendar
+		civil time.Time
+		date  CalendarDate
+	}{
+		{HebrewCalendar, time.Date(2024, 10, 3, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 5785, Month: CalendarMonth{Number: 1}, Day: 1}},
+		{HebrewCalendar, time.Date(2024, 4, 23, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 5784, Month: CalendarMonth{Number: 7}, Day: 15}},
+		{HebrewCalendar, time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 5784, Month: CalendarMonth{Number: 5, Leap: true}, Day: 8}},
+		{HebrewCalendar, time.Date(2024, 3, 24, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 5784, Month: CalendarMonth{Number: 6}, Day: 14}},
+		{IslamicCivilCalendar, time.Date(2023, 7, 19, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 1445, Month: CalendarMonth{Number: 1}, Day: 1}},
+		{IslamicCivilCalendar, time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC), CalendarDate{Year: 1445, Month: CalendarMonth{Number: 10}, Day: 1}},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.date, tc.cal.FromCivil(tc.civil.Date()), "%s FromCivil(%v)", tc.cal.Name(), tc.civil)
+		year, month, day := tc.cal.ToCivil(tc.date)
+		assert.Equal(t, tc.civil, time.Date(year, month, day, 0, 0, 0, 0, time.UTC), "%s ToCivil(%+v)", tc.cal.Name(), tc.date)
+	}
+
+	for _, cal := range []Calendar{HebrewCalendar, IslamicCivilCalendar} {
+		for days := civilDays(1900, 1, 1); days < civilDays(2100, 1, 1); days++ {
+			date := calendarDate(cal, days)
+			if date.Day < 1 || date.Day > cal.DaysInMonth(date.Year, date.Month) || calendarDays(cal, date) != days {
+				t.Fatalf("%s: day %d converts to %+v", cal.Name(), days, date)
+			}
+		}
+	}
+}
+
+func TestCalendarMonths(t *testing.T) {
+	assert.Len(t, HebrewCalendar.Months(5784), 13)
+	assert.Len(t, HebrewCalendar.Months(5785), 12)
+	assert.Equal(t, 30, HebrewCalendar.DaysInMonth(5784, CalendarMonth{Number: 5, Leap: true}))
+	assert.Equal(t, 0, HebrewCalendar.DaysInMonth(5785, CalendarMonth{Number: 5, Leap: true}))
+	assert.Equal(t, 383, HebrewCalendar.DaysInYear(5784))
+	assert.Equal(t, 355, IslamicCivilCalendar.DaysInYear(1445))
+	assert.Equal(t, 30, IslamicCivilCalendar.DaysInMonth(1445, CalendarMonth{Number: 12}))
+	assert.Equal(t, 29, IslamicCivilCalendar.DaysInMonth(1446, CalendarMonth{Number: 12}))
+}
+
+func TestRscale(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		rule     string
+		expected []time.Time
+	}{
+		{
+			// RFC 7529, section 4.3.
+			rule:     "RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5L;BYMONTHDAY=8;SKIP=FORWARD;COUNT=5",
+			expected: []time.Time{day(2014, 2, 8), day(2015, 2, 27), day(2016, 2, 17), day(2017, 3, 6), day(2018, 2, 23)},
+		},
+		{
+			rule:     "RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5L;BYMONTHDAY=8;SKIP=BACKWARD;COUNT=3",
+			expected: []time.Time{day(2014, 2, 8), day(2015, 1, 28), day(2016, 2, 17)},
+		},
+		{
+			// The implicit month and day of DTSTART are in the calendar.
+			rule:     "RRULE:RSCALE=HEBREW;FREQ=YEARLY;COUNT=3",
+			expected: []time.Time{day(2014, 2, 8), day(2016, 2, 17), day(2019, 2, 13)},
+		},
+		{
+			// The last day of Elul moves to Rosh Hashanah.
+			rule:     "RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=30;SKIP=FORWARD;COUNT=2",
+			expected: []time.Time{day(2014, 9, 25), day(2015, 9, 14)},
+		},
+		{
+			rule:     "RRULE:RSCALE=ISLAMIC-CIVIL;FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			expected: []time.Time{day(2014, 3, 2), day(2014, 4, 1), day(2014, 4, 30)},
+		},
+		{
+			rule:     "RRULE:RSCALE=ISLAMIC-CIVIL;FREQ=YEARLY;BYMONTH=9;BYMONTHDAY=1;COUNT=3",
+			expected: []time.Time{day(2014, 6, 29), day(2015, 6, 18), day(2016, 6, 7)},
+		},
+	}
+	for _, tc := range tests {
+		r, err := Parse("DTSTART;VALUE=DATE:20140208", tc.rule)
+		require.NoError(t, err, tc.rule)
+		assert.Equal(t, tc.expected, r.All(), tc.rule)
+	}
+
+	_, err := Parse("DTSTART;VALUE=DATE:20140208", "RRULE:RSCALE=JULIAN;FREQ=YEARLY")
+	assert.ErrorContains(t, err, `unsupported RSCALE "JULIAN"`)
+	_, err = Parse("DTSTART;VALUE=DATE:20140208", "RRULE:FREQ=YEARLY;BYMONTH=5L")
+	assert.Error(t, err)
+	_, err = Parse("DTSTART;VALUE=DATE:20140208", "RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYWEEKNO=1")
+	assert.Error(t, err)
+}
+
+func TestRscaleSeek(t *testing.T) {
+	r, err := Parse("DTSTART;TZID=America/New_York:20200105T093000",
+		"RRULE:RSCALE=HEBREW;FREQ=MONTHLY;INTERVAL=5;BYMONTHDAY=30;SKIP=BACKWARD")
+	require.NoError(t, err)
+	from, end := time.Date(2031, 3, 3, 0, 0, 0, 0, time.UTC), time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	var want []time.Time
+	for v := range r.Occurrences() {
+		if v.After(end) {
+			break
+		}
+		if !v.Before(from) {
+			want = append(want, v)
+		}
+	}
+	assert.Equal(t, want, r.Between(from, end, true))
+	prev, ok := r.Prev(from)
+	require.True(t, ok)
+	assert.Equal(t, r.Before(want[0], false), prev)
+	assert.True(t, r.Contains(want[0]))
+	assert.False(t, r.Contains(want[0].AddDate(0, 0, 1)))
+}
+
+func TestChineseCalendar(t *testing.T) {
+	// Chinese New Year, as published in the almanac.
+	newYears := []time.Time{
+		time.Date(2000, 2, 5, 0, 0, 0, 0, time.UTC), time.Date(2001, 1, 24, 0, 0, 0, 0, time.UTC),
+		time.Date(2002, 2, 12, 0, 0, 0, 0, time.UTC), time.Date(2003, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2004, 1, 22, 0, 0, 0, 0, time.UTC), time.Date(2005, 2, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2006, 1, 29, 0, 0, 0, 0, time.UTC), time.Date(2007, 2, 18, 0, 0, 0, 0, time.UTC),
+		time.Date(2008, 2, 7, 0, 0, 0, 0, time.UTC), time.Date(2009, 1, 26, 0, 0, 0, 0, time.UTC),
+		time.Date(2010, 2, 14, 0, 0, 0, 0, time.UTC), time.Date(2011, 2, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2012, 1, 23, 0, 0, 0, 0, time.UTC), time.Date(2013, 2, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2014, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2015, 2, 19, 0, 0, 0, 0, time.UTC),
+		time.Date(2016, 2, 8, 0, 0, 0, 0, time.UTC), time.Date(2017, 1, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2018, 2, 16, 0, 0, 0, 0, time.UTC), time.Date(2019, 2, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 1, 25, 0, 0, 0, 0, time.UTC), time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 22, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 2, 17, 0, 0, 0, 0, time.UTC),
+	}
+	r, err := Parse("DTSTART;VALUE=DATE:20000205", "RRULE:RSCALE=CHINESE;FREQ=YEARLY")
+	require.NoError(t, err)
+	assert.Equal(t, newYears, r.Between(newYears[0], newYears[len(newYears)-1], true))
+
+	first, last := civilDays(1900, 1, 31), ChineseCalendar.(chineseCalendar).lastDay()
+	for days := first; days <= last; days++ {
+		date := calendarDate(ChineseCalendar, days)
+		if date.Day < 1 || date.Day > ChineseCalendar.DaysInMonth(date.Year, date.Month) || calendarDays(ChineseCalendar, date) != days {
+			t.Fatalf("day %d converts to %+v", days, date)
+		}
+	}
+	assert.Equal(t, CalendarDate{}, calendarDate(ChineseCalendar, first-1))
+	assert.Equal(t, CalendarDate{}, calendarDate(ChineseCalendar, last+1))
+	assert.Equal(t, CalendarDate{Year: 2020, Month: CalendarMonth{Number: 4, Leap: true}, Day: 1}, ChineseCalendar.FromCivil(2020, 5, 23))
+	for _, year := range []int{1899, 2101, 2200} {
+		y, m, d := ChineseCalendar.ToCivil(CalendarDate{Year: year, Month: CalendarMonth{Number: 1}, Day: 1})
+		assert.Equal(t, [3]int{0, 0, 0}, [3]int{y, int(m), d}, year)
+	}
+}
+
+func TestRscaleChinese(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		dtstart  string
+		rule     string
+		expected []time.Time
+	}{
+		{
+			// RFC 7529, section 4.3.
+			dtstart:  "DTSTART;VALUE=DATE:20130210",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=YEARLY;COUNT=4",
+			expected: []time.Time{day(2013, 2, 10), day(2014, 1, 31), day(2015, 2, 19), day(2016, 2, 8)},
+		},
+		{
+			// Mid-Autumn Festival.
+			dtstart:  "DTSTART;VALUE=DATE:20230101",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=YEARLY;COUNT=3;BYMONTH=8;BYMONTHDAY=15",
+			expected: []time.Time{day(2023, 9, 29), day(2024, 9, 17), day(2025, 10, 6)},
+		},
+		{
+			// Born on the first day of the leap fourth month of 2020.
+			dtstart:  "DTSTART;VALUE=DATE:20200523",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=YEARLY;COUNT=3;BYMONTH=4L;BYMONTHDAY=1;SKIP=BACKWARD",
+			expected: []time.Time{day(2020, 5, 23), day(2021, 5, 12), day(2022, 5, 1)},
+		},
+		{
+			dtstart:  "DTSTART;VALUE=DATE:20200523",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=YEARLY;COUNT=3;BYMONTH=4L;BYMONTHDAY=1;SKIP=FORWARD",
+			expected: []time.Time{day(2020, 5, 23), day(2021, 6, 10), day(2022, 5, 30)},
+		},
+		{
+			// Small months have no day 30.
+			dtstart:  "DTSTART;VALUE=DATE:20240210",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=MONTHLY;COUNT=3;BYMONTHDAY=30",
+			expected: []time.Time{day(2024, 4, 8), day(2024, 7, 5), day(2024, 9, 2)},
+		},
+		{
+			dtstart:  "DTSTART;VALUE=DATE:20240210",
+			rule:     "RRULE:RSCALE=CHINESE;FREQ=MONTHLY;COUNT=3;BYMONTHDAY=30;SKIP=BACKWARD",
+			expected: []time.Time{day(2024, 3, 9), day(2024, 4, 8), day(2024, 5, 7)},
+		},
+	}
+	for _, tc := range tests {
+		r, err := Parse(tc.dtstart, tc.rule)
+		require.NoError(t, err, tc.rule)
+		assert.Equal(t, tc.expected, r.All(), tc.rule)
+		assert.Equal(t, tc.dtstart+"\n"+tc.rule, r.String())
+	}
+
+	r, err := New(ROption{Freq: YEARLY, Rscale: "CHINESE", Bymonth: []int{8}, Bymonthday: []int{15}, Dtstart: day(2095, 1, 1)})
+	require.NoError(t, err)
+	// The occurrences end with the table.
+	assert.Equal(t, []time.Time{day(2095, 9, 13), day(2096, 9, 30), day(2097, 9, 20), day(2098, 9, 9), day(2099, 9, 29), day(2100, 9, 18)}, r.All())
+	assert.Equal(t, day(2100, 9, 18), r.Before(day(2200, 1, 1), false))
+
+	_, err = New(ROption{Freq: YEARLY, Rscale: "CHINESE", Dtstart: day(1890, 1, 1)})
+	assert.Error(t, err)
+}
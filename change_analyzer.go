@@ -44,7 +44,10 @@ func NewRecurrenceDiffer() *RecurrenceDiffer {
 	return &RecurrenceDiffer{}
 }
 
-// AnalyzeChanges analyzes changes between two rule sets.
+// AnalyzeChanges analyzes changes between two rule sets. The rule sets are
+// recurrence lines, which hold no RECURRENCE-ID overrides: changes made with
+// SetOverride are not compared, and callers keeping overrides compare the
+// GetOverrides of the two recurrences themselves.
 func (a *RecurrenceDiffer) AnalyzeChanges(oldRuleSet, newRuleSet []string) (*RecurrenceChangeAnalysis, error) {
 	// If rules are identical, there are no changes.
 	if !HasRRuleChanges(oldRuleSet, newRuleSet) {
@@ -123,7 +126,8 @@ func (a *RecurrenceDiffer) parseRulesForAnalysis(ruleset []string) (*Recurrence,
 	return set, nil
 }
 
-// requiresFullRebuild checks whether a full rebuild is required.
+// requiresFullRebuild checks whether a full rebuild is required. Overrides
+// are ignored, as AnalyzeChanges documents.
 func (a *RecurrenceDiffer) requiresFullRebuild(oldSet, newSet *Recurrence) bool {
 	if oldSet == nil || newSet == nil || !oldSet.hasRule || !newSet.hasRule {
 		return true
@@ -207,6 +211,28 @@ func (a *RecurrenceDiffer) requiresFullRebuild(oldSet, newSet *Recurrence) bool
 		return true
 	}
 
+	if oldSet.skip != newSet.skip {
+		return true
+	}
+
+	if oldSet.rscale != newSet.rscale || !slices.Equal(oldSet.bymonthleap, newSet.bymonthleap) {
+		return true
+	}
+
+	// Check additional RRULE and EXRULE changes; any of them changes the pattern.
+	sameRule := func(a, b *compiledRule) bool {
+		return a.rrulePropertiesString() == b.rrulePropertiesString()
+	}
+	if !slices.EqualFunc(oldSet.extraRules, newSet.extraRules, sameRule) ||
+		!slices.EqualFunc(oldSet.exrules, newSet.exrules, sameRule) {
+		return true
+	}
+
+	// Check event duration changes; every instance changes length.
+	if oldSet.GetDuration() != newSet.GetDuration() {
+		return true
+	}
+
 	// Check RDATE changes; they require a full rebuild.
 	if a.hasRDateChange(oldSet, newSet) {
 		return true
@@ -379,7 +405,9 @@ func (a *RecurrenceDiffer) hasRDateChange(oldSet, newSet *Recurrence) bool {
 		}
 	}
 
-	return false
+	return !slices.EqualFunc(oldSet.GetRDatePeriods(), newSet.GetRDatePeriods(), func(a, b Period) bool {
+		return a.Start.Equal(b.Start) && a.End.Equal(b.End)
+	})
 }
 
 func ruleUntilValue(set *Recurrence) *time.Time {
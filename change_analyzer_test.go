@@ -49,6 +49,33 @@ func TestRRuleChangeAnalyzer_AnalyzeChanges(t *testing.T) {
 	})
 
 	t.Run("FullRebuild", func(t *testing.T) {
+		t.Run("additional rule change", func(t *testing.T) {
+			oldRules := []string{"RRULE:FREQ=WEEKLY;BYDAY=MO", "RRULE:FREQ=WEEKLY;BYDAY=TH"}
+			newRules := []string{"RRULE:FREQ=WEEKLY;BYDAY=MO", "RRULE:FREQ=WEEKLY;BYDAY=FR"}
+
+			analysis, err := analyzer.AnalyzeChanges(oldRules, newRules)
+			require.NoError(t, err)
+			assert.EqualValues(t, FullRebuild, analysis.ChangeType)
+		})
+
+		t.Run("skip change", func(t *testing.T) {
+			oldRules := []string{"RRULE:FREQ=MONTHLY;BYMONTHDAY=31"}
+			newRules := []string{"RRULE:FREQ=MONTHLY;BYMONTHDAY=31;SKIP=BACKWARD"}
+
+			analysis, err := analyzer.AnalyzeChanges(oldRules, newRules)
+			require.NoError(t, err)
+			assert.EqualValues(t, FullRebuild, analysis.ChangeType)
+		})
+
+		t.Run("rscale change", func(t *testing.T) {
+			oldRules := []string{"RRULE:FREQ=YEARLY;BYMONTH=6;BYMONTHDAY=8"}
+			newRules := []string{"RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=6;BYMONTHDAY=8"}
+
+			analysis, err := analyzer.AnalyzeChanges(oldRules, newRules)
+			require.NoError(t, err)
+			assert.EqualValues(t, FullRebuild, analysis.ChangeType)
+		})
+
 		t.Run("frequency change", func(t *testing.T) {
 			oldRules := []string{"RRULE:FREQ=DAILY;COUNT=5"}
 			newRules := []string{"RRULE:FREQ=WEEKLY;COUNT=5"}
@@ -0,0 +1,275 @@
+package rrule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ContentLine is an RFC 5545 content line, NAME;PARAM=VALUE:VALUE, after
+// unfolding. Property and parameter names are upper-cased; parameter values
+// are unquoted and unescaped, and the value is kept as written.
+type ContentLine struct {
+	Name   string
+	Params []Param
+	Value  string
+	Line   int // physical line the content line starts on, from 1
+
+	column    int // column of the name
+	valueLine int // physical line and column of the value
+	valueCol  int
+}
+
+// Param is a property parameter with its values, such as MEMBER="a","b".
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// SyntaxError reports a malformed content line and where it was found.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Param returns the first value of the parameter name, ignoring case, and
+// whether the line has it.
+func (l ContentLine) Param(name string) (string, bool) {
+	for _, p := range l.Params {
+		if strings.EqualFold(p.Name, name) && len(p.Values) > 0 {
+			return p.Values[0], true
+		}
+	}
+	return "", false
+}
+
+// Text returns the value unescaped as an RFC 5545 TEXT value.
+func (l ContentLine) Text() string {
+	if !strings.Contains(l.Value, `\`) {
+		return l.Value
+	}
+	var b strings.Builder
+	for i := 0; i < len(l.Value); i++ {
+		c := l.Value[i]
+		if c == '\\' && i+1 < len(l.Value) {
+			i++
+			switch c = l.Value[i]; c {
+			case 'n', 'N':
+				c = '\n'
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// String returns the line unfolded, quoting the parameter values that need it.
+func (l ContentLine) String() string {
+	var b strings.Builder
+	b.WriteString(l.Name)
+	for _, p := range l.Params {
+		b.WriteString(";" + p.Name + "=")
+		for i, v := range p.Values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			v = strings.NewReplacer("^", "^^", "\n", "^n", `"`, "^'").Replace(v)
+			if strings.ContainsAny(v, ";:,") {
+				v = `"` + v + `"`
+			}
+			b.WriteString(v)
+		}
+	}
+	b.WriteString(":" + l.Value)
+	return b.String()
+}
+
+// Time parses the DATE or DATE-TIME value of l, such as a DTSTART or a
+// RECURRENCE-ID, in the location of its TZID parameter or in defaultLoc.
+// DATE values are returned at midnight UTC. A nil resolver stands for
+// DefaultLocationResolver.
+func (l ContentLine) Time(defaultLoc *time.Location, resolver LocationResolver) (time.Time, error) {
+	return parseDtStart(l, defaultLoc, resolver)
+}
+
+// Times parses the comma-separated DATE or DATE-TIME values of l, such as an
+// RDATE, like Time.
+func (l ContentLine) Times(defaultLoc *time.Location, resolver LocationResolver) ([]time.Time, error) {
+	return parseDates(l, defaultLoc, resolver)
+}
+
+// errorf returns a SyntaxError located at the value of l.
+func (l ContentLine) errorf(format string, args ...any) error {
+	return &SyntaxError{Line: l.valueLine, Column: l.valueCol, Err: fmt.Errorf(format, args...)}
+}
+
+// dateKind reports whether the value of l is a DATE, and whether a DATE-TIME
+// value is pinned to a time zone by TZID or as UTC.
+func (l ContentLine) dateKind() (isDate, hasTZID, isUTC bool) {
+	if value, _ := l.Param("VALUE"); strings.EqualFold(value, "DATE") {
+		return true, false, false
+	}
+	_, hasTZID = l.Param("TZID")
+	return false, hasTZID, strings.HasSuffix(strings.ToUpper(l.Value), "Z")
+}
+
+// ParseContentLine parses a single content line, which may be folded.
+func ParseContentLine(s string) (ContentLine, error) {
+	lines := unfold(s, 1)
+	if len(lines) != 1 {
+		return ContentLine{}, &SyntaxError{Line: 1, Column: 1, Err: fmt.Errorf("want one content line, found %d", len(lines))}
+	}
+	return lexContentLine(lines[0])
+}
+
+// ParseContentLines unfolds text and parses its content lines. Lines may end
+// with CRLF or LF; blank lines are skipped.
+func ParseContentLines(text string) ([]ContentLine, error) {
+	var result []ContentLine
+	for _, u := range unfold(text, 1) {
+		l, err := lexContentLine(u)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, l)
+	}
+	return result, nil
+}
+
+// unfoldedLine is a content line joined from its physical lines.
+type unfoldedLine struct {
+	text   string
+	line   int   // physical line of the first fold
+	indent int   // white space trimmed from the first fold
+	folds  []int // offsets in text where the following folds start
+}
+
+// pos returns the physical line and column of offset i of text.
+func (u unfoldedLine) pos(i int) (line, column int) {
+	line, start, skipped := u.line, 0, u.indent
+	for k, fold := range u.folds {
+		if i < fold {
+			break
+		}
+		// A fold drops the white space character that starts it.
+		line, start, skipped = u.line+k+1, fold, 1
+	}
+	return line, i - start + skipped + 1
+}
+
+// unfold splits text into content lines, joining the lines that start with a
+// space or a tab to the line before them. first is the number of the first
+// physical line.
+func unfold(text string, first int) []unfoldedLine {
+	var lines []unfoldedLine
+	open := false
+	for i, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		switch {
+		case open && raw != "" && (raw[0] == ' ' || raw[0] == '\t'):
+			u := &lines[len(lines)-1]
+			u.folds = append(u.folds, len(u.text))
+			u.text += raw[1:]
+		case strings.TrimSpace(raw) == "":
+			open = false
+		default:
+			text := strings.TrimLeft(raw, " \t")
+			lines = append(lines, unfoldedLine{text: text, line: first + i, indent: len(raw) - len(text)})
+			open = true
+		}
+	}
+	for i := range lines {
+		lines[i].text = strings.TrimRight(lines[i].text, " \t")
+	}
+	return lines
+}
+
+// lexContentLine parses an unfolded content line:
+//
+//	contentline = name *(";" param ) ":" value
+//	param       = param-name "=" param-value *("," param-value)
+//	param-value = paramtext / quoted-string
+func lexContentLine(u unfoldedLine) (ContentLine, error) {
+	s := u.text
+	fail := func(i int, format string, args ...any) (ContentLine, error) {
+		line, column := u.pos(i)
+		return ContentLine{}, &SyntaxError{Line: line, Column: column, Err: fmt.Errorf(format, args...)}
+	}
+
+	i := scanName(s, 0)
+	if i == 0 {
+		return fail(0, "missing property name")
+	}
+	_, column := u.pos(0)
+	l := ContentLine{Name: strings.ToUpper(s[:i]), Line: u.line, column: column}
+	for i < len(s) && s[i] == ';' {
+		start := i + 1
+		if i = scanName(s, start); i == start {
+			return fail(start, "missing parameter name")
+		}
+		p := Param{Name: strings.ToUpper(s[start:i])}
+		if i == len(s) || s[i] != '=' {
+			return fail(i, "missing '=' after parameter %s", p.Name)
+		}
+		for {
+			i++ // skip '=' or ','
+			var value string
+			if i < len(s) && s[i] == '"' {
+				end := strings.IndexByte(s[i+1:], '"')
+				if end < 0 {
+					return fail(i, "unterminated quoted value of parameter %s", p.Name)
+				}
+				value, i = s[i+1:i+1+end], i+end+2
+			} else {
+				end := strings.IndexAny(s[i:], `;:,"`)
+				if end < 0 {
+					end = len(s) - i
+				}
+				value, i = s[i:i+end], i+end
+				if i < len(s) && s[i] == '"' {
+					return fail(i, "unexpected '\"' in value of parameter %s", p.Name)
+				}
+			}
+			p.Values = append(p.Values, unescapeParam(value))
+			if i == len(s) || s[i] != ',' {
+				break
+			}
+		}
+		l.Params = append(l.Params, p)
+	}
+	if i == len(s) {
+		return fail(i, "missing ':' before the value of %s", l.Name)
+	}
+	if s[i] != ':' {
+		return fail(i, "unexpected %q after %s", s[i], l.Name)
+	}
+	l.Value = s[i+1:]
+	l.valueLine, l.valueCol = u.pos(i + 1)
+	return l, nil
+}
+
+// scanName returns the end of the name, letters, digits and dashes, at start.
+func scanName(s string, start int) int {
+	i := start
+	for i < len(s) && (s[i] >= 'A' && s[i] <= 'Z' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= '0' && s[i] <= '9' || s[i] == '-') {
+		i++
+	}
+	return i
+}
+
+// unescapeParam decodes the RFC 6868 escapes of a parameter value.
+func unescapeParam(value string) string {
+	if !strings.Contains(value, "^") {
+		return value
+	}
+	return strings.NewReplacer("^^", "^", "^n", "\n", "^N", "\n", "^'", `"`).Replace(value)
+}
@@ -0,0 +1,127 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContentLine(t *testing.T) {
+	l, err := ParseContentLine(`rdate;Value=DATE-TIME;x-members="a:b;c",d;tzid="America/New_York":20240105T090000`)
+	require.NoError(t, err)
+	assert.Equal(t, "RDATE", l.Name)
+	assert.Equal(t, []Param{
+		{Name: "VALUE", Values: []string{"DATE-TIME"}},
+		{Name: "X-MEMBERS", Values: []string{"a:b;c", "d"}},
+		{Name: "TZID", Values: []string{"America/New_York"}},
+	}, l.Params)
+	tzid, ok := l.Param("tzid")
+	assert.True(t, ok)
+	assert.Equal(t, "America/New_York", tzid)
+	assert.Equal(t, "20240105T090000", l.Value)
+	assert.Equal(t, `RDATE;VALUE=DATE-TIME;X-MEMBERS="a:b;c",d;TZID=America/New_York:20240105T090000`, l.String())
+
+	l, err = ParseContentLine("X-NOTE;X-TITLE=^'Big^' ^^ day^n:Lunch\\, then a walk\\;\\nbring\\\\shoes")
+	require.NoError(t, err)
+	title, _ := l.Param("X-TITLE")
+	assert.Equal(t, "\"Big\" ^ day\n", title)
+	assert.Equal(t, "Lunch, then a walk;\nbring\\shoes", l.Text())
+	again, err := ParseContentLine(l.String())
+	require.NoError(t, err)
+	assert.Equal(t, l.Params, again.Params)
+
+	// A line folded at any octet, even inside a quoted value.
+	l, err = ParseContentLine("DTSTART;TZID=\"America/New\r\n _York\":2024010\r\n\t1T090000")
+	require.NoError(t, err)
+	tzid, _ = l.Param("TZID")
+	assert.Equal(t, "America/New_York", tzid)
+	assert.Equal(t, "20240101T090000", l.Value)
+}
+
+func TestParseContentLineErrors(t *testing.T) {
+	cases := []struct {
+		text         string
+		line, column int
+	}{
+		{":20240101", 1, 1},
+		{"RDATE;=DATE:20240101", 1, 7},
+		{"RDATE;VALUE:20240101", 1, 12},
+		{`DTSTART;TZID="America/New_York:20240101T090000`, 1, 14},
+		{`DTSTART;TZID=America"New_York":20240101T090000`, 1, 21},
+		{"DTSTART;TZID=UTC", 1, 17},
+		{"FREQ=DAILY", 1, 5},
+		{"RRULE:FREQ=DAILY\r\nEXDATE;VALUE=DATE\r\n ;TZID=\"U\r\n TC;20240101", 3, 8},
+		{"RRULE:FREQ=DAILY\n\n  RDATE;TZID=\"UTC:20240101", 3, 14},
+	}
+	for _, c := range cases {
+		_, err := ParseContentLines(c.text)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseContentLines(%q): get %v, want a SyntaxError", c.text, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || syntaxErr.Column != c.column {
+			t.Errorf("ParseContentLines(%q): get %v, want line %d, column %d", c.text, err, c.line, c.column)
+		}
+	}
+}
+
+func TestParseContentLines(t *testing.T) {
+	str := "dtstart;tzid=\"America/New_York\":20240101T090000\r\n" +
+		"rrule:freq=weekly;count=4;\r\n byday=mo,\r\n\twe\r\n" +
+		"EXDATE;TZID=America/New_York;VALUE=DATE-TIME:20240103T090000\r\n" +
+		"RDATE;VALUE=PERIOD;TZID=\"America/New_York\":20240106T100000/PT2H\r\n"
+	r, err := StrToRRuleSet(str)
+	require.NoError(t, err)
+	got := r.All()
+	for i := range got {
+		got[i] = got[i].UTC()
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 6, 15, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 10, 14, 0, 0, 0, time.UTC),
+	}
+	assert.True(t, timesEqual(got, want), "get %v, want %v", got, want)
+
+	// The same lines given one by one to Parse.
+	r, err = Parse("DTSTART;TZID=\"America/New_York\":20240101T090000", "rrule:freq=daily;count=2")
+	require.NoError(t, err)
+	assert.Equal(t, "DTSTART;TZID=America/New_York:20240101T090000\nRRULE:FREQ=DAILY;COUNT=2", r.String())
+
+	r, err = NewWithDTStart(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), false,
+		"exdate;tzid=\"America/New_York\":20240102T040000\nRRULE:FREQ=DAILY;\n COUNT=3")
+	require.NoError(t, err)
+	want = []time.Time{time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC)}
+	assert.True(t, timesEqual(r.All(), want), "get %v, want %v", r.All(), want)
+
+	// Errors in values point at the value, counting lines across arguments.
+	_, err = Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY\nEXDATE:2024010X")
+	assert.ErrorContains(t, err, "line 3, column 8: strToDates failed")
+	_, err = StrToRRuleSet("DTSTART:20240101T090000Z\nX-RULE:FREQ=DAILY")
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 2, syntaxErr.Line)
+}
+
+func TestParseIndentedLines(t *testing.T) {
+	// An indented line continues the line before it, as an RFC 5545 fold,
+	// where it used to be trimmed and read on its own.
+	_, err := StrToRRuleSet("DTSTART:20240101T090000Z\n    RRULE:FREQ=DAILY;COUNT=2")
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 1, syntaxErr.Line)
+
+	// Lines given one by one to Parse are still trimmed.
+	r, err := Parse("DTSTART:20240101T090000Z", "    RRULE:FREQ=DAILY;COUNT=2")
+	require.NoError(t, err)
+	assert.Len(t, r.All(), 2)
+	// So is the indentation of the first line of a string.
+	r, err = StrToRRuleSet("    DTSTART:20240101T090000Z\nRRULE:FREQ=DAILY;COUNT=2")
+	require.NoError(t, err)
+	assert.Len(t, r.All(), 2)
+}
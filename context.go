@@ -0,0 +1,64 @@
+package rrule
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// ExpansionError reports an expansion stopped by its context.
+// It wraps the context error, so errors.Is(err, context.DeadlineExceeded)
+// holds for an expansion that ran out of time.
+type ExpansionError struct {
+	Err     error     // ctx.Err()
+	Reached time.Time // the point in time the expansion had reached
+	Emitted int       // number of occurrences returned before it stopped
+}
+
+func (e *ExpansionError) Error() string {
+	return fmt.Sprintf("rrule: expansion stopped at %s after %d occurrences: %v",
+		e.Reached.Format(time.RFC3339), e.Emitted, e.Err)
+}
+
+func (e *ExpansionError) Unwrap() error {
+	return e.Err
+}
+
+// AllContext is like All but stops when ctx is done. The context is checked
+// while periods are scanned, not only between occurrences, so rules that scan
+// many empty periods honor deadlines too.
+// It returns the occurrences found so far with an *ExpansionError wrapping
+// ctx.Err(), or a *LimitError when one of the recurrence limits is reached.
+func (set *Recurrence) AllContext(ctx context.Context) ([]time.Time, error) {
+	return set.betweenWithLimits(ctx, time.Time{}, endOfTime, true, set.limits)
+}
+
+// BetweenContext is like Between but stops when ctx is done.
+// Errors are reported as in AllContext.
+func (set *Recurrence) BetweenContext(ctx context.Context, after, before time.Time, inc bool) ([]time.Time, error) {
+	return set.betweenWithLimits(ctx, after, before, inc, set.limits)
+}
+
+// OccurrencesContext returns a sequence over all occurrences of the Recurrence
+// that stops when ctx is done. If the expansion stops on the context or on a
+// limit, the last pair yielded holds the error as in AllContext.
+func (set *Recurrence) OccurrencesContext(ctx context.Context) iter.Seq2[time.Time, error] {
+	return func(yield func(time.Time, error) bool) {
+		it := set.limitedIterator(ctx, time.Time{}, set.limits)
+		emitted := 0
+		for dt, ok := it.next(); ok; dt, ok = it.next() {
+			if max := set.limits.MaxOccurrences; max > 0 && emitted == max {
+				yield(time.Time{}, &LimitError{Limit: "MaxOccurrences", Value: max})
+				return
+			}
+			if !yield(dt, nil) {
+				return
+			}
+			emitted++
+		}
+		if err := it.stopped(emitted); err != nil {
+			yield(time.Time{}, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package rrule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllContextCanceled(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := r.AllContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("get error %v, want context.Canceled", err)
+	}
+	var expansionErr *ExpansionError
+	if !errors.As(err, &expansionErr) || expansionErr.Emitted != 0 {
+		t.Errorf("get error %#v, want an ExpansionError with no occurrence emitted", err)
+	}
+	if len(res) != 0 {
+		t.Errorf("get %v, want no occurrence", res)
+	}
+}
+
+func TestBetweenContextDeadlineWhileScanning(t *testing.T) {
+	// The interval never reaches hour 1: without limits, a single period
+	// advance never returns, so the deadline must be checked while scanning.
+	r, _ := Parse("DTSTART:20240101T000000Z", "RRULE:FREQ=SECONDLY;INTERVAL=86400;BYHOUR=1")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := r.BetweenContext(ctx, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get error %v, want context.DeadlineExceeded", err)
+	}
+	var expansionErr *ExpansionError
+	if !errors.As(err, &expansionErr) || !expansionErr.Reached.After(r.GetDTStart()) {
+		t.Errorf("get error %#v, want an ExpansionError past DTSTART", err)
+	}
+}
+
+func TestBetweenContext(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "RDATE:20240106T090000Z", "EXDATE:20240110T090000Z")
+	after, before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	res, err := r.BetweenContext(context.Background(), after, before, true)
+	if err != nil {
+		t.Fatalf("BetweenContext failed: %v", err)
+	}
+	if want := r.Between(after, before, true); !timesEqual(res, want) {
+		t.Errorf("get %v, want %v", res, want)
+	}
+}
+
+func TestOccurrencesContext(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var res []time.Time
+	var err error
+	for dt, e := range r.OccurrencesContext(ctx) {
+		if e != nil {
+			err = e
+			break
+		}
+		res = append(res, dt)
+		if len(res) == 5 {
+			cancel()
+		}
+	}
+	var expansionErr *ExpansionError
+	if !errors.As(err, &expansionErr) || !errors.Is(err, context.Canceled) || expansionErr.Emitted != 5 {
+		t.Fatalf("get error %#v, want a canceled ExpansionError after 5 occurrences", err)
+	}
+	if want := r.Between(r.GetDTStart(), time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), true); !timesEqual(res, want) {
+		t.Errorf("get %v, want %v", res, want)
+	}
+
+	// Limits set on the recurrence are reported as well.
+	r, _ = ParseWithLimits(Limits{MaxOccurrences: 3}, "DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY")
+	n := 0
+	for _, e := range r.OccurrencesContext(context.Background()) {
+		if e != nil {
+			err = e
+			break
+		}
+		n++
+	}
+	if !errors.Is(err, ErrIterationLimit) || n != 3 {
+		t.Errorf("get %d occurrences and error %v, want 3 and ErrIterationLimit", n, err)
+	}
+}
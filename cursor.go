@@ -0,0 +1,310 @@
+package rrule
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+var (
+	// ErrCursorMismatch is returned by ResumeFrom for a cursor created for a
+	// different recurrence.
+	ErrCursorMismatch = errors.New("rrule: cursor was created for a different recurrence")
+	// ErrInvalidCursor is returned when a cursor token cannot be decoded.
+	ErrInvalidCursor = errors.New("rrule: invalid cursor token")
+)
+
+// cursorVersion is the first byte of every cursor token.
+const cursorVersion = 2
+
+// Cursor is the position of an iteration over a recurrence, used to page
+// through occurrences across requests. It marshals to an opaque token with
+// MarshalText. A Cursor is bound to the recurrence it was created for,
+// including its RDATE and EXDATE values.
+type Cursor struct {
+	fingerprint uint64
+	index       int          // number of occurrences emitted so far
+	last        time.Time    // last emitted occurrence, zero before the first one
+	rules       []ruleCursor // one per RRULE, nil before the first occurrence
+	rdate       int          // number of sorted RDATE values up to last
+	exdate      int          // number of sorted EXDATE values up to last
+}
+
+// ruleCursor is the position of one RRULE of the recurrence.
+type ruleCursor struct {
+	period  int  // rule period, in seek units, to restart from
+	emitted int  // rule occurrences up to last, counted against COUNT
+	done    bool // the rule has no occurrence after last
+}
+
+// Index returns the zero-based index of the next occurrence, which is the
+// number of occurrences emitted before the cursor.
+func (c Cursor) Index() int {
+	return c.index
+}
+
+// Last returns the last occurrence emitted before the cursor, or the zero time
+// if the cursor is at the start of the recurrence.
+func (c Cursor) Last() time.Time {
+	return c.last
+}
+
+// MarshalText encodes the cursor as an opaque URL-safe token.
+func (c Cursor) MarshalText() ([]byte, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, cursorVersion)
+	buf = binary.BigEndian.AppendUint64(buf, c.fingerprint)
+	var flags byte
+	if !c.last.IsZero() {
+		flags |= 1
+	}
+	buf = append(buf, flags)
+	buf = binary.AppendUvarint(buf, uint64(c.index))
+	buf = binary.AppendUvarint(buf, uint64(c.rdate))
+	buf = binary.AppendUvarint(buf, uint64(c.exdate))
+	buf = binary.AppendUvarint(buf, uint64(len(c.rules)))
+	for _, rc := range c.rules {
+		buf = binary.AppendVarint(buf, int64(rc.period))
+		// The low bit of the emitted count holds the done flag.
+		emitted := uint64(rc.emitted) << 1
+		if rc.done {
+			emitted |= 1
+		}
+		buf = binary.AppendUvarint(buf, emitted)
+	}
+	if !c.last.IsZero() {
+		buf = binary.AppendVarint(buf, c.last.Unix())
+		buf = binary.AppendUvarint(buf, uint64(c.last.Nanosecond()))
+	}
+	out := make([]byte, base64.RawURLEncoding.EncodedLen(len(buf)))
+	base64.RawURLEncoding.Encode(out, buf)
+	return out, nil
+}
+
+// UnmarshalText decodes a token produced by MarshalText.
+// It returns ErrInvalidCursor if the token is malformed.
+func (c *Cursor) UnmarshalText(text []byte) error {
+	buf := make([]byte, base64.RawURLEncoding.DecodedLen(len(text)))
+	n, err := base64.RawURLEncoding.Decode(buf, text)
+	if err != nil || n < 10 || buf[0] != cursorVersion {
+		return ErrInvalidCursor
+	}
+	buf = buf[:n]
+	var res Cursor
+	res.fingerprint = binary.BigEndian.Uint64(buf[1:9])
+	flags := buf[9]
+	buf = buf[10:]
+
+	fail := false
+	uvarint := func() int {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 || v > 1<<31 {
+			fail = true
+			return 0
+		}
+		buf = buf[n:]
+		return int(v)
+	}
+	varint := func() int64 {
+		v, n := binary.Varint(buf)
+		if n <= 0 {
+			fail = true
+			return 0
+		}
+		buf = buf[n:]
+		return v
+	}
+	res.index = uvarint()
+	res.rdate = uvarint()
+	res.exdate = uvarint()
+	nrules := uvarint()
+	if nrules > len(buf) {
+		return ErrInvalidCursor
+	}
+	for i := 0; i < nrules && !fail; i++ {
+		period := int(varint())
+		emitted := uvarint()
+		if period < 0 {
+			fail = true
+		}
+		res.rules = append(res.rules, ruleCursor{period: period, emitted: emitted >> 1, done: emitted&1 != 0})
+	}
+	if flags&1 != 0 {
+		sec := varint()
+		nsec := uvarint()
+		res.last = time.Unix(sec, int64(nsec)).UTC()
+	}
+	if fail || len(buf) != 0 || flags&^1 != 0 {
+		return ErrInvalidCursor
+	}
+	*c = res
+	return nil
+}
+
+// fingerprint identifies the recurrence a cursor was created for: its DTSTART
+// and location, its rules and its RDATE and EXDATE values. It reads the state
+// of the set rather than its serialization, which depends on the TZIDPolicy
+// and may resolve locations.
+func (set *Recurrence) fingerprint() uint64 {
+	var buf []byte
+	appendTime := func(t time.Time) {
+		_, offset := t.Zone()
+		buf = binary.AppendVarint(buf, t.Unix())
+		buf = binary.AppendVarint(buf, int64(offset))
+	}
+	buf = append(buf, boolByte(set.allDay), boolByte(set.floating))
+	appendTime(set.dtstart)
+	buf = append(append(buf, set.dtstart.Location().String()...), 0)
+	for _, rules := range [][]*compiledRule{set.rules(), set.exrules} {
+		for _, r := range rules {
+			buf = append(append(buf, r.rrulePropertiesString()...), 0)
+		}
+		buf = append(buf, 0)
+	}
+	for _, ts := range [][]time.Time{set.sortedRDate, set.sortedExDate} {
+		buf = binary.AppendUvarint(buf, uint64(len(ts)))
+		for _, t := range ts {
+			appendTime(t)
+		}
+	}
+	h := fnv.New64a()
+	h.Write(buf)
+	return h.Sum64()
+}
+
+// StartCursor returns a cursor positioned before the first occurrence.
+func (set *Recurrence) StartCursor() Cursor {
+	return Cursor{fingerprint: set.fingerprint()}
+}
+
+// CursorIterator iterates over the occurrences of a recurrence and can report
+// its position as a Cursor at any point.
+type CursorIterator struct {
+	set   *Recurrence
+	it    *setIterator
+	base  Cursor
+	rules []*cursorRule
+
+	emitted int       // occurrences emitted by this iterator
+	last    time.Time // last occurrence emitted
+}
+
+// cursorRule tracks one rule stream of a CursorIterator.
+type cursorRule struct {
+	rule    *compiledRule
+	base    ruleCursor
+	emitted int       // rule values pulled into the merge
+	pending bool      // the merge holds a rule value, peek
+	peek    time.Time // last rule value pulled into the merge
+	done    bool
+}
+
+// ResumeFrom returns an iterator continuing right after the position recorded
+// in c. It returns ErrCursorMismatch if c was created for another recurrence,
+// or if the recurrence changed since.
+func (set *Recurrence) ResumeFrom(c Cursor) (*CursorIterator, error) {
+	rules := set.rules()
+	if c.fingerprint != set.fingerprint() || c.rdate > len(set.sortedRDate) || c.exdate > len(set.sortedExDate) ||
+		c.rules != nil && len(c.rules) != len(rules) {
+		return nil, ErrCursorMismatch
+	}
+	ci := &CursorIterator{set: set, base: c, last: c.last}
+	ci.it = newSetIterator(set.allDay, false)
+	ci.it.rlist.add(timeSliceIterator(set.sortedRDate[c.rdate:]))
+	for i, r := range rules {
+		cr := &cursorRule{rule: r}
+		if c.rules != nil {
+			cr.base = c.rules[i]
+		}
+		if r.count > 0 && cr.base.emitted > r.count {
+			return nil, ErrCursorMismatch
+		}
+		cr.done = cr.base.done
+		ci.rules = append(ci.rules, cr)
+		if !cr.done {
+			ci.it.rlist.add(cr.from(c.last, set.limits))
+		}
+	}
+	ci.it.exlist.add(timeSliceIterator(set.sortedExDate[c.exdate:]))
+	for _, r := range set.exrules {
+		// Exclusion rule values up to last are skipped by the merge.
+		ci.it.exlist.add(r.ruleIteratorFrom(c.last, set.limits))
+	}
+	return ci, nil
+}
+
+// from returns the rule values after last, restarting the rule at the period
+// recorded in the cursor. COUNT is enforced here from the number of rule values
+// already emitted, since the rule iterator does not start at DTSTART.
+func (cr *cursorRule) from(last time.Time, limits Limits) Next {
+	r := cr.rule
+	iterator := &rIterator{}
+	iterator.reset(r, time.Time{}, limits)
+	if cr.base.period != 0 {
+		iterator.seek(cr.base.period)
+	}
+	iterator.count = 0
+	remaining := r.count - cr.base.emitted
+	return func() (time.Time, bool) {
+		for r.count == 0 || remaining > 0 {
+			dt, ok := iterator.next()
+			if !ok {
+				break
+			}
+			if !last.IsZero() && !dt.After(last) {
+				continue
+			}
+			remaining--
+			cr.emitted++
+			cr.pending = true
+			cr.peek = dt
+			return dt, true
+		}
+		cr.pending = false
+		cr.done = true
+		return time.Time{}, false
+	}
+}
+
+// Next returns the next occurrence and true if it exists, else zero value and false.
+func (ci *CursorIterator) Next() (time.Time, bool) {
+	dt, ok := ci.it.next()
+	if ok {
+		ci.emitted++
+		ci.last = dt
+	}
+	return dt, ok
+}
+
+// Cursor returns the position of the iterator, right after the last
+// occurrence returned by Next.
+func (ci *CursorIterator) Cursor() Cursor {
+	set := ci.set
+	c := Cursor{
+		fingerprint: ci.base.fingerprint,
+		index:       ci.base.index + ci.emitted,
+		last:        ci.last,
+		rdate:       ci.base.rdate,
+		exdate:      ci.base.exdate,
+		rules:       make([]ruleCursor, len(ci.rules)),
+	}
+	for i, cr := range ci.rules {
+		rc := ruleCursor{period: cr.base.period, emitted: cr.base.emitted + cr.emitted, done: cr.done}
+		if cr.pending && cr.peek.After(ci.last) {
+			// The merge primed one rule value past the last occurrence. A
+			// value equal to it was merged with another stream and is consumed.
+			rc.emitted--
+		}
+		if !c.last.IsZero() {
+			rc.period = cr.rule.seekUnits(c.last)
+		}
+		c.rules[i] = rc
+	}
+	if !c.last.IsZero() {
+		c.rdate = searchTimesAfter(set.sortedRDate, c.last)
+		c.exdate = searchTimesAfter(set.sortedExDate, c.last)
+	}
+	return c
+}
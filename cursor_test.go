@@ -0,0 +1,141 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// pageThrough collects up to limit occurrences of r in pages of size n, going
+// through a marshaled token between pages.
+func pageThrough(t *testing.T, r *Recurrence, n, limit int) []time.Time {
+	t.Helper()
+	var res []time.Time
+	token, err := r.StartCursor().MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText failed: %v", err)
+	}
+	for len(res) < limit {
+		var c Cursor
+		if err := c.UnmarshalText(token); err != nil {
+			t.Fatalf("UnmarshalText(%s) failed: %v", token, err)
+		}
+		if c.Index() != len(res) {
+			t.Fatalf("Index: get %d, want %d", c.Index(), len(res))
+		}
+		it, err := r.ResumeFrom(c)
+		if err != nil {
+			t.Fatalf("ResumeFrom failed: %v", err)
+		}
+		page := 0
+		for ; page < n && len(res) < limit; page++ {
+			dt, ok := it.Next()
+			if !ok {
+				break
+			}
+			res = append(res, dt)
+		}
+		if page < n {
+			return res
+		}
+		if token, err = it.Cursor().MarshalText(); err != nil {
+			t.Fatalf("MarshalText failed: %v", err)
+		}
+	}
+	return res
+}
+
+func TestCursorPagination(t *testing.T) {
+	recurrences := queryTestRecurrences(t)
+	for _, opt := range seekTestOptions(t) {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		recurrences = append(recurrences, r)
+	}
+	r, _ := newRecurrence(ROption{Freq: MONTHLY, Count: 20, Bysetpos: []int{1, -1}, Byweekday: []Weekday{MO, FR}, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	recurrences = append(recurrences, r)
+
+	const limit = 120
+	for i, r := range recurrences {
+		if i%2 == 0 {
+			dtstart := r.GetDTStart()
+			r.RDate(dtstart.AddDate(0, 0, -3))
+			r.RDate(dtstart.AddDate(0, 1, 0))
+			r.RDate(r.After(dtstart.AddDate(0, 0, 10), true))
+			r.ExDate(r.After(dtstart.AddDate(0, 0, 20), true))
+		}
+		var want []time.Time
+		for v := range r.Occurrences() {
+			if len(want) == limit {
+				break
+			}
+			want = append(want, v)
+		}
+		for _, n := range []int{1, 7, 50} {
+			if got := pageThrough(t, r, n, limit); !timesEqual(got, want) {
+				t.Errorf("%s with pages of %d: get %v, want %v", r.String(), n, got, want)
+			}
+		}
+	}
+}
+
+func TestCursorLast(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY;COUNT=3")
+	it, _ := r.ResumeFrom(r.StartCursor())
+	if c := it.Cursor(); !c.Last().IsZero() || c.Index() != 0 {
+		t.Errorf("get %v at index %d, want the start", c.Last(), c.Index())
+	}
+	it.Next()
+	dt, _ := it.Next()
+	if c := it.Cursor(); !c.Last().Equal(dt) || c.Index() != 2 {
+		t.Errorf("get %v at index %d, want %v at index 2", c.Last(), c.Index(), dt)
+	}
+	it.Next()
+	if _, ok := it.Next(); ok {
+		t.Fatal("expected the end of the recurrence")
+	}
+	it, _ = r.ResumeFrom(it.Cursor())
+	if dt, ok := it.Next(); ok {
+		t.Errorf("get %v after the end, want nothing", dt)
+	}
+}
+
+func TestCursorMismatch(t *testing.T) {
+	r1, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY")
+	r2, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY;INTERVAL=2")
+	it, _ := r1.ResumeFrom(r1.StartCursor())
+	it.Next()
+	c := it.Cursor()
+	if _, err := r2.ResumeFrom(c); !errors.Is(err, ErrCursorMismatch) {
+		t.Errorf("ResumeFrom on another rule: get error %v, want ErrCursorMismatch", err)
+	}
+	r1.ExDate(time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC))
+	if _, err := r1.ResumeFrom(c); !errors.Is(err, ErrCursorMismatch) {
+		t.Errorf("ResumeFrom after a change: get error %v, want ErrCursorMismatch", err)
+	}
+	if _, err := r1.ResumeFrom(Cursor{}); !errors.Is(err, ErrCursorMismatch) {
+		t.Errorf("ResumeFrom with a zero cursor: get error %v, want ErrCursorMismatch", err)
+	}
+
+	// Recurrences that Lines cannot write are told apart too.
+	r1, _ = New(ROption{Freq: DAILY, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("", 3600))})
+	r2, _ = New(ROption{Freq: WEEKLY, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("", 7200))})
+	r1.SetTZIDPolicy(TZIDError)
+	r2.SetTZIDPolicy(TZIDError)
+	if _, err := r2.ResumeFrom(r1.StartCursor()); !errors.Is(err, ErrCursorMismatch) {
+		t.Errorf("ResumeFrom on another unnamed location: get error %v, want ErrCursorMismatch", err)
+	}
+}
+
+func TestCursorInvalidToken(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY")
+	token, _ := r.StartCursor().MarshalText()
+	for _, text := range []string{"", "!!!", "AAAA", string(token[:len(token)-2]), string(token) + "AA"} {
+		var c Cursor
+		if err := c.UnmarshalText([]byte(text)); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("UnmarshalText(%q): get error %v, want ErrInvalidCursor", text, err)
+		}
+	}
+}
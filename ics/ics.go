@@ -0,0 +1,109 @@
+// Package ics reads iCalendar (RFC 5545) streams and builds a recurrence for
+// each series of events and to-dos they define.
+package ics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	rrule "github.com/yinjun1991/rrule-go"
+)
+
+// Component is an iCalendar component, such as a VEVENT, with its properties
+// and the components nested in it.
+type Component struct {
+	Name       string
+	Properties []rrule.ContentLine
+	Children   []*Component
+	Line       int // line of the BEGIN property
+}
+
+// Property returns the first property called name.
+func (c *Component) Property(name string) (rrule.ContentLine, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return rrule.ContentLine{}, false
+}
+
+// PropertiesNamed returns the properties called name, in order.
+func (c *Component) PropertiesNamed(name string) []rrule.ContentLine {
+	var props []rrule.ContentLine
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Error reports a component that could not be read.
+type Error struct {
+	Component string // name of the component, such as "VEVENT"
+	UID       string // UID of the component, if known
+	Line      int    // line of its BEGIN property
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.UID != "" {
+		return fmt.Sprintf("ics: %s %q at line %d: %v", e.Component, e.UID, e.Line, e.Err)
+	}
+	return fmt.Sprintf("ics: %s at line %d: %v", e.Component, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Decode reads the components of an iCalendar stream, usually a single
+// VCALENDAR. Lines may be folded and end with CRLF or LF.
+func Decode(r io.Reader) ([]*Component, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := rrule.ParseContentLines(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("ics: %w", err)
+	}
+
+	var top []*Component
+	var stack []*Component
+	for _, l := range lines {
+		switch l.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(l.Value), Line: l.Line}
+			if len(stack) == 0 {
+				top = append(top, c)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ics: line %d: END:%s without BEGIN", l.Line, l.Value)
+			}
+			c := stack[len(stack)-1]
+			if !strings.EqualFold(l.Value, c.Name) {
+				return nil, &Error{Component: c.Name, Line: c.Line, Err: fmt.Errorf("END:%s at line %d does not match BEGIN:%s", l.Value, l.Line, c.Name)}
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ics: line %d: property %s outside a component", l.Line, l.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, l)
+		}
+	}
+	if len(stack) > 0 {
+		c := stack[len(stack)-1]
+		return nil, &Error{Component: c.Name, Line: c.Line, Err: fmt.Errorf("missing END:%s", c.Name)}
+	}
+	return top, nil
+}
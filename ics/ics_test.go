@@ -0,0 +1,169 @@
+package ics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rrule "github.com/yinjun1991/rrule-go"
+)
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"DTSTART;TZID=\"America/New_York\";X-FOO=bar:20240101T090000\r\n" +
+	"DTEND;TZID=America/New_York:20240101T093000\r\n" +
+	"RRULE:FREQ=DAILY;COUNT=5;\r\n BYDAY=MO,TU,WE,TH,FR\r\n" +
+	"EXDATE;TZID=America/New_York:20240103T090000\r\n" +
+	"SUMMARY:Stand-up\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT5M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:report@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20240105\r\n" +
+	"DUE;VALUE=DATE:20240106\r\n" +
+	"RRULE:FREQ=MONTHLY;COUNT=2\r\n" +
+	"END:VTODO\r\n" +
+	"begin:vevent\r\n" +
+	"UID:standup@example.com\r\n" +
+	"RECURRENCE-ID;TZID=America/New_York:20240102T090000\r\n" +
+	"DTSTART;TZID=America/New_York:20240102T140000\r\n" +
+	"DTEND;TZID=America/New_York:20240102T150000\r\n" +
+	"SUMMARY:Stand-up (afternoon\\, moved)\r\n" +
+	"end:vevent\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	components, err := Decode(strings.NewReader(calendar))
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	cal := components[0]
+	assert.Equal(t, "VCALENDAR", cal.Name)
+	require.Len(t, cal.Children, 3)
+	event := cal.Children[0]
+	assert.Equal(t, 4, event.Line)
+	require.Len(t, event.Children, 1)
+	assert.Equal(t, "VALARM", event.Children[0].Name)
+	rule, ok := event.Property("RRULE")
+	require.True(t, ok)
+	assert.Equal(t, "FREQ=DAILY;COUNT=5;BYDAY=MO,TU,WE,TH,FR", rule.Value)
+	assert.Len(t, event.PropertiesNamed("EXDATE"), 1)
+	summary, _ := cal.Children[2].Property("SUMMARY")
+	assert.Equal(t, "Stand-up (afternoon, moved)", summary.Text())
+}
+
+func TestRead(t *testing.T) {
+	series, err := Read(strings.NewReader(calendar))
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+
+	standup := series[0]
+	assert.Equal(t, "standup@example.com", standup.UID)
+	require.Len(t, standup.Overrides, 1)
+	var starts []time.Time
+	var lengths []time.Duration
+	var moved *Component
+	for instance := range standup.Recurrence.Instances() {
+		starts = append(starts, instance.Start.UTC())
+		lengths = append(lengths, instance.Duration)
+		if instance.Override != nil {
+			moved = instance.Override.Payload.(*Component)
+		}
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 19, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 4, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 5, 14, 0, 0, 0, time.UTC),
+	}, starts)
+	assert.Equal(t, []time.Duration{30 * time.Minute, time.Hour, 30 * time.Minute, 30 * time.Minute}, lengths)
+	assert.Same(t, standup.Overrides[0], moved)
+
+	report := series[1]
+	assert.Equal(t, "report@example.com", report.UID)
+	assert.True(t, report.Recurrence.IsAllDay())
+	assert.Equal(t, rrule.Duration{Days: 1}, report.Recurrence.GetDuration())
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC),
+	}, report.Recurrence.All())
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []struct {
+		desc      string
+		text      string
+		component string
+		line      int
+	}{
+		{
+			desc:      "mismatched END",
+			text:      "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VTODO\nEND:VCALENDAR",
+			component: "VEVENT",
+			line:      2,
+		},
+		{
+			desc:      "missing END",
+			text:      "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nEND:VCALENDAR",
+			component: "VEVENT",
+			line:      2,
+		},
+		{
+			desc:      "bad RRULE",
+			text:      "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nDTSTART:20240101T090000Z\nRRULE:FREQ=SOMETIMES\nEND:VEVENT\nEND:VCALENDAR",
+			component: "VEVENT",
+			line:      2,
+		},
+		{
+			desc:      "RRULE without DTSTART",
+			text:      "BEGIN:VTODO\nUID:a\nRRULE:FREQ=DAILY\nEND:VTODO",
+			component: "VTODO",
+			line:      1,
+		},
+		{
+			desc:      "override without UID",
+			text:      "BEGIN:VEVENT\nRECURRENCE-ID:20240101T090000Z\nEND:VEVENT",
+			component: "VEVENT",
+			line:      1,
+		},
+		{
+			desc:      "duplicate UID",
+			text:      "BEGIN:VEVENT\nUID:a\nDTSTART:20240101T090000Z\nEND:VEVENT\nBEGIN:VEVENT\nUID:a\nDTSTART:20240102T090000Z\nEND:VEVENT",
+			component: "VEVENT",
+			line:      5,
+		},
+		{
+			desc:      "bad RECURRENCE-ID",
+			text:      "BEGIN:VEVENT\nUID:a\nDTSTART:20240101T090000Z\nEND:VEVENT\nBEGIN:VEVENT\nUID:a\nRECURRENCE-ID:tomorrow\nEND:VEVENT",
+			component: "VEVENT",
+			line:      5,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := Read(strings.NewReader(c.text))
+			var icsErr *Error
+			require.ErrorAs(t, err, &icsErr)
+			assert.Equal(t, c.component, icsErr.Component)
+			assert.Equal(t, c.line, icsErr.Line)
+		})
+	}
+
+	// The syntax error of a property keeps its position in the stream.
+	_, err := Read(strings.NewReader("BEGIN:VEVENT\nUID:a\nDTSTART:20240101T090000Z\nRRULE:FREQ=SOMETIMES\nEND:VEVENT"))
+	var syntaxErr *rrule.SyntaxError
+	require.True(t, errors.As(err, &syntaxErr), "get %v", err)
+	assert.Equal(t, 4, syntaxErr.Line)
+	assert.Equal(t, 7, syntaxErr.Column)
+
+	_, err = Read(strings.NewReader("VERSION:2.0\nBEGIN:VCALENDAR\nEND:VCALENDAR"))
+	assert.EqualError(t, err, "ics: line 1: property VERSION outside a component")
+}
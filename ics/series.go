@@ -0,0 +1,214 @@
+package ics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	rrule "github.com/yinjun1991/rrule-go"
+)
+
+// Series is an event or a to-do together with the components that override
+// some of its occurrences, which share its UID.
+type Series struct {
+	UID       string
+	Master    *Component   // nil when the stream only holds overrides
+	Overrides []*Component // components with a RECURRENCE-ID, in stream order
+	// Recurrence holds the occurrences of Master, with an rrule.Override for
+	// each component of Overrides as its payload. It is nil when Master is,
+	// or when Master is a to-do without DTSTART.
+	Recurrence *rrule.Recurrence
+}
+
+// Read reads an iCalendar stream and returns the series of its VEVENT and
+// VTODO components, in the order their UIDs first appear. TZID parameters
+// refer to the VTIMEZONE components of the stream, or else are found by
+// rrule.DefaultLocationResolver.
+func Read(r io.Reader) ([]*Series, error) {
+	components, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	timezones := Timezones{}
+	var series []*Series
+	byUID := make(map[string]*Series)
+	var collect func(components []*Component) error
+	collect = func(components []*Component) error {
+		for _, c := range components {
+			switch c.Name {
+			case "VCALENDAR":
+				if err := collect(c.Children); err != nil {
+					return err
+				}
+			case "VTIMEZONE":
+				loc, err := LoadLocation(c)
+				if err != nil {
+					return &Error{Component: c.Name, Line: c.Line, Err: err}
+				}
+				timezones[loc.String()] = loc
+			case "VEVENT", "VTODO":
+				uid := ""
+				if p, ok := c.Property("UID"); ok {
+					uid = p.Text()
+				}
+				_, isOverride := c.Property("RECURRENCE-ID")
+				if uid == "" {
+					if isOverride {
+						return &Error{Component: c.Name, Line: c.Line, Err: errors.New("RECURRENCE-ID without UID")}
+					}
+					series = append(series, &Series{Master: c})
+					continue
+				}
+				s, ok := byUID[uid]
+				if !ok {
+					s = &Series{UID: uid}
+					byUID[uid] = s
+					series = append(series, s)
+				}
+				switch {
+				case isOverride:
+					s.Overrides = append(s.Overrides, c)
+				case s.Master != nil:
+					return &Error{Component: c.Name, UID: uid, Line: c.Line, Err: fmt.Errorf("UID already used by the %s at line %d", s.Master.Name, s.Master.Line)}
+				default:
+					s.Master = c
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(components); err != nil {
+		return nil, err
+	}
+
+	resolver := rrule.ChainLocationResolvers(timezones, rrule.DefaultLocationResolver)
+	for _, s := range series {
+		if err := s.build(resolver); err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// build sets the Recurrence of s, finding the locations of TZIDs with
+// resolver.
+func (s *Series) build(resolver rrule.LocationResolver) error {
+	if s.Master == nil {
+		return nil
+	}
+	rec, err := recurrenceOf(s.Master, resolver)
+	if err != nil {
+		return &Error{Component: s.Master.Name, UID: s.UID, Line: s.Master.Line, Err: err}
+	}
+	if rec == nil {
+		return nil
+	}
+	for _, c := range s.Overrides {
+		o, err := overrideOf(c, rec.GetDTStart().Location(), resolver)
+		if err != nil {
+			return &Error{Component: c.Name, UID: s.UID, Line: c.Line, Err: err}
+		}
+		rec.SetOverride(o)
+	}
+	s.Recurrence = rec
+	return nil
+}
+
+// recurrenceOf builds the recurrence of a master component. It returns nil
+// for a to-do without DTSTART, which has no occurrences.
+func recurrenceOf(c *Component, resolver rrule.LocationResolver) (*rrule.Recurrence, error) {
+	dtstart, ok := c.Property("DTSTART")
+	if !ok {
+		for _, name := range []string{"RRULE", "RDATE"} {
+			if _, ok := c.Property(name); ok {
+				return nil, fmt.Errorf("%s without DTSTART", name)
+			}
+		}
+		if c.Name == "VTODO" {
+			return nil, nil
+		}
+		return nil, errors.New("missing DTSTART")
+	}
+
+	lines := []rrule.ContentLine{timeProperty(dtstart)}
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "RRULE", "EXRULE", "DURATION":
+			lines = append(lines, p)
+		case "RDATE", "EXDATE", "DTEND":
+			lines = append(lines, timeProperty(p))
+		case "DUE":
+			// The end of a to-do gives the length of its occurrences.
+			p = timeProperty(p)
+			p.Name = "DTEND"
+			lines = append(lines, p)
+		}
+	}
+	return rrule.FromContentLines(resolver, lines...)
+}
+
+// overrideOf returns the override described by a component with a
+// RECURRENCE-ID. Times without a TZID are read in loc.
+func overrideOf(c *Component, loc *time.Location, resolver rrule.LocationResolver) (rrule.Override, error) {
+	rid, _ := c.Property("RECURRENCE-ID")
+	id, err := timeProperty(rid).Time(loc, resolver)
+	if err != nil {
+		return rrule.Override{}, fmt.Errorf("RECURRENCE-ID: %w", err)
+	}
+	o := rrule.Override{RecurrenceID: id, Payload: c}
+	if r, _ := rid.Param("RANGE"); strings.EqualFold(r, "THISANDFUTURE") {
+		o.ThisAndFuture = true
+	}
+
+	start := id
+	if p, ok := c.Property("DTSTART"); ok {
+		if o.Start, err = timeProperty(p).Time(loc, resolver); err != nil {
+			return rrule.Override{}, fmt.Errorf("DTSTART: %w", err)
+		}
+		start = o.Start
+	}
+	if p, ok := c.Property("DURATION"); ok {
+		d, err := rrule.StrToDuration(p.Value)
+		if err != nil {
+			return rrule.Override{}, fmt.Errorf("DURATION: %w", err)
+		}
+		o.Duration = &d
+		return o, nil
+	}
+	for _, name := range []string{"DTEND", "DUE"} {
+		p, ok := c.Property(name)
+		if !ok {
+			continue
+		}
+		end, err := timeProperty(p).Time(loc, resolver)
+		if err != nil {
+			return rrule.Override{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if end.Before(start) {
+			return rrule.Override{}, fmt.Errorf("%s is before DTSTART", name)
+		}
+		d := rrule.Duration{Clock: end.Sub(start)}
+		if value, _ := p.Param("VALUE"); strings.EqualFold(value, "DATE") {
+			d = rrule.Duration{Days: int(end.Sub(start) / (24 * time.Hour))}
+		}
+		o.Duration = &d
+		break
+	}
+	return o, nil
+}
+
+// timeProperty returns p with only its VALUE and TZID parameters, the ones
+// that affect the time it holds.
+func timeProperty(p rrule.ContentLine) rrule.ContentLine {
+	var params []rrule.Param
+	for _, param := range p.Params {
+		if param.Name == "VALUE" || param.Name == "TZID" {
+			params = append(params, param)
+		}
+	}
+	p.Params = params
+	return p
+}
@@ -0,0 +1,36 @@
+package ics
+
+import (
+	"fmt"
+	"time"
+
+	rrule "github.com/yinjun1991/rrule-go"
+)
+
+// Timezones maps TZIDs to the locations defined by VTIMEZONE components. It
+// resolves the TZIDs of the components read along with them.
+type Timezones map[string]*time.Location
+
+func (z Timezones) ResolveLocation(tzid string) (*time.Location, error) {
+	if loc, ok := z[tzid]; ok {
+		return loc, nil
+	}
+	return nil, fmt.Errorf("no VTIMEZONE for TZID %q", tzid)
+}
+
+// LoadLocation returns the location defined by a VTIMEZONE component, named
+// after its TZID, as rrule.LoadVTimezone does.
+func LoadLocation(c *Component) (*time.Location, error) {
+	return rrule.LoadVTimezone(c.contentLines()...)
+}
+
+// contentLines returns c as content lines, from its BEGIN line to its END
+// line.
+func (c *Component) contentLines() []rrule.ContentLine {
+	lines := []rrule.ContentLine{{Name: "BEGIN", Value: c.Name, Line: c.Line}}
+	lines = append(lines, c.Properties...)
+	for _, child := range c.Children {
+		lines = append(lines, child.contentLines()...)
+	}
+	return append(lines, rrule.ContentLine{Name: "END", Value: c.Name})
+}
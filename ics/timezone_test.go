@@ -0,0 +1,161 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outlookEastern is the VTIMEZONE Outlook writes for "Eastern Standard Time".
+const outlookEastern = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Eastern Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0400\r\n" +
+	"TZOFFSETTO:-0500\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0500\r\n" +
+	"TZOFFSETTO:-0400\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n"
+
+func loadLocation(t *testing.T, text string) *time.Location {
+	t.Helper()
+	components, err := Decode(strings.NewReader(text))
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	loc, err := LoadLocation(components[0])
+	require.NoError(t, err)
+	return loc
+}
+
+func TestLoadLocationYearlyRules(t *testing.T) {
+	loc := loadLocation(t, outlookEastern)
+	assert.Equal(t, "Eastern Standard Time", loc.String())
+
+	ny, _ := time.LoadLocation("America/New_York")
+	// Past transitionHorizon, the POSIX rule keeps the transitions going.
+	for _, year := range []int{2024, 2150} {
+		for d := time.Date(year, 1, 1, 12, 0, 0, 0, time.UTC); d.Year() == year; d = d.Add(6 * time.Hour) {
+			_, got := d.In(loc).Zone()
+			_, want := d.In(ny).Zone()
+			require.Equal(t, want, got, d)
+		}
+	}
+	name, _ := time.Date(2024, 7, 1, 0, 0, 0, 0, loc).Zone()
+	assert.Equal(t, "-0400", name)
+}
+
+func TestLoadLocationUntilAndRDate(t *testing.T) {
+	loc := loadLocation(t, "BEGIN:VTIMEZONE\r\n"+
+		"TZID:Custom/East\r\n"+
+		"BEGIN:STANDARD\r\n"+
+		"DTSTART:20101031T030000\r\n"+
+		"TZOFFSETFROM:+0200\r\n"+
+		"TZOFFSETTO:+0100\r\n"+
+		"TZNAME:CET\r\n"+
+		"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10;UNTIL=20111030T010000Z\r\n"+
+		"END:STANDARD\r\n"+
+		"BEGIN:DAYLIGHT\r\n"+
+		"DTSTART:20100328T020000\r\n"+
+		"TZOFFSETFROM:+0100\r\n"+
+		"TZOFFSETTO:+0200\r\n"+
+		"TZNAME:CEST\r\n"+
+		"RDATE:20100328T020000,20110327T020000\r\n"+
+		"RDATE:20120325T020000\r\n"+
+		"END:DAYLIGHT\r\n"+
+		"END:VTIMEZONE\r\n")
+
+	for _, c := range []struct {
+		at     time.Time
+		name   string
+		offset int
+	}{
+		{time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), "CET", 3600},
+		{time.Date(2010, 3, 28, 0, 59, 59, 0, time.UTC), "CET", 3600},
+		{time.Date(2010, 3, 28, 1, 0, 0, 0, time.UTC), "CEST", 7200},
+		{time.Date(2010, 10, 31, 1, 0, 0, 0, time.UTC), "CET", 3600},
+		{time.Date(2011, 7, 1, 0, 0, 0, 0, time.UTC), "CEST", 7200},
+		{time.Date(2011, 10, 30, 1, 0, 0, 0, time.UTC), "CET", 3600},
+		{time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC), "CEST", 7200},
+		// No STANDARD onset follows the last RDATE.
+		{time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), "CEST", 7200},
+	} {
+		name, offset := c.at.In(loc).Zone()
+		assert.Equal(t, c.name, name, c.at)
+		assert.Equal(t, c.offset, offset, c.at)
+	}
+}
+
+func TestLoadLocationFixed(t *testing.T) {
+	loc := loadLocation(t, "BEGIN:VTIMEZONE\r\n"+
+		"TZID:India\r\n"+
+		"BEGIN:STANDARD\r\n"+
+		"DTSTART:16010101T000000\r\n"+
+		"TZOFFSETFROM:+0530\r\n"+
+		"TZOFFSETTO:+0530\r\n"+
+		"END:STANDARD\r\n"+
+		"END:VTIMEZONE\r\n")
+
+	for _, year := range []int{1500, 2024, 2200} {
+		name, offset := time.Date(year, 6, 1, 0, 0, 0, 0, loc).Zone()
+		assert.Equal(t, "+0530", name)
+		assert.Equal(t, 5*3600+30*60, offset)
+	}
+}
+
+func TestLoadLocationErrors(t *testing.T) {
+	for _, text := range []string{
+		"BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+		"BEGIN:VTIMEZONE\r\nBEGIN:STANDARD\r\nDTSTART:16010101T000000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:X\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:X\r\nBEGIN:STANDARD\r\nDTSTART:16010101T000000\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:X\r\nBEGIN:STANDARD\r\nDTSTART:16010101T000000\r\nTZOFFSETFROM:0100\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:X\r\nBEGIN:STANDARD\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:X\r\nBEGIN:STANDARD\r\nDTSTART:16010101T000000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nRRULE:FREQ=SOMETIMES\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n",
+	} {
+		components, err := Decode(strings.NewReader(text))
+		require.NoError(t, err, text)
+		_, err = LoadLocation(components[0])
+		assert.Error(t, err, text)
+	}
+}
+
+func TestReadVTimezone(t *testing.T) {
+	series, err := Read(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		outlookEastern +
+		"BEGIN:VEVENT\r\n" +
+		"UID:weekly@example.com\r\n" +
+		"DTSTART;TZID=Eastern Standard Time:20241028T090000\r\n" +
+		"RRULE:FREQ=WEEKLY;COUNT=2\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:paris@example.com\r\n" +
+		"DTSTART;TZID=Europe/Paris:20241028T090000\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+
+	all := series[0].Recurrence.All()
+	require.Len(t, all, 2)
+	assert.Equal(t, "Eastern Standard Time", all[0].Location().String())
+	assert.Equal(t, time.Date(2024, 10, 28, 13, 0, 0, 0, time.UTC), all[0].UTC())
+	assert.Equal(t, time.Date(2024, 11, 4, 14, 0, 0, 0, time.UTC), all[1].UTC())
+	assert.Equal(t, "Europe/Paris", series[1].Recurrence.GetDTStart().Location().String())
+
+	_, err = Read(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Broken\r\nEND:VTIMEZONE\r\n" +
+		"END:VCALENDAR\r\n"))
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, "VTIMEZONE", e.Component)
+	assert.Equal(t, 2, e.Line)
+}
@@ -0,0 +1,249 @@
+package rrule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIterationLimit is returned, wrapped in a *LimitError, when expanding a
+// recurrence exceeds one of its Limits.
+var ErrIterationLimit = errors.New("rrule: iteration limit exceeded")
+
+// Limits bounds the work done when expanding a recurrence, which matters when
+// rules come from untrusted input: rules such as FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30
+// never produce an occurrence and would otherwise be scanned up to MAXYEAR.
+// A zero field means no limit.
+type Limits struct {
+	// MaxEmptyPeriods is the number of consecutive periods without any
+	// occurrence the rule iterator scans before giving up.
+	MaxEmptyPeriods int
+	// MaxOccurrences is the number of occurrences a single expansion returns.
+	// Only the expansions that report a *LimitError enforce it.
+	MaxOccurrences int
+	// MaxYear is the last year iterated, regardless of UNTIL.
+	MaxYear int
+	// MaxByListSize is the number of values accepted in each BY* rule part.
+	MaxByListSize int
+}
+
+// DefaultLimits is a conservative set of limits for rules from untrusted input.
+// Recurrences have no limits unless they are set explicitly.
+var DefaultLimits = Limits{
+	MaxEmptyPeriods: 1000000,
+	MaxOccurrences:  100000,
+	MaxYear:         2200,
+	MaxByListSize:   1000,
+}
+
+// LimitError reports which limit an expansion or a rule exceeded.
+// It wraps ErrIterationLimit.
+type LimitError struct {
+	Limit string // name of the Limits field
+	Value int    // configured value of the limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rrule: %s limit of %d exceeded", e.Limit, e.Value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrIterationLimit
+}
+
+// checkRule returns a *LimitError if a BY* part of option is longer than
+// MaxByListSize.
+func (l Limits) checkRule(option ROption) error {
+	if l.MaxByListSize <= 0 {
+		return nil
+	}
+	for _, n := range []int{
+		len(option.Bysetpos), len(option.Bymonth) + len(option.BymonthLeap), len(option.Bymonthday), len(option.Byyearday),
+		len(option.Byweekno), len(option.Byweekday), len(option.Byhour), len(option.Byminute),
+		len(option.Bysecond), len(option.Byeaster),
+	} {
+		if n > l.MaxByListSize {
+			return &LimitError{Limit: "MaxByListSize", Value: l.MaxByListSize}
+		}
+	}
+	return nil
+}
+
+// NewWithLimits is like New but checks the rule against limits and enforces
+// them on every expansion of the recurrence.
+func NewWithLimits(option ROption, limits Limits) (*Recurrence, error) {
+	rec, err := New(option)
+	if err != nil {
+		return nil, err
+	}
+	if err := rec.SetLimits(limits); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// ParseWithLimits is like Parse but checks the rule against limits and
+// enforces them on every expansion of the recurrence.
+func ParseWithLimits(limits Limits, lines ...string) (*Recurrence, error) {
+	rec, err := Parse(lines...)
+	if err != nil {
+		return nil, err
+	}
+	if err := rec.SetLimits(limits); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// SetLimits sets the limits enforced when the recurrence is expanded.
+// Iterators, sequences and the slice helpers such as All and Between stop
+// silently when MaxEmptyPeriods or MaxYear is reached and ignore
+// MaxOccurrences; the Context variants such as AllContext report every limit
+// as an error. It returns a *LimitError, and leaves the limits unchanged, if a
+// rule of the set already exceeds MaxByListSize.
+func (set *Recurrence) SetLimits(limits Limits) error {
+	for _, r := range append(set.rules(), set.exrules...) {
+		if err := limits.checkRule(r.ruleOptionFromState()); err != nil {
+			return err
+		}
+	}
+	set.limits = limits
+	return nil
+}
+
+// GetLimits returns the limits enforced when the recurrence is expanded.
+func (set *Recurrence) GetLimits() Limits {
+	return set.limits
+}
+
+// AllWithLimits returns all occurrences of the Recurrence, enforcing limits
+// instead of the limits set on the recurrence. When a limit is reached it
+// returns the occurrences found so far and a *LimitError.
+func (set *Recurrence) AllWithLimits(limits Limits) ([]time.Time, error) {
+	return set.betweenWithLimits(context.Background(), time.Time{}, endOfTime, true, limits)
+}
+
+// BetweenWithLimits is like Between but enforces limits instead of the limits
+// set on the recurrence. MaxOccurrences counts the occurrences inside the
+// window. When a limit is reached it returns the occurrences found so far and
+// a *LimitError.
+func (set *Recurrence) BetweenWithLimits(after, before time.Time, inc bool, limits Limits) ([]time.Time, error) {
+	return set.betweenWithLimits(context.Background(), after, before, inc, limits)
+}
+
+// betweenWithLimits collects the occurrences between after and before,
+// enforcing limits and stopping with an *ExpansionError once ctx is done.
+func (set *Recurrence) betweenWithLimits(ctx context.Context, after, before time.Time, inc bool, limits Limits) ([]time.Time, error) {
+	it := set.limitedIterator(ctx, after, limits)
+	var res []time.Time
+	for dt, ok := it.next(); ok; dt, ok = it.next() {
+		if inc && dt.After(before) || !inc && !dt.Before(before) {
+			return res, nil
+		}
+		if inc && dt.Before(after) || !inc && !dt.After(after) {
+			continue
+		}
+		if limits.MaxOccurrences > 0 && len(res) == limits.MaxOccurrences {
+			return res, &LimitError{Limit: "MaxOccurrences", Value: limits.MaxOccurrences}
+		}
+		res = append(res, dt)
+	}
+	return res, it.stopped(len(res))
+}
+
+// limitedIterator merges the rule and RDATE streams like iteratorFrom, enforcing
+// the rule iterator limits, MaxEmptyPeriods and MaxYear, and ctx. Exclusion
+// rules are merged like EXDATE values.
+type limitedIterator struct {
+	ctx    context.Context
+	set    *setIterator
+	rules  []*limitedRule
+	lastdt time.Time
+	err    error
+}
+
+// limitedRule is one rule stream of a limitedIterator.
+type limitedRule struct {
+	iterator *rIterator
+	done     bool
+}
+
+// limitedIterator returns an iterator that starts near dt. Values before dt may
+// still be yielded and must be filtered by the caller.
+func (set *Recurrence) limitedIterator(ctx context.Context, dt time.Time, limits Limits) *limitedIterator {
+	it := &limitedIterator{ctx: ctx, set: newSetIterator(set.allDay, false)}
+	it.set.rlist.add(timeSliceIterator(set.sortedRDate[searchTimes(set.sortedRDate, dt):]))
+	for _, r := range set.rules() {
+		it.addRule(&it.set.rlist, r, dt, limits)
+	}
+	it.set.exlist.add(timeSliceIterator(set.sortedExDate[searchTimes(set.sortedExDate, dt):]))
+	for _, r := range set.exrules {
+		it.addRule(&it.set.exlist, r, dt, limits)
+	}
+	return it
+}
+
+// addRule merges the values of r into list. A rule stopped by a limit stops
+// the iterator once its values are exhausted, exclusion rules included: the
+// occurrences they would have removed are unknown.
+func (it *limitedIterator) addRule(list *genHeap, r *compiledRule, dt time.Time, limits Limits) {
+	iterator := r.newRuleIterator(dt, limits)
+	if iterator == nil {
+		return
+	}
+	iterator.ctx = it.ctx
+	rule := &limitedRule{iterator: iterator}
+	it.rules = append(it.rules, rule)
+	list.add(func() (time.Time, bool) {
+		v, ok := rule.iterator.next()
+		rule.done = !ok
+		return v, ok
+	})
+}
+
+func (it *limitedIterator) next() (time.Time, bool) {
+	if it.err != nil {
+		return time.Time{}, false
+	}
+	for _, rule := range it.rules {
+		if rule.done && rule.iterator.err != nil {
+			// Occurrences past the point where the rule stopped are unknown.
+			it.err = rule.iterator.err
+			return time.Time{}, false
+		}
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return time.Time{}, false
+	}
+	dt, ok := it.set.next()
+	if ok {
+		it.lastdt = dt
+	}
+	return dt, ok
+}
+
+// stopped returns the error that stopped the iterator, if any, after emitted
+// occurrences were returned to the caller. Context errors are wrapped in an
+// *ExpansionError.
+func (it *limitedIterator) stopped(emitted int) error {
+	err := it.err
+	reached := it.lastdt
+	for _, rule := range it.rules {
+		if rule.iterator.err != nil {
+			if err == nil {
+				err = rule.iterator.err
+			}
+			reached = rule.iterator.position()
+			break
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*LimitError); ok {
+		return err
+	}
+	return &ExpansionError{Err: err, Reached: reached, Emitted: emitted}
+}
@@ -0,0 +1,155 @@
+package rrule
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsEmptyPeriods(t *testing.T) {
+	limits := Limits{MaxEmptyPeriods: 300}
+	for _, lines := range [][]string{
+		{"DTSTART:20240101T090000Z", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"},
+		{"DTSTART:20240101T090000Z", "RRULE:FREQ=MONTHLY;BYMONTHDAY=31;BYMONTH=4,6,9,11"},
+		{"DTSTART:20240101T000000Z", "RRULE:FREQ=SECONDLY;BYMONTH=12;COUNT=10"},
+		// The interval never reaches hour 1, which used to loop forever.
+		{"DTSTART:20240101T000000Z", "RRULE:FREQ=SECONDLY;INTERVAL=86400;BYHOUR=1"},
+		{"DTSTART:20240101T000000Z", "RRULE:FREQ=HOURLY;INTERVAL=24;BYHOUR=1"},
+	} {
+		r, err := Parse(lines...)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", lines, err)
+		}
+		res, err := r.AllWithLimits(limits)
+		if !errors.Is(err, ErrIterationLimit) {
+			t.Errorf("%q: get error %v, want ErrIterationLimit", lines, err)
+		}
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "MaxEmptyPeriods" || limitErr.Value != 300 {
+			t.Errorf("%q: get error %#v, want a MaxEmptyPeriods LimitError", lines, err)
+		}
+		if len(res) != 0 {
+			t.Errorf("%q: get %v, want no occurrence", lines, res)
+		}
+	}
+}
+
+func TestLimitsEmptyPeriodsResetOnOccurrence(t *testing.T) {
+	// February 29 is found every four years, so the counter never exceeds 3.
+	r, _ := Parse("DTSTART:20240229T090000Z", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;UNTIL=20400301T000000Z")
+	res, err := r.AllWithLimits(Limits{MaxEmptyPeriods: 3})
+	if err != nil {
+		t.Fatalf("AllWithLimits failed: %v", err)
+	}
+	if len(res) != 5 {
+		t.Errorf("get %d occurrences, want 5", len(res))
+	}
+}
+
+func TestLimitsMaxOccurrences(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY", "RDATE:20240101T120000Z")
+	res, err := r.AllWithLimits(Limits{MaxOccurrences: 10})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxOccurrences" {
+		t.Fatalf("get error %v, want a MaxOccurrences LimitError", err)
+	}
+	if want := r.Between(r.GetDTStart(), time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC), true); !timesEqual(res, want) {
+		t.Errorf("get %v, want %v", res, want)
+	}
+
+	// Reaching the limit exactly is not an error.
+	r, _ = Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY;COUNT=10")
+	if res, err := r.AllWithLimits(Limits{MaxOccurrences: 10}); err != nil || len(res) != 10 {
+		t.Errorf("get %d occurrences and error %v, want 10 and no error", len(res), err)
+	}
+
+	// The limit counts the occurrences inside the window only.
+	r, _ = Parse("DTSTART:20000101T090000Z", "RRULE:FREQ=DAILY")
+	res, err = r.BetweenWithLimits(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), true, Limits{MaxOccurrences: 30})
+	if err != nil || len(res) != 30 {
+		t.Errorf("get %d occurrences and error %v, want 30 and no error", len(res), err)
+	}
+
+	// Expansions that cannot report the limit ignore it.
+	r, _ = ParseWithLimits(Limits{MaxOccurrences: 10}, "DTSTART:20240101T090000Z", "RRULE:FREQ=HOURLY;BYMINUTE=0,30;COUNT=50")
+	all := r.All()
+	if len(all) != 50 {
+		t.Fatalf("All returned %d occurrences, want 50", len(all))
+	}
+	if n, ok := r.Count(); n != 50 || !ok {
+		t.Errorf("Count returned %d, %v, want 50, true", n, ok)
+	}
+	if dt, ok := r.Nth(20); !ok || !dt.Equal(all[20]) {
+		t.Errorf("Nth(20) returned %v, %v, want %v, true", dt, ok, all[20])
+	}
+	if i, ok := r.IndexOf(all[15]); i != 15 || !ok {
+		t.Errorf("IndexOf(%v) returned %d, %v, want 15, true", all[15], i, ok)
+	}
+	if !r.Contains(all[49]) {
+		t.Errorf("Contains(%v) returned false, want true", all[49])
+	}
+}
+
+func TestLimitsMaxYear(t *testing.T) {
+	r, _ := Parse("DTSTART:20240101T090000Z", "RRULE:FREQ=YEARLY")
+	res, err := r.AllWithLimits(Limits{MaxYear: 2030})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxYear" {
+		t.Fatalf("get error %v, want a MaxYear LimitError", err)
+	}
+	if len(res) != 7 || res[len(res)-1].Year() != 2030 {
+		t.Errorf("get %v, want the occurrences from 2024 to 2030", res)
+	}
+
+	// Windows ending before the horizon do not reach it.
+	res, err = r.BetweenWithLimits(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC), false, Limits{MaxYear: 2030})
+	if err != nil || len(res) != 3 {
+		t.Errorf("get %v and error %v, want 3 occurrences and no error", res, err)
+	}
+}
+
+func TestLimitsMaxByListSize(t *testing.T) {
+	limits := Limits{MaxByListSize: 4}
+	_, err := NewWithLimits(ROption{Freq: DAILY, Byhour: []int{1, 2, 3, 4, 5}, Dtstart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, limits)
+	if !errors.Is(err, ErrIterationLimit) {
+		t.Errorf("NewWithLimits: get error %v, want ErrIterationLimit", err)
+	}
+	_, err = ParseWithLimits(limits, "DTSTART:20240101T000000Z", "RRULE:FREQ=YEARLY;BYYEARDAY="+strings.Repeat("1,", 10)+"2")
+	if !errors.Is(err, ErrIterationLimit) {
+		t.Errorf("ParseWithLimits: get error %v, want ErrIterationLimit", err)
+	}
+	r, err := ParseWithLimits(limits, "DTSTART:20240101T000000Z", "RRULE:FREQ=DAILY;BYHOUR=1,2,3,4")
+	if err != nil {
+		t.Fatalf("ParseWithLimits failed: %v", err)
+	}
+	if r.GetLimits() != limits {
+		t.Errorf("GetLimits: get %v, want %v", r.GetLimits(), limits)
+	}
+	if err := r.SetLimits(Limits{MaxByListSize: 3}); !errors.Is(err, ErrIterationLimit) || r.GetLimits() != limits {
+		t.Errorf("SetLimits: get error %v and limits %v, want ErrIterationLimit and unchanged limits", err, r.GetLimits())
+	}
+}
+
+func TestLimitsOnRecurrence(t *testing.T) {
+	r, err := ParseWithLimits(Limits{MaxEmptyPeriods: 100}, "DTSTART:20240101T090000Z", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	if err != nil {
+		t.Fatalf("ParseWithLimits failed: %v", err)
+	}
+	if res := r.All(); len(res) != 0 {
+		t.Errorf("All: get %v, want no occurrence", res)
+	}
+	if res := r.Before(time.Date(9000, 1, 1, 0, 0, 0, 0, time.UTC), true); !res.IsZero() {
+		t.Errorf("Before: get %v, want zero", res)
+	}
+	if res := r.After(r.GetDTStart(), true); !res.IsZero() {
+		t.Errorf("After: get %v, want zero", res)
+	}
+
+	r, _ = ParseWithLimits(DefaultLimits, "DTSTART:20240101T090000Z", "RRULE:FREQ=WEEKLY;BYDAY=MO,FR")
+	want := r.Between(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true)
+	res, err := r.BetweenWithLimits(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true, DefaultLimits)
+	if err != nil || !timesEqual(res, want) {
+		t.Errorf("BetweenWithLimits: get %d occurrences and error %v, want %d", len(res), err, len(want))
+	}
+}
@@ -0,0 +1,76 @@
+package rrule
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// LocationResolver finds the location named by a TZID parameter.
+type LocationResolver interface {
+	ResolveLocation(tzid string) (*time.Location, error)
+}
+
+// LocationResolverFunc is a function used as a LocationResolver.
+type LocationResolverFunc func(tzid string) (*time.Location, error)
+
+func (f LocationResolverFunc) ResolveLocation(tzid string) (*time.Location, error) {
+	return f(tzid)
+}
+
+// IANALocations resolves IANA time zone names, such as "America/New_York",
+// with time.LoadLocation.
+var IANALocations LocationResolver = LocationResolverFunc(time.LoadLocation)
+
+// WindowsLocations resolves Windows time zone IDs, such as "Eastern Standard
+// Time", to the IANA location CLDR maps them to for the world (territory 001).
+var WindowsLocations LocationResolver = LocationResolverFunc(func(tzid string) (*time.Location, error) {
+	name, ok := windowsZones[tzid]
+	if !ok {
+		return nil, fmt.Errorf("unknown Windows time zone %q", tzid)
+	}
+	return time.LoadLocation(name)
+})
+
+// FixedOffsetLocations resolves the synthetic TZIDs of fixed offsets, such as
+// "UTC+0800" or "UTC-0330", to fixed zones of that name.
+var FixedOffsetLocations LocationResolver = LocationResolverFunc(func(tzid string) (*time.Location, error) {
+	if !fixedOffsetPattern.MatchString(tzid) {
+		return nil, fmt.Errorf("not a fixed offset %q", tzid)
+	}
+	offset, err := parseUTCOffset(tzid[len("UTC"):])
+	if err != nil {
+		return nil, err
+	}
+	return time.FixedZone(tzid, offset), nil
+})
+
+var fixedOffsetPattern = regexp.MustCompile(`^UTC[+-]([01][0-9]|2[0-3])[0-5][0-9]([0-5][0-9])?$`)
+
+// ChainLocationResolvers returns a resolver that tries resolvers in order and
+// returns the first location found. When none is found, it returns the error
+// of the first resolver.
+func ChainLocationResolvers(resolvers ...LocationResolver) LocationResolver {
+	return LocationResolverFunc(func(tzid string) (*time.Location, error) {
+		var first error
+		for _, r := range resolvers {
+			loc, err := r.ResolveLocation(tzid)
+			if err == nil {
+				return loc, nil
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		if first == nil {
+			first = fmt.Errorf("unknown time zone %q", tzid)
+		}
+		return nil, first
+	})
+}
+
+// DefaultLocationResolver finds the locations of the TZID parameters read by
+// StrToDtStart, StrToDatesInLoc, StrToPeriodsInLoc and Parse: IANA names
+// first, then Windows IDs, then fixed offsets. It may be replaced before
+// parsing starts.
+var DefaultLocationResolver = ChainLocationResolvers(IANALocations, WindowsLocations, FixedOffsetLocations)
@@ -0,0 +1,67 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsLocations(t *testing.T) {
+	for id, name := range windowsZones {
+		loc, err := WindowsLocations.ResolveLocation(id)
+		if assert.NoError(t, err, id) {
+			assert.Equal(t, name, loc.String(), id)
+		}
+	}
+
+	_, err := WindowsLocations.ResolveLocation("America/New_York")
+	assert.Error(t, err)
+}
+
+func TestParseWindowsTZID(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+
+	dt, err := StrToDtStart("TZID=Eastern Standard Time:20240310T090000", time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 10, 9, 0, 0, 0, ny), dt)
+
+	s, err := Parse("DTSTART;TZID=\"Eastern Standard Time\":20240309T090000",
+		"RRULE:FREQ=DAILY;COUNT=2",
+		"EXDATE;TZID=Eastern Standard Time:20240309T090000")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2024, 3, 10, 9, 0, 0, 0, ny)}, s.All())
+}
+
+func TestChainLocationResolvers(t *testing.T) {
+	custom := time.FixedZone("Custom", 3*3600)
+	resolver := ChainLocationResolvers(
+		LocationResolverFunc(func(tzid string) (*time.Location, error) {
+			if tzid == "Custom" {
+				return custom, nil
+			}
+			return nil, errors.New("not custom")
+		}),
+		IANALocations,
+	)
+
+	loc, err := resolver.ResolveLocation("Custom")
+	require.NoError(t, err)
+	assert.Equal(t, custom, loc)
+	loc, err = resolver.ResolveLocation("Europe/Paris")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Paris", loc.String())
+	_, err = resolver.ResolveLocation("Nowhere")
+	assert.EqualError(t, err, "not custom")
+	_, err = ChainLocationResolvers().ResolveLocation("UTC")
+	assert.Error(t, err)
+
+	s, err := ParseWithResolver(resolver, "DTSTART;TZID=Custom:20240101T090000", "RRULE:FREQ=DAILY;COUNT=1")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2024, 1, 1, 9, 0, 0, 0, custom)}, s.All())
+
+	_, err = ParseWithResolver(resolver, "DTSTART;TZID=Eastern Standard Time:20240101T090000")
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package rrule
+
+import (
+	"container/heap"
+	"iter"
+	"sort"
+	"time"
+)
+
+// Override replaces one occurrence of a recurrence, like a component with a
+// RECURRENCE-ID in an iCalendar object. The occurrence keeps its identity,
+// the original start, in RecurrenceID.
+type Override struct {
+	RecurrenceID time.Time // original start of the occurrence
+	Start        time.Time // replacement start, zero to keep the original start
+	Duration     *Duration // replacement length, nil to keep the original length
+	// ThisAndFuture applies the override to the later occurrences as well,
+	// like RANGE=THISANDFUTURE: they are shifted by the same amount as the
+	// overridden one and take its duration and payload.
+	ThisAndFuture bool
+	Payload       any // opaque data of the caller, such as the modified event
+}
+
+// shift returns how far the override moves its occurrence.
+func (o Override) shift() time.Duration {
+	if o.Start.IsZero() {
+		return 0
+	}
+	return o.Start.Sub(o.RecurrenceID)
+}
+
+// SetOverride replaces the occurrence at o.RecurrenceID, or updates the
+// override already registered for it. Overrides whose RecurrenceID is not an
+// occurrence of the set are kept but have no effect.
+// Overrides apply to Instances and Overlapping; the other queries return the
+// original occurrences.
+func (set *Recurrence) SetOverride(o Override) {
+	o.RecurrenceID = set.normalizeOverrideTime(o.RecurrenceID)
+	if !o.Start.IsZero() {
+		o.Start = set.normalizeOverrideTime(o.Start)
+	}
+	if o.Duration != nil {
+		d := set.normalizeDuration(*o.Duration)
+		o.Duration = &d
+	}
+	if set.overrides == nil {
+		set.overrides = make(map[int64]Override)
+	}
+	set.overrides[o.RecurrenceID.Unix()] = o
+}
+
+// GetOverride returns the override registered for the occurrence at id.
+func (set *Recurrence) GetOverride(id time.Time) (Override, bool) {
+	o, ok := set.overrides[set.normalizeOverrideTime(id).Unix()]
+	return o, ok
+}
+
+// RemoveOverride restores the occurrence at id.
+func (set *Recurrence) RemoveOverride(id time.Time) {
+	delete(set.overrides, set.normalizeOverrideTime(id).Unix())
+}
+
+// GetOverrides returns the overrides of the set sorted by RecurrenceID.
+func (set *Recurrence) GetOverrides() []Override {
+	overrides := make([]Override, 0, len(set.overrides))
+	for _, o := range set.overrides {
+		overrides = append(overrides, o)
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].RecurrenceID.Before(overrides[j].RecurrenceID)
+	})
+	return overrides
+}
+
+// normalizeOverrides registers the overrides again after the set switched
+// to all-day or floating times.
+func (set *Recurrence) normalizeOverrides() {
+	overrides := set.GetOverrides()
+	clear(set.overrides)
+	for _, o := range overrides {
+		set.SetOverride(o)
+	}
+}
+
+// normalizeOverrideTime truncates t like RDate does.
+func (set *Recurrence) normalizeOverrideTime(t time.Time) time.Time {
+	if set.allDay {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	if set.floating {
+		return wallClock(t)
+	}
+	return t.Truncate(time.Second)
+}
+
+// instancesNear returns a sequence over the instances of the set in order of
+// start, with the overrides applied, for the occurrences from near dt.
+// Overridden instances may start long before or after their occurrence, so
+// they are held back until no later occurrence can start before them.
+func (set *Recurrence) instancesNear(dt time.Time) iter.Seq[Instance] {
+	return func(yield func(Instance) bool) {
+		periods := set.sortedPeriods()
+		if len(set.overrides) == 0 {
+			for id := range set.occurrencesNear(dt) {
+				if !yield(set.instanceAt(id, periods)) {
+					return
+				}
+			}
+			return
+		}
+
+		var ranges []Override
+		var minShift time.Duration
+		for _, o := range set.GetOverrides() {
+			minShift = min(minShift, o.shift())
+			if o.ThisAndFuture {
+				ranges = append(ranges, o)
+			}
+		}
+
+		var pending instanceHeap
+		var current *Override // the THISANDFUTURE override in effect
+		for id := range set.occurrencesNear(dt) {
+			// No instance from id on can start before id+minShift.
+			for len(pending) > 0 && !pending[0].Start.After(id.Add(minShift)) {
+				if !yield(heap.Pop(&pending).(Instance)) {
+					return
+				}
+			}
+			for len(ranges) > 0 && !ranges[0].RecurrenceID.After(id) {
+				current = &ranges[0]
+				ranges = ranges[1:]
+			}
+			instance := set.instanceAt(id, periods)
+			if o, ok := set.overrides[id.Unix()]; ok {
+				instance = o.apply(instance, o.Start)
+			} else if current != nil {
+				instance = current.apply(instance, id.Add(current.shift()))
+			}
+			heap.Push(&pending, instance)
+		}
+		for len(pending) > 0 {
+			if !yield(heap.Pop(&pending).(Instance)) {
+				return
+			}
+		}
+	}
+}
+
+// apply returns instance moved to start, with the duration and payload of o.
+func (o *Override) apply(instance Instance, start time.Time) Instance {
+	if !start.IsZero() {
+		instance.Start = start
+	}
+	if o.Duration != nil {
+		instance.Duration = o.Duration.AddTo(instance.Start).Sub(instance.Start)
+	}
+	instance.Override = o
+	return instance
+}
+
+// maxOverrideSpan returns how far after its occurrence an overridden instance
+// may end, beyond the length of the occurrence itself.
+func (set *Recurrence) maxOverrideSpan() time.Duration {
+	var span time.Duration
+	for _, o := range set.overrides {
+		span = max(span, o.shift())
+		if o.Duration != nil {
+			span = max(span, o.shift()+time.Duration(o.Duration.Days)*25*time.Hour+o.Duration.Clock)
+		}
+	}
+	return span
+}
+
+// instanceHeap orders instances by start, then by RecurrenceID.
+type instanceHeap []Instance
+
+func (h instanceHeap) Len() int { return len(h) }
+func (h instanceHeap) Less(i, j int) bool {
+	if h[i].Start.Equal(h[j].Start) {
+		return h[i].RecurrenceID.Before(h[j].RecurrenceID)
+	}
+	return h[i].Start.Before(h[j].Start)
+}
+func (h instanceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *instanceHeap) Push(x any)   { *h = append(*h, x.(Instance)) }
+func (h *instanceHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,132 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetOverride(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY, Count: 4, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	r.SetDuration(Duration{Clock: time.Hour})
+	twoHours := Duration{Clock: 2 * time.Hour}
+	// The second meeting moves after the third one and lasts two hours.
+	r.SetOverride(Override{
+		RecurrenceID: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		Start:        time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC),
+		Duration:     &twoHours,
+		Payload:      "moved",
+	})
+	// The fourth one keeps its start but carries a payload.
+	r.SetOverride(Override{RecurrenceID: time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC), Payload: "renamed"})
+
+	want := []Instance{
+		{Start: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Duration: time.Hour, RecurrenceID: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), Duration: time.Hour, RecurrenceID: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC), Duration: 2 * time.Hour, RecurrenceID: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC), Duration: time.Hour, RecurrenceID: time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC)},
+	}
+	wantPayload := []any{nil, nil, "moved", "renamed"}
+	var got []Instance
+	for instance := range r.Instances() {
+		got = append(got, instance)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Instances: get %v, want %v", got, want)
+	}
+	for i := range got {
+		if !got[i].Start.Equal(want[i].Start) || got[i].Duration != want[i].Duration || !got[i].RecurrenceID.Equal(want[i].RecurrenceID) {
+			t.Errorf("Instances[%d]: get %+v, want %+v", i, got[i], want[i])
+		}
+		var payload any
+		if got[i].Override != nil {
+			payload = got[i].Override.Payload
+		}
+		if payload != wantPayload[i] {
+			t.Errorf("Instances[%d]: get payload %v, want %v", i, payload, wantPayload[i])
+		}
+	}
+
+	// The original occurrences are unchanged.
+	if !r.Contains(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Contains: get false, want true")
+	}
+	overlapping := r.Overlapping(time.Date(2024, 1, 3, 16, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 17, 0, 0, 0, time.UTC))
+	if len(overlapping) != 1 || !overlapping[0].End.Equal(time.Date(2024, 1, 3, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("Overlapping: get %v, want the moved meeting", overlapping)
+	}
+
+	if o, ok := r.GetOverride(time.Date(2024, 1, 2, 9, 0, 0, 500, time.UTC)); !ok || o.Payload != "moved" {
+		t.Errorf("GetOverride: get %+v, %v", o, ok)
+	}
+	r.RemoveOverride(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	if overrides := r.GetOverrides(); len(overrides) != 1 || overrides[0].Payload != "renamed" {
+		t.Errorf("GetOverrides: get %+v, want the fourth one", overrides)
+	}
+}
+
+func TestSetOverrideThisAndFuture(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: WEEKLY, Count: 5, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	// From the third week on, the meeting is held a day earlier at 15:00.
+	r.SetOverride(Override{
+		RecurrenceID:  time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		Start:         time.Date(2024, 1, 14, 15, 0, 0, 0, time.UTC),
+		ThisAndFuture: true,
+		Payload:       "series",
+	})
+	// A single override still wins over the range.
+	r.SetOverride(Override{
+		RecurrenceID: time.Date(2024, 1, 22, 9, 0, 0, 0, time.UTC),
+		Start:        time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC),
+	})
+
+	wantStart := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 14, 15, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 28, 15, 0, 0, 0, time.UTC),
+	}
+	wantID := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 22, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 29, 9, 0, 0, 0, time.UTC),
+	}
+	var starts, ids []time.Time
+	for instance := range r.Instances() {
+		starts = append(starts, instance.Start)
+		ids = append(ids, instance.RecurrenceID)
+		if instance.RecurrenceID.Equal(wantID[4]) && (instance.Override == nil || instance.Override.Payload != "series") {
+			t.Errorf("Instances: get %+v, want the range override", instance)
+		}
+	}
+	if !timesEqual(starts, wantStart) {
+		t.Errorf("Instances: get starts %v, want %v", starts, wantStart)
+	}
+	if !timesEqual(ids, wantID) {
+		t.Errorf("Instances: get IDs %v, want %v", ids, wantID)
+	}
+}
+
+func TestSetOverrideUnbounded(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	r.SetOverride(Override{
+		RecurrenceID: time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC),
+		Start:        time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	})
+	var starts []time.Time
+	for instance := range r.Instances() {
+		if starts = append(starts, instance.Start); len(starts) == 3 {
+			break
+		}
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+	}
+	if !timesEqual(starts, want) {
+		t.Errorf("Instances: get %v, want %v", starts, want)
+	}
+}
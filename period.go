@@ -0,0 +1,313 @@
+package rrule
+
+import (
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period is a span of time given by an RDATE;VALUE=PERIOD value.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the period.
+func (p Period) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// Duration is an RFC 5545 DURATION value. Days are nominal: adding them keeps
+// the wall clock across daylight saving time changes, while Clock is exact.
+type Duration struct {
+	Days  int
+	Clock time.Duration
+}
+
+// AddTo returns t moved forward by d.
+func (d Duration) AddTo(t time.Time) time.Time {
+	return t.AddDate(0, 0, d.Days).Add(d.Clock)
+}
+
+// IsZero reports whether d has no length.
+func (d Duration) IsZero() bool {
+	return d.Days == 0 && d.Clock == 0
+}
+
+// String returns d in the RFC 5545 DURATION format, e.g. "P1DT2H".
+func (d Duration) String() string {
+	var b strings.Builder
+	days, clock := d.Days, d.Clock
+	if days < 0 || days == 0 && clock < 0 {
+		b.WriteByte('-')
+		days, clock = -days, -clock
+	}
+	b.WriteByte('P')
+	if days != 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if clock != 0 || days == 0 {
+		b.WriteByte('T')
+		hours := clock / time.Hour
+		minutes := clock % time.Hour / time.Minute
+		seconds := clock % time.Minute / time.Second
+		if hours != 0 {
+			fmt.Fprintf(&b, "%dH", hours)
+		}
+		if minutes != 0 {
+			fmt.Fprintf(&b, "%dM", minutes)
+		}
+		if seconds != 0 || hours == 0 && minutes == 0 {
+			fmt.Fprintf(&b, "%dS", seconds)
+		}
+	}
+	return b.String()
+}
+
+// Instance is an occurrence of a recurrence set together with its length.
+type Instance struct {
+	Start        time.Time
+	Duration     time.Duration // zero when the occurrence has no length
+	RecurrenceID time.Time     // original start of the occurrence, which identifies it
+	Override     *Override     // the override applied to the occurrence, if any
+}
+
+// End returns the end of the instance.
+func (i Instance) End() time.Time {
+	return i.Start.Add(i.Duration)
+}
+
+// StrToPeriodsInLoc parses an RDATE;VALUE=PERIOD property without the RDATE;
+// part. Accepts string with format: "VALUE=PERIOD;[TZID=...]:{period},...,{period}"
+// where each period is either "{start}/{end}" or "{start}/{duration}".
+// Dates without a time zone are parsed in defaultLoc.
+func StrToPeriodsInLoc(str string, defaultLoc *time.Location) ([]Period, error) {
+	l, err := lexPropertyTail("RDATE", str)
+	if err != nil {
+		return nil, err
+	}
+	return parsePeriods(l, defaultLoc, nil)
+}
+
+// parsePeriods parses the values of an RDATE;VALUE=PERIOD line.
+func parsePeriods(l ContentLine, defaultLoc *time.Location, resolver LocationResolver) ([]Period, error) {
+	value, loc, err := timeParams(l, defaultLoc, resolver, "PERIOD")
+	if err == nil && value == "" {
+		err = fmt.Errorf("missing VALUE=PERIOD")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad period param: %s", err.Error())
+	}
+
+	var periods []Period
+	for _, value := range strings.Split(strings.ToUpper(l.Value), ",") {
+		startStr, endStr, ok := strings.Cut(value, "/")
+		if !ok {
+			return nil, fmt.Errorf("bad period %q", value)
+		}
+		start, err := strToTimeInLoc(startStr, loc)
+		if err != nil {
+			return nil, fmt.Errorf("strToTime failed: %v", err)
+		}
+		var end time.Time
+		if strings.HasPrefix(endStr, "P") || strings.HasPrefix(endStr, "+P") || strings.HasPrefix(endStr, "-P") {
+			d, err := StrToDuration(endStr)
+			if err != nil {
+				return nil, fmt.Errorf("StrToDuration failed: %v", err)
+			}
+			end = d.AddTo(start)
+		} else if end, err = strToTimeInLoc(endStr, loc); err != nil {
+			return nil, fmt.Errorf("strToTime failed: %v", err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("period %q ends before it starts", value)
+		}
+		periods = append(periods, Period{Start: start, End: end})
+	}
+	return periods, nil
+}
+
+// RDatePeriod includes the start of p in the recurrence set generation, with
+// the length of p. It will be truncated to second precision.
+func (set *Recurrence) RDatePeriod(p Period) {
+	p = set.normalizePeriod(p)
+	set.rperiod = append(set.rperiod, p)
+	set.sortedRDate = insertSorted(set.sortedRDate, p.Start)
+}
+
+// GetRDatePeriods returns the RDATE periods of the set.
+func (set *Recurrence) GetRDatePeriods() []Period {
+	return set.rperiod
+}
+
+// normalizePeriod truncates p like RDate does.
+func (set *Recurrence) normalizePeriod(p Period) Period {
+	if set.allDay {
+		year, month, day := p.Start.Date()
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		year, month, day = p.End.Date()
+		return Period{Start: start, End: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+	}
+	if set.floating {
+		return Period{Start: wallClock(p.Start), End: wallClock(p.End)}
+	}
+	return Period{Start: p.Start.Truncate(time.Second), End: p.End.Truncate(time.Second)}
+}
+
+// SetDuration sets the length of the occurrences of the set, like a DURATION
+// property. Occurrences given by an RDATE period keep the length of the
+// period. For all-day sets the time part is rounded up to whole days.
+// Negative durations are treated as zero.
+func (set *Recurrence) SetDuration(d Duration) {
+	set.duration = set.normalizeDuration(d)
+}
+
+// SetDTEnd sets the length of the occurrences of the set from the end of the
+// first one, like a DTEND property. All-day sets last a number of calendar
+// days; timed sets last the exact time between DTSTART and dtend.
+// DTSTART must be set first.
+func (set *Recurrence) SetDTEnd(dtend time.Time) {
+	if set.dtstart.IsZero() {
+		return
+	}
+	if set.allDay {
+		year, month, day := dtend.Date()
+		end := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		set.SetDuration(Duration{Days: int(end.Sub(set.dtstart) / (24 * time.Hour))})
+		return
+	}
+	if set.floating {
+		dtend = wallClock(dtend)
+	}
+	set.SetDuration(Duration{Clock: dtend.Truncate(time.Second).Sub(set.dtstart)})
+}
+
+// GetDuration returns the length of the occurrences of the set.
+func (set *Recurrence) GetDuration() Duration {
+	return set.duration
+}
+
+// normalizeDuration rounds d to whole days for all-day sets.
+func (set *Recurrence) normalizeDuration(d Duration) Duration {
+	if d.Days < 0 || d.Clock < 0 {
+		return Duration{}
+	}
+	if set.allDay && d.Clock > 0 {
+		const day = 24 * time.Hour
+		d.Days += int((d.Clock + day - 1) / day)
+		d.Clock = 0
+	}
+	return d
+}
+
+// DurationString returns the length of the occurrences serialized as a
+// DURATION line, or "" if it is zero.
+// Example: DURATION:P1DT2H
+func (set *Recurrence) DurationString() string {
+	if set.duration.IsZero() {
+		return ""
+	}
+	return "DURATION:" + set.duration.String()
+}
+
+// Instances returns a sequence over all occurrences of the Recurrence with
+// their length, in order of start. Occurrences given by an RDATE period have
+// the length of the period; when several periods start at the same time, the
+// longest is used. Other occurrences have the length set by SetDuration or
+// SetDTEnd. Overrides set by SetOverride are applied.
+func (set *Recurrence) Instances() iter.Seq[Instance] {
+	return set.instancesNear(time.Time{})
+}
+
+// Overlapping returns the occurrences of the set, as [start, end) periods,
+// that intersect the window [start, end). Unlike Between, it includes the
+// occurrences that started before the window and are still running in it.
+// Occurrences without length are included when they start in the window.
+// Overrides set by SetOverride are applied.
+func (set *Recurrence) Overlapping(start, end time.Time) []Period {
+	// The longest occurrence bounds how far before the window to look.
+	// Nominal days may last 25 hours across a daylight saving time change.
+	span := time.Duration(set.duration.Days)*25*time.Hour + set.duration.Clock
+	for _, p := range set.rperiod {
+		span = max(span, p.Duration())
+	}
+	span += set.maxOverrideSpan()
+
+	var res []Period
+	for instance := range set.instancesNear(start.Add(-span)) {
+		if !instance.Start.Before(end) {
+			break
+		}
+		if instance.End().After(start) || instance.Duration == 0 && !instance.Start.Before(start) {
+			res = append(res, Period{Start: instance.Start, End: instance.End()})
+		}
+	}
+	return res
+}
+
+// instanceAt returns the occurrence at dt with its length, looked up in the
+// RDATE periods sorted by start.
+func (set *Recurrence) instanceAt(dt time.Time, periods []Period) Instance {
+	i := sort.Search(len(periods), func(i int) bool { return !periods[i].Start.Before(dt) })
+	if i == len(periods) || !periods[i].Start.Equal(dt) {
+		return Instance{Start: dt, Duration: set.duration.AddTo(dt).Sub(dt), RecurrenceID: dt}
+	}
+	instance := Instance{Start: dt, RecurrenceID: dt}
+	for ; i < len(periods) && periods[i].Start.Equal(dt); i++ {
+		instance.Duration = max(instance.Duration, periods[i].Duration())
+	}
+	return instance
+}
+
+// sortedPeriods returns the RDATE periods sorted by start.
+func (set *Recurrence) sortedPeriods() []Period {
+	periods := make([]Period, len(set.rperiod))
+	copy(periods, set.rperiod)
+	sort.SliceStable(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods
+}
+
+// rdatePeriodString returns the RDATE periods serialized as RDATE;VALUE=PERIOD
+// lines grouped by timezone, with an explicit end. Floating periods have
+// neither TZID nor a trailing Z.
+// Example: RDATE;VALUE=PERIOD:19960403T020000Z/19960403T040000Z
+// Example: RDATE;VALUE=PERIOD;TZID=Asia/Shanghai:20240301T090000/20240301T110000
+func (set *Recurrence) rdatePeriodString(w *tzidWriter) string {
+	if len(set.rperiod) == 0 {
+		return ""
+	}
+	valuesByTZID := make(map[string][]string)
+	var tzidOrder []string
+	for _, p := range set.rperiod {
+		// Floating periods are grouped under "Z", which no TZID holds.
+		tzid := "Z"
+		if !set.floating {
+			tzid = w.tzid(p.Start)
+		}
+		if _, ok := valuesByTZID[tzid]; !ok {
+			tzidOrder = append(tzidOrder, tzid)
+		}
+		var value string
+		if tzid == "Z" {
+			value = p.Start.Format(LocalDateTimeFormat) + "/" + p.End.Format(LocalDateTimeFormat)
+		} else if tzid == "" {
+			value = timeToUTCStr(p.Start) + "/" + timeToUTCStr(p.End)
+		} else {
+			value = p.Start.Format(LocalDateTimeFormat) + "/" + p.End.In(p.Start.Location()).Format(LocalDateTimeFormat)
+		}
+		valuesByTZID[tzid] = append(valuesByTZID[tzid], value)
+	}
+
+	lines := make([]string, 0, len(valuesByTZID))
+	for _, tzid := range tzidOrder {
+		values := strings.Join(valuesByTZID[tzid], ",")
+		if tzid == "" || tzid == "Z" {
+			lines = append(lines, fmt.Sprintf("RDATE;VALUE=PERIOD:%s", values))
+		} else {
+			lines = append(lines, fmt.Sprintf("RDATE;VALUE=PERIOD%s:%s", tzidParam(tzid), values))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,225 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStrToPeriodsInLoc(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	newYork, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		str  string
+		want []Period
+	}{
+		{"VALUE=PERIOD:19960403T020000Z/19960403T040000Z", []Period{
+			{time.Date(1996, 4, 3, 2, 0, 0, 0, time.UTC), time.Date(1996, 4, 3, 4, 0, 0, 0, time.UTC)},
+		}},
+		{"VALUE=PERIOD:19960404T010000Z/PT3H,19960405T010000Z/P1DT30M", []Period{
+			{time.Date(1996, 4, 4, 1, 0, 0, 0, time.UTC), time.Date(1996, 4, 4, 4, 0, 0, 0, time.UTC)},
+			{time.Date(1996, 4, 5, 1, 0, 0, 0, time.UTC), time.Date(1996, 4, 6, 1, 30, 0, 0, time.UTC)},
+		}},
+		{"VALUE=PERIOD;TZID=Asia/Shanghai:20240301T090000/20240301T110000", []Period{
+			{time.Date(2024, 3, 1, 9, 0, 0, 0, shanghai), time.Date(2024, 3, 1, 11, 0, 0, 0, shanghai)},
+		}},
+		// Days are nominal: the period keeps its wall-clock end across DST.
+		{"VALUE=PERIOD;TZID=America/New_York:20240309T090000/P1D", []Period{
+			{time.Date(2024, 3, 9, 9, 0, 0, 0, newYork), time.Date(2024, 3, 10, 9, 0, 0, 0, newYork)},
+		}},
+	}
+	for _, c := range cases {
+		periods, err := StrToPeriodsInLoc(c.str, time.UTC)
+		if err != nil {
+			t.Errorf("StrToPeriodsInLoc(%q) failed: %v", c.str, err)
+			continue
+		}
+		if len(periods) != len(c.want) {
+			t.Errorf("StrToPeriodsInLoc(%q) = %v, want %v", c.str, periods, c.want)
+			continue
+		}
+		for i := range periods {
+			if !periods[i].Start.Equal(c.want[i].Start) || !periods[i].End.Equal(c.want[i].End) {
+				t.Errorf("StrToPeriodsInLoc(%q) = %v, want %v", c.str, periods, c.want)
+			}
+		}
+	}
+
+	for _, str := range []string{
+		"19960403T020000Z/19960403T040000Z",
+		"VALUE=DATE-TIME:19960403T020000Z/19960403T040000Z",
+		"VALUE=PERIOD:19960403T020000Z",
+		"VALUE=PERIOD:19960403T020000Z/PT",
+		"VALUE=PERIOD:19960403T040000Z/19960403T020000Z",
+	} {
+		if _, err := StrToPeriodsInLoc(str, time.UTC); err == nil {
+			t.Errorf("StrToPeriodsInLoc(%q) err = nil, want not nil", str)
+		}
+	}
+}
+
+func TestRDatePeriod(t *testing.T) {
+	r, err := Parse(
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"RDATE;VALUE=PERIOD:20240102T090000Z/PT2H,20240105T130000Z/20240105T170000Z",
+		"RDATE:20240106T090000Z",
+	)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Instance{
+		{Start: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Duration: 0},
+		{Start: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), Duration: 2 * time.Hour},
+		{Start: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), Duration: 0},
+		{Start: time.Date(2024, 1, 5, 13, 0, 0, 0, time.UTC), Duration: 4 * time.Hour},
+		{Start: time.Date(2024, 1, 6, 9, 0, 0, 0, time.UTC), Duration: 0},
+	}
+	var got []Instance
+	for instance := range r.Instances() {
+		got = append(got, instance)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Instances: get %v, want %v", got, want)
+	}
+	for i := range got {
+		if !got[i].Start.Equal(want[i].Start) || got[i].Duration != want[i].Duration {
+			t.Errorf("Instances[%d]: get %v, want %v", i, got[i], want[i])
+		}
+	}
+	if end := got[3].End(); !end.Equal(time.Date(2024, 1, 5, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("End: get %v", end)
+	}
+	if len(r.GetRDate()) != 1 || len(r.GetRDatePeriods()) != 2 {
+		t.Errorf("get %v and %v, want 1 RDATE and 2 periods", r.GetRDate(), r.GetRDatePeriods())
+	}
+	if !r.Contains(want[3].Start) {
+		t.Errorf("Contains(%v): get false, want true", want[3].Start)
+	}
+
+	wantStr := `DTSTART:20240101T090000Z
+RRULE:FREQ=DAILY;COUNT=3
+RDATE:20240106T090000Z
+RDATE;VALUE=PERIOD:20240102T090000Z/20240102T110000Z,20240105T130000Z/20240105T170000Z`
+	if r.String() != wantStr {
+		t.Errorf("String: get\n%s\nwant\n%s", r.String(), wantStr)
+	}
+	if parsed, err := StrToRRuleSet(r.String()); err != nil || parsed.String() != wantStr {
+		t.Errorf("round trip: get %v and error %v, want\n%s", parsed, err, wantStr)
+	}
+}
+
+func TestRDatePeriodTZID(t *testing.T) {
+	r := &Recurrence{}
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	r.DTStart(time.Date(2024, 3, 1, 9, 0, 0, 0, shanghai))
+	r.RDatePeriod(Period{Start: time.Date(2024, 3, 1, 9, 0, 0, 0, shanghai), End: time.Date(2024, 3, 1, 10, 30, 0, 0, shanghai)})
+	want := "RDATE;VALUE=PERIOD;TZID=Asia/Shanghai:20240301T090000/20240301T103000"
+	if value := r.RDateString(); value != want {
+		t.Errorf("get %s, want %s", value, want)
+	}
+	if value := r.All(); len(value) != 1 || !value[0].Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, shanghai)) {
+		t.Errorf("All: get %v", value)
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	cases := []struct {
+		d    Duration
+		want string
+	}{
+		{Duration{}, "PT0S"},
+		{Duration{Days: 1, Clock: 2 * time.Hour}, "P1DT2H"},
+		{Duration{Days: 14}, "P14D"},
+		{Duration{Clock: 90 * time.Minute}, "PT1H30M"},
+		{Duration{Clock: 61 * time.Second}, "PT1M1S"},
+		{Duration{Clock: -15 * time.Minute}, "-PT15M"},
+	}
+	for _, c := range cases {
+		if got := c.d.String(); got != c.want {
+			t.Errorf("%+v.String() = %q, want %q", c.d, got, c.want)
+		}
+		if d, err := StrToDuration(c.want); err != nil || d != c.d {
+			t.Errorf("StrToDuration(%q) = %+v, %v, want %+v", c.want, d, err, c.d)
+		}
+	}
+}
+
+func TestSetDuration(t *testing.T) {
+	r, err := Parse(
+		"DTSTART:20240101T220000Z",
+		"DTEND:20240102T013000Z",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"RDATE;VALUE=PERIOD:20240110T080000Z/PT30M",
+	)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if d := r.GetDuration(); d != (Duration{Clock: 3*time.Hour + 30*time.Minute}) {
+		t.Errorf("GetDuration: get %+v, want 3h30m", d)
+	}
+	want := "DTSTART:20240101T220000Z\nDURATION:PT3H30M\nRRULE:FREQ=DAILY;COUNT=3\nRDATE;VALUE=PERIOD:20240110T080000Z/20240110T083000Z"
+	if r.String() != want {
+		t.Errorf("String: get %q, want %q", r.String(), want)
+	}
+
+	// The occurrence of January 2 started before the window and is still running.
+	got := r.Overlapping(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 10, 8, 10, 0, 0, time.UTC))
+	wantPeriods := []Period{
+		{time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 1, 30, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 3, 22, 0, 0, 0, time.UTC), time.Date(2024, 1, 4, 1, 30, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC), time.Date(2024, 1, 10, 8, 30, 0, 0, time.UTC)},
+	}
+	if len(got) != len(wantPeriods) {
+		t.Fatalf("Overlapping: get %v, want %v", got, wantPeriods)
+	}
+	for i := range got {
+		if !got[i].Start.Equal(wantPeriods[i].Start) || !got[i].End.Equal(wantPeriods[i].End) {
+			t.Errorf("Overlapping: get %v, want %v", got, wantPeriods)
+		}
+	}
+	// The window is half-open: an occurrence ending at its start is not included.
+	if got := r.Overlapping(time.Date(2024, 1, 2, 1, 30, 0, 0, time.UTC), time.Date(2024, 1, 2, 22, 0, 0, 0, time.UTC)); len(got) != 0 {
+		t.Errorf("Overlapping: get %v, want none", got)
+	}
+
+	for _, lines := range [][]string{
+		{"RRULE:FREQ=DAILY;COUNT=3", "DTEND:20240102T013000Z"},
+		{"DTSTART:20240101T220000Z", "DTEND:20240101T210000Z"},
+		{"DTSTART:20240101T220000Z", "DURATION:-PT1H"},
+		{"DTSTART:20240101T220000Z", "DURATION:1H"},
+	} {
+		if _, err := Parse(lines...); err == nil {
+			t.Errorf("Parse(%q) err = nil, want not nil", lines)
+		}
+	}
+}
+
+func TestSetDurationAllDay(t *testing.T) {
+	r, err := Parse("DTSTART;VALUE=DATE:20240308", "DTEND;VALUE=DATE:20240310", "RRULE:FREQ=WEEKLY;COUNT=2")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if d := r.GetDuration(); d != (Duration{Days: 2}) {
+		t.Errorf("GetDuration: get %+v, want 2 days", d)
+	}
+	got := r.Overlapping(time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC))
+	if len(got) != 2 || !got[0].End.Equal(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Overlapping: get %v, want the two occurrences", got)
+	}
+
+	// Durations of all-day sets are rounded up to calendar days.
+	r.SetDuration(Duration{Clock: 25 * time.Hour})
+	if d := r.GetDuration(); d != (Duration{Days: 2}) {
+		t.Errorf("SetDuration: get %+v, want 2 days", d)
+	}
+
+	// Timed durations keep the wall clock across DST.
+	newYork, _ := time.LoadLocation("America/New_York")
+	timed, _ := newRecurrence(ROption{Freq: DAILY, Count: 1, Dtstart: time.Date(2024, 3, 9, 9, 0, 0, 0, newYork)})
+	timed.SetDuration(Duration{Days: 1})
+	for instance := range timed.Instances() {
+		if instance.Duration != 23*time.Hour {
+			t.Errorf("Instances: get %v, want 23h", instance.Duration)
+		}
+	}
+}
@@ -3,8 +3,10 @@
 package rrule
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
+	"iter"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +15,22 @@ import (
 
 // Recurrence allows more complex recurrence setups, mixing multiple rules, dates, exclusion rules, and exclusion dates
 type Recurrence struct {
+	compiledRule                 // the first RRULE, which also holds DTSTART and the all-day flag
+	extraRules   []*compiledRule // further RRULEs, sharing DTSTART
+	exrules      []*compiledRule // EXRULEs, sharing DTSTART
+	rdate        []time.Time
+	rperiod      []Period // RDATE PERIOD values, whose starts are merged into sortedRDate
+	exdate       []time.Time
+	duration     Duration           // length of the occurrences, from DTEND or DURATION
+	overrides    map[int64]Override // by RECURRENCE-ID, in Unix seconds
+	sortedRDate  []time.Time        // rdate in ascending order, kept in sync by the mutators
+	sortedExDate []time.Time        // exdate in ascending order, kept in sync by the mutators
+	limits       Limits
+	tzidPolicy   TZIDPolicy
+}
+
+// compiledRule is an RRULE expanded into the BY* sets used by the iterator.
+type compiledRule struct {
 	freq                    Frequency
 	dtstart                 time.Time
 	interval                int
@@ -21,6 +39,7 @@ type Recurrence struct {
 	until                   time.Time
 	bysetpos                []int
 	bymonth                 []int
+	bymonthleap             []int
 	bymonthday, bynmonthday []int
 	byyearday               []int
 	byweekno                []int
@@ -30,11 +49,12 @@ type Recurrence struct {
 	byminute                []int
 	bysecond                []int
 	byeaster                []int
+	skip                    Skip
+	rscale                  string
+	calendar                Calendar // nil for the Gregorian calendar
 	timeset                 []time.Time
-	len                     int
-	rdate                   []time.Time
-	exdate                  []time.Time
 	allDay                  bool
+	floating                bool
 	intervalExplicit        bool
 	bymonthExplicit         bool
 	bymonthdayExplicit      bool
@@ -65,165 +85,212 @@ func NewWithDTStart(dtstart time.Time, allDay bool, lines ...string) (*Recurrenc
 	rec.SetAllDay(allDay)
 	rec.DTStart(dtstart)
 
-	if len(lines) == 0 {
-		return rec, nil
-	}
-
-	normalized, err := NormalizeRecurrenceRuleset(lines)
+	contentLines, err := readContentLines(lines)
 	if err != nil {
 		return nil, err
 	}
-	if len(normalized) == 0 {
-		return rec, nil
-	}
-
-	filtered := make([]string, 0, len(normalized))
-	for _, line := range normalized {
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "DTSTART") {
-			continue
-		}
-		filtered = append(filtered, line)
-	}
-	if len(filtered) == 0 {
-		return rec, nil
-	}
 
 	defaultLoc := time.UTC
+	var dtstartLine *ContentLine
 	if !rec.GetDTStart().IsZero() {
 		defaultLoc = rec.GetDTStart().Location()
-	}
-
-	var dtstartLineForRRULE string
-	if !rec.GetDTStart().IsZero() {
+		var str string
 		if rec.allDay {
-			dtstartLineForRRULE = fmt.Sprintf("DTSTART;VALUE=DATE:%s", rec.GetDTStart().Format(DateFormat))
+			str = fmt.Sprintf("DTSTART;VALUE=DATE:%s", rec.GetDTStart().Format(DateFormat))
 		} else {
-			dtstartLineForRRULE = fmt.Sprintf("DTSTART%s", timeToRFCDatetimeStr(rec.GetDTStart()))
+			str = fmt.Sprintf("DTSTART%s", timeToRFCDatetimeStr(rec.GetDTStart()))
 		}
-	}
-
-	for _, line := range filtered {
-		name, err := processRRuleName(line)
+		l, err := ParseContentLine(str)
 		if err != nil {
 			return nil, err
 		}
-		rule := line[len(name)+1:]
-
-		switch name {
-		case "RRULE":
-			rruleInput := line
-			if dtstartLineForRRULE != "" {
-				rruleInput = dtstartLineForRRULE + "\n" + line
-			}
-			rOpt, err := parseROptionFromString(rruleInput)
-			if err != nil {
-				return nil, fmt.Errorf("parseROption failed: %v", err)
-			}
-			err = rec.setRuleOptions(*rOpt)
-			if err != nil {
-				return nil, fmt.Errorf("NewRRule failed: %v", err)
-			}
-		case "RDATE", "EXDATE":
-			if !rec.allDay && containsValueDateParam(rule) {
-				rec.SetAllDay(true)
-			}
-
-			ts, err := StrToDatesInLoc(rule, defaultLoc)
-			if err != nil {
-				return nil, fmt.Errorf("strToDates failed: %v", err)
-			}
-			for _, t := range ts {
-				if name == "RDATE" {
-					rec.RDate(t)
-				} else {
-					rec.ExDate(t)
-				}
-			}
-		}
+		dtstartLine = &l
 	}
 
+	if err := rec.addContentLines(contentLines, dtstartLine, defaultLoc, nil); err != nil {
+		return nil, err
+	}
 	return rec, nil
 }
 
-// Parse builds a recurrence from lines.
-// Returns an error when lines are malformed; returns an empty Recurrence when
-// lines are empty or normalize to no usable rules.
+// Parse builds a recurrence from lines. Each line may hold several content
+// lines, folded or not; names are case-insensitive and parameters may come
+// in any order. A leading DTSTART line sets the start of the recurrence.
+// Returns an error, a *SyntaxError locating the offending line, when lines
+// are malformed; returns an empty Recurrence when lines are empty or
+// normalize to no usable rules.
 func Parse(lines ...string) (*Recurrence, error) {
-	if len(lines) == 0 {
-		return &Recurrence{}, nil
-	}
+	return ParseWithResolver(nil, lines...)
+}
 
-	normalized, err := NormalizeRecurrenceRuleset(lines)
+// ParseWithResolver is like Parse but finds the locations of TZID parameters
+// with resolver. A nil resolver stands for DefaultLocationResolver. The
+// VTIMEZONE components among lines, such as those Lines writes, define TZIDs
+// that take precedence over resolver.
+func ParseWithResolver(resolver LocationResolver, lines ...string) (*Recurrence, error) {
+	contentLines, err := readContentLines(lines)
 	if err != nil {
 		return nil, err
 	}
-	if len(normalized) == 0 {
-		return &Recurrence{}, nil
+	contentLines, timezones, err := splitVTimezones(contentLines)
+	if err != nil {
+		return nil, err
+	}
+	if timezones != nil {
+		if resolver == nil {
+			resolver = DefaultLocationResolver
+		}
+		resolver = ChainLocationResolvers(timezones, resolver)
+	}
+	return FromContentLines(resolver, contentLines...)
+}
+
+// FromContentLines builds a recurrence from parsed DTSTART, RRULE, EXRULE,
+// RDATE, EXDATE, DTEND and DURATION lines, as ParseWithResolver does. Errors
+// are located at the lines they were parsed from.
+func FromContentLines(resolver LocationResolver, contentLines ...ContentLine) (*Recurrence, error) {
+	for _, l := range contentLines {
+		if err := validateContentLine(l); err != nil {
+			return nil, &SyntaxError{Line: l.Line, Column: l.column, Err: fmt.Errorf("invalid recurrence string '%s': %w", l, err)}
+		}
 	}
-	lines = normalized
 
 	defaultLoc := time.UTC
 	set := Recurrence{}
-	var dtstartLineForRRULE string
+	var dtstartLine *ContentLine
 
-	firstName, err := processRRuleName(lines[0])
-	if err != nil {
-		return nil, err
-	}
-	if firstName == "DTSTART" {
-		dtstartField := lines[0][len(firstName)+1:]
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(dtstartField)), "VALUE=DATE:") {
+	if len(contentLines) > 0 && contentLines[0].Name == "DTSTART" {
+		first := contentLines[0]
+		if isDate, hasTZID, isUTC := first.dateKind(); isDate {
 			set.SetAllDay(true)
+		} else if !hasTZID && !isUTC {
+			set.SetFloating(true)
 		}
 
-		dt, err := StrToDtStart(dtstartField, defaultLoc)
+		dt, err := parseDtStart(first, defaultLoc, resolver)
 		if err != nil {
-			return nil, fmt.Errorf("StrToDtStart failed: %v", err)
+			return nil, first.errorf("StrToDtStart failed: %v", err)
 		}
 		defaultLoc = dt.Location()
 		set.DTStart(dt)
-		if !set.GetDTStart().IsZero() {
-			if set.allDay {
-				dtstartLineForRRULE = fmt.Sprintf("DTSTART;VALUE=DATE:%s", set.GetDTStart().Format(DateFormat))
-			} else {
-				dtstartLineForRRULE = fmt.Sprintf("DTSTART%s", timeToRFCDatetimeStr(set.GetDTStart()))
-			}
+		// DTStartString keeps the floating kind for the RRULE parser.
+		l, err := ParseContentLine(set.dtstartString(nil))
+		if err != nil {
+			return nil, err
 		}
-		lines = lines[1:]
+		dtstartLine = &l
+		contentLines = contentLines[1:]
 	}
 
-	for _, line := range lines {
-		name, err := processRRuleName(line)
-		if err != nil {
-			return nil, err
+	if err := set.addContentLines(contentLines, dtstartLine, defaultLoc, resolver); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// readContentLines unfolds and lexes recurrence lines, skipping blank ones.
+// Each line may hold several content lines, and physical lines are numbered
+// across all of them. Bare rule parts, such as "FREQ=DAILY;COUNT=3", are read
+// as an RRULE.
+func readContentLines(lines []string) ([]ContentLine, error) {
+	var result []ContentLine
+	first := 1
+	for _, text := range lines {
+		for _, u := range unfold(text, first) {
+			l, err := lexContentLine(u)
+			if err != nil {
+				if !isRRuleProperties(u.text) {
+					return nil, err
+				}
+				_, column := u.pos(0)
+				l = ContentLine{Name: "RRULE", Value: u.text, Line: u.line, column: column, valueLine: u.line, valueCol: column}
+			}
+			result = append(result, l)
 		}
-		rule := line[len(name)+1:]
+		first += strings.Count(text, "\n") + 1
+	}
+	return result, nil
+}
 
-		switch name {
+// validateContentLine checks that l is a property of a recurrence set.
+func validateContentLine(l ContentLine) error {
+	switch l.Name {
+	case "DTSTART", "DTEND", "DURATION", "RDATE", "EXDATE":
+		return nil
+	case "RRULE", "EXRULE":
+		return validateRRuleProperties(l.Value)
+	}
+	return fmt.Errorf("unrecognized rule format")
+}
+
+// addContentLines adds the rules, dates and duration of lines to set. DTSTART
+// lines are skipped; RRULEs are parsed against dtstart when it is set, dates
+// without a TZID are read in defaultLoc and the others located by resolver.
+func (set *Recurrence) addContentLines(lines []ContentLine, dtstart *ContentLine, defaultLoc *time.Location, resolver LocationResolver) error {
+	for _, l := range lines {
+		switch l.Name {
 		case "RRULE":
-			rruleInput := line
-			if dtstartLineForRRULE != "" {
-				rruleInput = dtstartLineForRRULE + "\n" + line
+			rOpt, err := parseROption(l.Value, dtstart, resolver)
+			if err != nil {
+				return l.errorf("parseROption failed: %v", err)
+			}
+			if err := set.AddRule(*rOpt); err != nil {
+				return l.errorf("NewRRule failed: %v", err)
 			}
-			rOpt, err := parseROptionFromString(rruleInput)
+		case "EXRULE":
+			// RFC 2445 feeds are loose about the UNTIL form, so the rule is
+			// not checked against DTSTART.
+			rOpt, err := parseROption(l.Value, nil, resolver)
 			if err != nil {
-				return nil, fmt.Errorf("parseROption failed: %v", err)
+				return l.errorf("parseROption failed: %v", err)
 			}
-			err = set.setRuleOptions(*rOpt)
+			if err := set.AddExRule(*rOpt); err != nil {
+				return l.errorf("NewRRule failed: %v", err)
+			}
+		case "DTEND":
+			if set.GetDTStart().IsZero() {
+				return l.errorf("DTEND requires DTSTART")
+			}
+			dt, err := parseDtStart(l, defaultLoc, resolver)
 			if err != nil {
-				return nil, fmt.Errorf("NewRRule failed: %v", err)
+				return l.errorf("StrToDtStart failed: %v", err)
+			}
+			if dt.Before(set.GetDTStart()) {
+				return l.errorf("DTEND is before DTSTART")
 			}
+			set.SetDTEnd(dt)
+		case "DURATION":
+			d, err := StrToDuration(strings.ToUpper(l.Value))
+			if err != nil {
+				return l.errorf("StrToDuration failed: %v", err)
+			}
+			if d.Days < 0 || d.Clock < 0 {
+				return l.errorf("negative DURATION %q", l.Value)
+			}
+			set.SetDuration(d)
 		case "RDATE", "EXDATE":
-			if !set.allDay && containsValueDateParam(rule) {
+			value, _ := l.Param("VALUE")
+			value = strings.ToUpper(value)
+			if l.Name == "RDATE" && value == "PERIOD" {
+				periods, err := parsePeriods(l, defaultLoc, resolver)
+				if err != nil {
+					return l.errorf("strToPeriods failed: %v", err)
+				}
+				for _, p := range periods {
+					set.RDatePeriod(p)
+				}
+				continue
+			}
+			if !set.allDay && value == "DATE" {
 				set.SetAllDay(true)
 			}
 
-			ts, err := StrToDatesInLoc(rule, defaultLoc)
+			ts, err := parseDates(l, defaultLoc, resolver)
 			if err != nil {
-				return nil, fmt.Errorf("strToDates failed: %v", err)
+				return l.errorf("strToDates failed: %v", err)
 			}
 			for _, t := range ts {
-				if name == "RDATE" {
+				if l.Name == "RDATE" {
 					set.RDate(t)
 				} else {
 					set.ExDate(t)
@@ -231,20 +298,18 @@ func Parse(lines ...string) (*Recurrence, error) {
 			}
 		}
 	}
-
-	return &set, nil
+	return nil
 }
 
 // NormalizeRecurrenceRuleset cleans and normalizes recurrence lines.
-// It trims whitespace, removes empty entries, normalizes RRULE prefixing,
-// and keeps only the first RRULE when multiple are present.
+// It trims whitespace, removes empty entries and normalizes RRULE prefixing.
+// Every RRULE is kept, in order.
 func NormalizeRecurrenceRuleset(ruleset []string) ([]string, error) {
 	if len(ruleset) == 0 {
 		return nil, nil
 	}
 
 	normalized := make([]string, 0, len(ruleset))
-	foundRRule := false
 
 	for _, rule := range ruleset {
 		rule = strings.TrimSpace(rule)
@@ -263,13 +328,6 @@ func NormalizeRecurrenceRuleset(ruleset []string) ([]string, error) {
 			return nil, fmt.Errorf("invalid recurrence string '%s': %w", rule, err)
 		}
 
-		if strings.HasPrefix(strings.ToUpper(normalizedRule), "RRULE:") {
-			if foundRRule {
-				continue
-			}
-			foundRRule = true
-		}
-
 		normalized = append(normalized, normalizedRule)
 	}
 
@@ -280,7 +338,8 @@ func NormalizeRecurrenceRuleset(ruleset []string) ([]string, error) {
 	return normalized, nil
 }
 
-// normalizeRecurrenceLine normalizes a single RRULE/RDATE/EXDATE line.
+// normalizeRecurrenceLine normalizes a single RRULE/RDATE/EXDATE line, and
+// passes DTEND and DURATION lines through.
 func normalizeRecurrenceLine(rule string) (string, error) {
 	upperRule := strings.ToUpper(rule)
 
@@ -294,6 +353,16 @@ func normalizeRecurrenceLine(rule string) (string, error) {
 		}
 		return rule, nil
 	}
+	if strings.HasPrefix(upperRule, "EXRULE:") {
+		if err := validateRRuleProperties(rule[len("EXRULE:"):]); err != nil {
+			return "", err
+		}
+		return rule, nil
+	}
+	if strings.HasPrefix(upperRule, "DTEND:") || strings.HasPrefix(upperRule, "DTEND;") ||
+		strings.HasPrefix(upperRule, "DURATION:") {
+		return rule, nil
+	}
 	if strings.HasPrefix(upperRule, "RDATE:") || strings.HasPrefix(upperRule, "RDATE;") ||
 		strings.HasPrefix(upperRule, "EXDATE:") || strings.HasPrefix(upperRule, "EXDATE;") {
 		return rule, nil
@@ -327,6 +396,7 @@ func isRRuleProperties(content string) bool {
 	upperContent := strings.ToUpper(strings.TrimSpace(content))
 	return strings.Contains(upperContent, "FREQ=") &&
 		!strings.HasPrefix(upperContent, "RRULE:") &&
+		!strings.HasPrefix(upperContent, "EXRULE:") &&
 		!strings.HasPrefix(upperContent, "RDATE:") &&
 		!strings.HasPrefix(upperContent, "EXDATE:") &&
 		!strings.HasPrefix(upperContent, "DTSTART")
@@ -353,6 +423,41 @@ func (r *Recurrence) normalizeAllDayTimes() {
 		year, month, day := exdate.Date()
 		r.exdate[i] = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 	}
+	for i, p := range r.rperiod {
+		r.rperiod[i] = r.normalizePeriod(p)
+	}
+	r.sortDates()
+}
+
+func (r *Recurrence) normalizeFloatingTimes() {
+	if !r.dtstart.IsZero() {
+		r.dtstart = wallClock(r.dtstart)
+	}
+	for i, rdate := range r.rdate {
+		r.rdate[i] = wallClock(rdate)
+	}
+	for i, exdate := range r.exdate {
+		r.exdate[i] = wallClock(exdate)
+	}
+	for i, p := range r.rperiod {
+		r.rperiod[i] = r.normalizePeriod(p)
+	}
+	r.sortDates()
+}
+
+// sortDates rebuilds the sorted copies of rdate and exdate used by Iterator.
+func (r *Recurrence) sortDates() {
+	r.sortRDates()
+	r.sortedExDate = sortedTimes(r.exdate)
+}
+
+// sortRDates rebuilds sortedRDate from the RDATE values and the starts of the
+// RDATE periods.
+func (r *Recurrence) sortRDates() {
+	r.sortedRDate = sortedTimes(r.rdate)
+	for _, p := range r.rperiod {
+		r.sortedRDate = insertSorted(r.sortedRDate, p.Start)
+	}
 }
 
 func (r *Recurrence) setRuleOptions(option ROption) error {
@@ -360,6 +465,10 @@ func (r *Recurrence) setRuleOptions(option ROption) error {
 		r.allDay = true
 		r.normalizeAllDayTimes()
 	}
+	if option.Floating && !option.AllDay && !r.floating {
+		r.floating = true
+		r.normalizeFloatingTimes()
+	}
 
 	if option.Dtstart.IsZero() && !r.dtstart.IsZero() {
 		option.Dtstart = r.dtstart
@@ -368,20 +477,39 @@ func (r *Recurrence) setRuleOptions(option ROption) error {
 	if err := r.applyRule(option); err != nil {
 		return err
 	}
+	if option.RDate != nil {
+		r.SetRDates(option.RDate)
+	}
+	if option.EXDate != nil {
+		r.SetExDates(option.EXDate)
+	}
 
 	r.hasRule = true
 	return nil
 }
 
-func (r *Recurrence) rebuildRule() {
+func (r *compiledRule) rebuildRule() {
 	_ = r.applyRule(r.ruleOptionFromState())
 	r.hasRule = true
 }
 
-func (r *Recurrence) applyRule(option ROption) error {
+func (r *compiledRule) applyRule(option ROption) error {
 	if err := validateBounds(option); err != nil {
 		return err
 	}
+	calendar, err := calendarFor(option.Rscale)
+	if err != nil {
+		return err
+	}
+	if calendar == nil && len(option.BymonthLeap) != 0 {
+		return errors.New("leap months require a non-Gregorian RSCALE")
+	}
+	if calendar != nil && (len(option.Byweekno) != 0 || len(option.Byeaster) != 0) {
+		return fmt.Errorf("BYWEEKNO and BYEASTER are not supported with RSCALE=%s", calendar.Name())
+	}
+	if calendar != nil && !option.Dtstart.IsZero() && calendar.FromCivil(option.Dtstart.Date()) == (CalendarDate{}) {
+		return fmt.Errorf("DTSTART is out of the range of RSCALE=%s", calendar.Name())
+	}
 	if option.AllDay {
 		option.Byhour = nil
 		option.Byminute = nil
@@ -391,8 +519,9 @@ func (r *Recurrence) applyRule(option ROption) error {
 		}
 	}
 	r.allDay = option.AllDay
+	r.floating = option.Floating && !option.AllDay
 	r.intervalExplicit = option.Interval > 0
-	r.bymonthExplicit = len(option.Bymonth) != 0
+	r.bymonthExplicit = len(option.Bymonth) != 0 || len(option.BymonthLeap) != 0
 	r.bymonthdayExplicit = len(option.Bymonthday) != 0
 	r.byweekdayExplicit = len(option.Byweekday) != 0
 	r.byhourExplicit = len(option.Byhour) != 0
@@ -400,6 +529,9 @@ func (r *Recurrence) applyRule(option ROption) error {
 	r.bysecondExplicit = len(option.Bysecond) != 0
 
 	r.freq = option.Freq
+	r.skip = option.Skip
+	r.rscale = strings.ToUpper(option.Rscale)
+	r.calendar = calendar
 
 	if option.Interval < 1 {
 		option.Interval = 1
@@ -418,6 +550,8 @@ func (r *Recurrence) applyRule(option ROption) error {
 	if option.AllDay {
 		year, month, day := option.Dtstart.Date()
 		option.Dtstart = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	} else if r.floating {
+		option.Dtstart = wallClock(option.Dtstart)
 	} else {
 		option.Dtstart = option.Dtstart.Truncate(time.Second)
 	}
@@ -429,19 +563,14 @@ func (r *Recurrence) applyRule(option ROption) error {
 		if option.AllDay {
 			year, month, day := option.Until.Date()
 			option.Until = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		} else if r.floating {
+			option.Until = wallClock(option.Until)
 		} else {
 			option.Until = option.Until.Truncate(time.Second)
 		}
 		r.until = option.Until
 	}
 
-	if option.RDate != nil {
-		r.SetRDates(option.RDate)
-	}
-	if option.EXDate != nil {
-		r.SetExDates(option.EXDate)
-	}
-
 	r.wkst = option.Wkst.weekday
 	r.bymonthday = nil
 	r.bynmonthday = nil
@@ -454,7 +583,18 @@ func (r *Recurrence) applyRule(option ROption) error {
 		len(option.Byyearday) == 0 &&
 		len(option.Byweekday) == 0 &&
 		len(option.Byeaster) == 0 {
-		if r.freq == YEARLY {
+		if r.calendar != nil && r.freq <= MONTHLY {
+			// The implicit month and day are those of DTSTART in the calendar.
+			date := r.calendar.FromCivil(r.dtstart.Date())
+			if r.freq == YEARLY && !r.bymonthExplicit {
+				if date.Month.Leap {
+					option.BymonthLeap = []int{date.Month.Number}
+				} else {
+					option.Bymonth = []int{date.Month.Number}
+				}
+			}
+			option.Bymonthday = []int{date.Day}
+		} else if r.freq == YEARLY {
 			if len(option.Bymonth) == 0 {
 				option.Bymonth = []int{int(r.dtstart.Month())}
 			}
@@ -466,6 +606,7 @@ func (r *Recurrence) applyRule(option ROption) error {
 		}
 	}
 	r.bymonth = option.Bymonth
+	r.bymonthleap = option.BymonthLeap
 	r.byyearday = option.Byyearday
 	r.byeaster = option.Byeaster
 	for _, mday := range option.Bymonthday {
@@ -534,7 +675,7 @@ func (r *Recurrence) applyRule(option ROption) error {
 	return nil
 }
 
-func (r *Recurrence) ruleOptionFromState() ROption {
+func (r *compiledRule) ruleOptionFromState() ROption {
 	option := ROption{
 		Freq:      r.freq,
 		Dtstart:   r.dtstart,
@@ -542,6 +683,7 @@ func (r *Recurrence) ruleOptionFromState() ROption {
 		Wkst:      Weekday{weekday: r.wkst},
 		Count:     r.count,
 		AllDay:    r.allDay,
+		Floating:  r.floating,
 		Bysetpos:  cloneIntSlice(r.bysetpos),
 		Bymonth:   cloneIntSlice(r.bymonth),
 		Byyearday: cloneIntSlice(r.byyearday),
@@ -550,6 +692,8 @@ func (r *Recurrence) ruleOptionFromState() ROption {
 		Byminute:  cloneIntSlice(r.byminute),
 		Bysecond:  cloneIntSlice(r.bysecond),
 		Byeaster:  cloneIntSlice(r.byeaster),
+		Skip:      r.skip,
+		Rscale:    r.rscale,
 	}
 
 	if !r.intervalExplicit && r.interval == 1 {
@@ -563,7 +707,9 @@ func (r *Recurrence) ruleOptionFromState() ROption {
 		}
 	}
 
-	if !r.bymonthExplicit {
+	if r.bymonthExplicit {
+		option.BymonthLeap = cloneIntSlice(r.bymonthleap)
+	} else {
 		option.Bymonth = nil
 	}
 
@@ -609,62 +755,120 @@ func cloneIntSlice(values []int) []int {
 	return out
 }
 
-func (r *Recurrence) ruleIterator() Next {
+func (r *compiledRule) ruleIterator(limits Limits) Next {
+	return r.ruleIteratorFrom(time.Time{}, limits)
+}
+
+// ruleIteratorFrom returns a rule generator that skips the periods lying
+// entirely before dt. The generator may still yield a few values before dt,
+// callers are expected to filter them.
+// Rules with COUNT cannot be seeked because the number of occurrences before dt
+// is unknown; they fall back to replaying from DTSTART, which COUNT bounds.
+func (r *compiledRule) ruleIteratorFrom(dt time.Time, limits Limits) Next {
 	if !r.hasRule {
 		return func() (time.Time, bool) {
 			return time.Time{}, false
 		}
 	}
-	iterator := rIterator{}
-	iterator.year, iterator.month, iterator.day = r.dtstart.Date()
-
-	if r.allDay {
-		iterator.hour, iterator.minute, iterator.second = 0, 0, 0
-	} else {
-		iterator.hour, iterator.minute, iterator.second = r.dtstart.Clock()
+	iterator := r.newRuleIterator(dt, limits)
+	if iterator == nil {
+		return func() (time.Time, bool) {
+			return time.Time{}, false
+		}
 	}
-	iterator.weekday = toPyWeekday(r.dtstart.Weekday())
+	return iterator.next
+}
 
-	iterator.ii = iterInfo{recurrence: r}
-	iterator.ii.rebuild(iterator.year, iterator.month)
+// newRuleIterator returns a rule iterator positioned like ruleIteratorFrom,
+// enforcing limits, or nil if the rule has no occurrence at or after dt.
+func (r *compiledRule) newRuleIterator(dt time.Time, limits Limits) *rIterator {
+	iterator := &rIterator{}
+	if !iterator.reset(r, dt, limits) {
+		return nil
+	}
+	return iterator
+}
 
-	if r.freq < HOURLY {
-		iterator.timeset = r.timeset
-	} else {
-		if r.freq >= HOURLY && len(r.byhour) != 0 && !contains(r.byhour, iterator.hour) ||
-			r.freq >= MINUTELY && len(r.byminute) != 0 && !contains(r.byminute, iterator.minute) ||
-			r.freq >= SECONDLY && len(r.bysecond) != 0 && !contains(r.bysecond, iterator.second) {
-			iterator.timeset = nil
-		} else {
-			iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
+// ruleIteratorBackward returns a rule generator yielding occurrences in
+// descending order, starting from the period containing dt. It may yield a few
+// values after dt, callers are expected to filter them.
+func (r *compiledRule) ruleIteratorBackward(dt time.Time, limits Limits) Next {
+	if !r.hasRule || dt.Before(r.dtstart) {
+		return func() (time.Time, bool) {
+			return time.Time{}, false
+		}
+	}
+	if r.count > 0 {
+		// COUNT is anchored at DTSTART, so the bounded forward sequence is
+		// collected and replayed in reverse.
+		var occurrences []time.Time
+		next := r.ruleIterator(limits)
+		for v, ok := next(); ok && !v.After(dt); v, ok = next() {
+			occurrences = append(occurrences, v)
 		}
+		return reverseTimeSliceIterator(occurrences)
 	}
-	iterator.count = r.count
+	if dt.After(r.until) {
+		dt = r.until
+	}
+	if last, ok := r.calendarLastDay(); ok {
+		// Start from the last period the calendar covers.
+		year, month, day := civilDate(last + 1)
+		if end := time.Date(year, month, day, 0, 0, 0, 0, r.dtstart.Location()).Add(-seekMargin - time.Second); dt.After(end) {
+			dt = end
+		}
+	}
+
+	iterator := &rBackIterator{}
+	iterator.ii = iterInfo{recurrence: r}
+	iterator.limits = limits
+	div, _ := divmod(r.unitsSince(dt.Add(seekMargin)), r.interval)
+	if r.skip == BACKWARD && r.skipCrossesPeriods() {
+		// The next period may move an invalid date into this one.
+		div++
+	}
+	iterator.units = div * r.interval
 	return iterator.next
 }
 
-// Strings returns a slice of all the recurrence rules for a set
+// Strings returns a slice of all the recurrence rules for a set, as Lines
+// does. It returns nil when Lines returns an error, which happens under
+// TZIDError when a time is in a location without a resolvable TZID.
 func (set *Recurrence) Strings() []string {
+	res, err := set.Lines()
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// lines returns the recurrence lines of set, writing TZIDs with w.
+func (set *Recurrence) lines(w *tzidWriter) []string {
 	var res []string
 
-	str := set.DTStartString()
+	str := set.dtstartString(w)
 	if str != "" {
 		res = append(res, str)
 	}
 
-	if set.hasRule {
-		str = set.RRuleString()
-		if str != "" {
-			res = append(res, str)
-		}
+	str = set.DurationString()
+	if str != "" {
+		res = append(res, str)
 	}
 
-	str = set.RDateString()
+	for _, r := range set.rules() {
+		res = append(res, "RRULE:"+r.rrulePropertiesString())
+	}
+	for _, r := range set.exrules {
+		res = append(res, "EXRULE:"+r.rrulePropertiesString())
+	}
+
+	str = set.rdateString(w)
 	if str != "" {
 		res = append(res, str)
 	}
 
-	str = set.EXDateString()
+	str = set.exdateString(w)
 	if str != "" {
 		res = append(res, str)
 	}
@@ -672,7 +876,8 @@ func (set *Recurrence) Strings() []string {
 	return res
 }
 
-// String returns the full RFC 5545 recurrence text, one property per line.
+// String returns the full RFC 5545 recurrence text, one property per line,
+// or "" when Strings returns nil.
 // Example:
 // DTSTART:20240101T090000Z
 // RRULE:FREQ=DAILY;COUNT=2
@@ -686,7 +891,15 @@ func (set *Recurrence) String() string {
 // Example: DTSTART;VALUE=DATE:20240101
 // Example: DTSTART:20240101T090000Z
 // Example: DTSTART;TZID=Asia/Shanghai:20240101T090000
+// Example: DTSTART:20240101T090000 (floating)
+// TZIDs follow the TZIDPolicy of set; see Lines. Under TZIDError it returns ""
+// if DTSTART is in a location without a resolvable TZID.
 func (set *Recurrence) DTStartString() string {
+	w := set.tzidWriter()
+	return w.checked(set.dtstartString(w))
+}
+
+func (set *Recurrence) dtstartString(w *tzidWriter) string {
 	if set.dtstart.IsZero() {
 		return ""
 	}
@@ -696,11 +909,16 @@ func (set *Recurrence) DTStartString() string {
 		// All-day events should use VALUE=DATE format as per RFC 5545
 		return fmt.Sprintf("DTSTART;VALUE=DATE:%s", set.dtstart.Format(DateFormat))
 	}
+	// Floating DATE-TIME has neither TZID nor a trailing Z
+	if set.floating {
+		return fmt.Sprintf("DTSTART:%s", set.dtstart.Format(LocalDateTimeFormat))
+	}
 
-	return fmt.Sprintf("DTSTART%s", timeToRFCDatetimeStr(set.dtstart))
+	return "DTSTART" + w.format(set.dtstart)
 }
 
-// RRuleString returns RRULE serialized as a single line without DTSTART.
+// RRuleString returns the first RRULE serialized as a single line without DTSTART.
+// Use Strings to serialize every RRULE of a set with several rules.
 // Example: RRULE:FREQ=DAILY;COUNT=5
 func (set *Recurrence) RRuleString() string {
 	return fmt.Sprintf("RRULE:%s", set.rrulePropertiesString())
@@ -713,7 +931,14 @@ func (set *Recurrence) RRuleString() string {
 // Example: EXDATE;VALUE=DATE:20240110,20240112
 // Example: EXDATE:20240110T090000Z,20240112T090000Z
 // Example: EXDATE;TZID=Asia/Shanghai:20240110T090000,20240112T090000
+// TZIDs follow the TZIDPolicy of set; see Lines. Under TZIDError it returns ""
+// if a value is in a location without a resolvable TZID.
 func (set *Recurrence) EXDateString() string {
+	w := set.tzidWriter()
+	return w.checked(set.exdateString(w))
+}
+
+func (set *Recurrence) exdateString(w *tzidWriter) string {
 	if len(set.exdate) == 0 {
 		return ""
 	}
@@ -724,16 +949,23 @@ func (set *Recurrence) EXDateString() string {
 		}
 		return fmt.Sprintf("EXDATE;VALUE=DATE:%s", strings.Join(values, ","))
 	}
+	if set.floating {
+		values := make([]string, 0, len(set.exdate))
+		for _, item := range set.exdate {
+			values = append(values, item.Format(LocalDateTimeFormat))
+		}
+		return fmt.Sprintf("EXDATE:%s", strings.Join(values, ","))
+	}
 
 	valuesByTZID := make(map[string][]string)
 	var tzidOrder []string
 	for _, item := range set.exdate {
-		tzid := item.Location().String()
+		tzid := w.tzid(item)
 		if _, ok := valuesByTZID[tzid]; !ok {
 			tzidOrder = append(tzidOrder, tzid)
 		}
-		if tzid == "UTC" {
-			valuesByTZID[tzid] = append(valuesByTZID[tzid], item.Format(DateTimeFormat))
+		if tzid == "" {
+			valuesByTZID[tzid] = append(valuesByTZID[tzid], timeToUTCStr(item))
 		} else {
 			valuesByTZID[tzid] = append(valuesByTZID[tzid], item.Format(LocalDateTimeFormat))
 		}
@@ -742,10 +974,10 @@ func (set *Recurrence) EXDateString() string {
 	lines := make([]string, 0, len(valuesByTZID))
 	for _, tzid := range tzidOrder {
 		values := strings.Join(valuesByTZID[tzid], ",")
-		if tzid == "UTC" {
+		if tzid == "" {
 			lines = append(lines, fmt.Sprintf("EXDATE:%s", values))
 		} else {
-			lines = append(lines, fmt.Sprintf("EXDATE;TZID=%s:%s", tzid, values))
+			lines = append(lines, fmt.Sprintf("EXDATE%s:%s", tzidParam(tzid), values))
 		}
 	}
 	return strings.Join(lines, "\n")
@@ -758,27 +990,51 @@ func (set *Recurrence) EXDateString() string {
 // Example: RDATE;VALUE=DATE:20240301,20240303
 // Example: RDATE:20240301T090000Z,20240305T090000Z
 // Example: RDATE;TZID=Asia/Shanghai:20240301T090000,20240305T090000
+// RDATE periods follow on RDATE;VALUE=PERIOD lines.
+// TZIDs follow the TZIDPolicy of set; see Lines. Under TZIDError it returns ""
+// if a value is in a location without a resolvable TZID.
 func (set *Recurrence) RDateString() string {
+	w := set.tzidWriter()
+	return w.checked(set.rdateString(w))
+}
+
+func (set *Recurrence) rdateString(w *tzidWriter) string {
+	periods := set.rdatePeriodString(w)
 	if len(set.rdate) == 0 {
-		return ""
+		return periods
 	}
 	if set.allDay {
 		values := make([]string, 0, len(set.rdate))
 		for _, item := range set.rdate {
 			values = append(values, item.Format(DateFormat))
 		}
-		return fmt.Sprintf("RDATE;VALUE=DATE:%s", strings.Join(values, ","))
+		line := fmt.Sprintf("RDATE;VALUE=DATE:%s", strings.Join(values, ","))
+		if periods != "" {
+			line += "\n" + periods
+		}
+		return line
+	}
+	if set.floating {
+		values := make([]string, 0, len(set.rdate))
+		for _, item := range set.rdate {
+			values = append(values, item.Format(LocalDateTimeFormat))
+		}
+		line := fmt.Sprintf("RDATE:%s", strings.Join(values, ","))
+		if periods != "" {
+			line += "\n" + periods
+		}
+		return line
 	}
 
 	valuesByTZID := make(map[string][]string)
 	var tzidOrder []string
 	for _, item := range set.rdate {
-		tzid := item.Location().String()
+		tzid := w.tzid(item)
 		if _, ok := valuesByTZID[tzid]; !ok {
 			tzidOrder = append(tzidOrder, tzid)
 		}
-		if tzid == "UTC" {
-			valuesByTZID[tzid] = append(valuesByTZID[tzid], item.Format(DateTimeFormat))
+		if tzid == "" {
+			valuesByTZID[tzid] = append(valuesByTZID[tzid], timeToUTCStr(item))
 		} else {
 			valuesByTZID[tzid] = append(valuesByTZID[tzid], item.Format(LocalDateTimeFormat))
 		}
@@ -787,39 +1043,182 @@ func (set *Recurrence) RDateString() string {
 	lines := make([]string, 0, len(valuesByTZID))
 	for _, tzid := range tzidOrder {
 		values := strings.Join(valuesByTZID[tzid], ",")
-		if tzid == "UTC" {
+		if tzid == "" {
 			lines = append(lines, fmt.Sprintf("RDATE:%s", values))
 		} else {
-			lines = append(lines, fmt.Sprintf("RDATE;TZID=%s:%s", tzid, values))
+			lines = append(lines, fmt.Sprintf("RDATE%s:%s", tzidParam(tzid), values))
 		}
 	}
+	if periods != "" {
+		lines = append(lines, periods)
+	}
 	return strings.Join(lines, "\n")
 }
 
 // DTStart sets dtstart property for set.
 // It will be truncated to second precision.
 func (set *Recurrence) DTStart(dtstart time.Time) {
-	prevDtstart := set.dtstart
 	// Handle AllDay events: convert to floating time (UTC) as per RFC 5545
 	if set.allDay {
 		// All-day events should use floating time (no timezone binding)
 		// In Go, we represent floating time as UTC to ensure consistency
 		year, month, day := dtstart.Date()
-		set.dtstart = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		dtstart = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	} else if set.floating {
+		// Floating events keep the wall clock only
+		dtstart = wallClock(dtstart)
 	} else {
 		// Non all-day events: truncate to second precision
-		set.dtstart = dtstart.Truncate(time.Second)
+		dtstart = dtstart.Truncate(time.Second)
 	}
 
 	if set.hasRule {
-		if !set.until.IsZero() && !prevDtstart.IsZero() {
-			maxUntil := prevDtstart.Add(time.Duration(1<<63 - 1))
-			if set.until.Equal(maxUntil) {
-				set.until = set.dtstart.Add(time.Duration(1<<63 - 1))
-			}
+		set.rebase(dtstart, set.allDay, set.floating)
+	} else {
+		set.dtstart = dtstart
+	}
+	for _, r := range set.extraRules {
+		r.rebase(dtstart, set.allDay, set.floating)
+	}
+	for _, r := range set.exrules {
+		r.rebase(dtstart, set.allDay, set.floating)
+	}
+}
+
+// rebase moves the rule to dtstart and rebuilds it. A rule without UNTIL
+// stays unbounded.
+func (r *compiledRule) rebase(dtstart time.Time, allDay, floating bool) {
+	option := r.ruleOptionFromState()
+	option.Dtstart = dtstart
+	option.AllDay = allDay
+	option.Floating = floating
+	if floating && !option.Until.IsZero() {
+		option.Until = wallClock(option.Until)
+	}
+	_ = r.applyRule(option)
+	r.hasRule = true
+}
+
+// rules returns the compiled RRULEs of the set, the first one included.
+func (set *Recurrence) rules() []*compiledRule {
+	if !set.hasRule {
+		return nil
+	}
+	rules := make([]*compiledRule, 0, 1+len(set.extraRules))
+	rules = append(rules, &set.compiledRule)
+	return append(rules, set.extraRules...)
+}
+
+// Rules returns the RRULEs of the set in the order they were added.
+func (set *Recurrence) Rules() []ROption {
+	rules := set.rules()
+	options := make([]ROption, 0, len(rules))
+	for _, r := range rules {
+		options = append(options, r.ruleOptionFromState())
+	}
+	return options
+}
+
+// AddRule adds an RRULE to the set. The occurrences of all rules are merged,
+// an instant produced by several rules being returned once.
+// The first rule of a set is applied like New does. Further rules share its
+// DTSTART and all-day flag, which option.Dtstart and option.AllDay cannot
+// change; their RDate and EXDate values are added to the set.
+func (set *Recurrence) AddRule(option ROption) error {
+	if !set.hasRule {
+		if err := set.limits.checkRule(option); err != nil {
+			return err
+		}
+		return set.setRuleOptions(option)
+	}
+	r, err := set.compileRule(option)
+	if err != nil {
+		return err
+	}
+	set.extraRules = append(set.extraRules, r)
+	for _, rdate := range option.RDate {
+		set.RDate(rdate)
+	}
+	for _, exdate := range option.EXDate {
+		set.ExDate(exdate)
+	}
+	return nil
+}
+
+// AddExRule adds an exclusion rule to the set, as the EXRULE property of
+// RFC 2445 does: occurrences of the rule are removed from the set like EXDATE
+// values. EXRULE was removed from RFC 5545; see ExpandExRules for consumers
+// that do not accept it.
+// The rule shares the DTSTART and all-day flag of the set. The RDate and
+// EXDate values of option are ignored.
+func (set *Recurrence) AddExRule(option ROption) error {
+	r, err := set.compileRule(option)
+	if err != nil {
+		return err
+	}
+	set.exrules = append(set.exrules, r)
+	return nil
+}
+
+// ExRules returns the exclusion rules of the set in the order they were added.
+func (set *Recurrence) ExRules() []ROption {
+	options := make([]ROption, 0, len(set.exrules))
+	for _, r := range set.exrules {
+		options = append(options, r.ruleOptionFromState())
+	}
+	return options
+}
+
+// ExpandExRules replaces the exclusion rules of the set with the EXDATE values
+// they stand for up to horizon, so that the set can be serialized for RFC 5545
+// consumers. Only occurrences of the set are added as EXDATE values.
+// Occurrences after horizon that an exclusion rule removed are included again.
+func (set *Recurrence) ExpandExRules(horizon time.Time) {
+	if len(set.exrules) == 0 {
+		return
+	}
+	candidates := newSetIterator(set.allDay, false)
+	candidates.rlist.add(timeSliceIterator(set.sortedRDate))
+	for _, r := range set.rules() {
+		candidates.rlist.add(r.ruleIteratorFrom(time.Time{}, set.limits))
+	}
+	excluded := newSetIterator(set.allDay, false)
+	for _, r := range set.exrules {
+		excluded.rlist.add(r.ruleIteratorFrom(time.Time{}, set.limits))
+	}
+
+	ex, exOK := excluded.next()
+	for dt, ok := candidates.next(); ok && !dt.After(horizon); dt, ok = candidates.next() {
+		for exOK && ex.Before(dt) {
+			ex, exOK = excluded.next()
+		}
+		if !exOK {
+			break
+		}
+		if ex.Equal(dt) && !timeSearch(set.sortedExDate, dt) {
+			set.ExDate(dt)
 		}
-		set.rebuildRule()
 	}
+	set.exrules = nil
+}
+
+// compileRule compiles option into a rule sharing the DTSTART and all-day
+// flag of the set.
+func (set *Recurrence) compileRule(option ROption) (*compiledRule, error) {
+	if err := set.limits.checkRule(option); err != nil {
+		return nil, err
+	}
+	if !set.dtstart.IsZero() {
+		option.Dtstart = set.dtstart
+	}
+	option.AllDay = set.allDay
+	option.Floating = set.floating
+	r := &compiledRule{}
+	if err := r.applyRule(option); err != nil {
+		return nil, err
+	}
+	r.hasRule = true
+	return r, nil
 }
 
 // GetDTStart gets DTSTART for set
@@ -836,10 +1235,14 @@ func (set *Recurrence) RDate(rdate time.Time) {
 		// In Go, we represent floating time as UTC to ensure consistency
 		year, month, day := rdate.Date()
 		set.rdate = append(set.rdate, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	} else if set.floating {
+		// Floating events keep the wall clock only
+		set.rdate = append(set.rdate, wallClock(rdate))
 	} else {
 		// Non all-day events: truncate to second precision
 		set.rdate = append(set.rdate, rdate.Truncate(time.Second))
 	}
+	set.sortedRDate = insertSorted(set.sortedRDate, set.rdate[len(set.rdate)-1])
 }
 
 // SetRDates sets explicitly added dates (rdates) in the set.
@@ -853,11 +1256,15 @@ func (set *Recurrence) SetRDates(rdates []time.Time) {
 			// In Go, we represent floating time as UTC to ensure consistency
 			year, month, day := rdate.Date()
 			set.rdate = append(set.rdate, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		} else if set.floating {
+			// Floating events keep the wall clock only
+			set.rdate = append(set.rdate, wallClock(rdate))
 		} else {
 			// Non all-day events: truncate to second precision
 			set.rdate = append(set.rdate, rdate.Truncate(time.Second))
 		}
 	}
+	set.sortRDates()
 }
 
 // GetRDate returns explicitly added dates (rdates) in the set
@@ -876,10 +1283,14 @@ func (set *Recurrence) ExDate(exdate time.Time) {
 		// In Go, we represent floating time as UTC to ensure consistency
 		year, month, day := exdate.Date()
 		set.exdate = append(set.exdate, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	} else if set.floating {
+		// Floating events keep the wall clock only
+		set.exdate = append(set.exdate, wallClock(exdate))
 	} else {
 		// Non all-day events: truncate to second precision
 		set.exdate = append(set.exdate, exdate.Truncate(time.Second))
 	}
+	set.sortedExDate = insertSorted(set.sortedExDate, set.exdate[len(set.exdate)-1])
 }
 
 // SetExDates sets explicitly excluded dates (exdates) in the set.
@@ -893,11 +1304,15 @@ func (set *Recurrence) SetExDates(exdates []time.Time) {
 			// In Go, we represent floating time as UTC to ensure consistency
 			year, month, day := exdate.Date()
 			set.exdate = append(set.exdate, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		} else if set.floating {
+			// Floating events keep the wall clock only
+			set.exdate = append(set.exdate, wallClock(exdate))
 		} else {
 			// Non all-day events: truncate to second precision
 			set.exdate = append(set.exdate, exdate.Truncate(time.Second))
 		}
 	}
+	set.sortedExDate = sortedTimes(set.exdate)
 }
 
 // GetExDate returns explicitly excluded dates (exdates) in the set.
@@ -929,11 +1344,114 @@ func (set *Recurrence) SetAllDay(allDay bool) {
 			year, month, day := exdate.Date()
 			set.exdate[i] = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 		}
+
+		// Normalize rdate periods
+		for i, p := range set.rperiod {
+			set.rperiod[i] = set.normalizePeriod(p)
+		}
+		set.sortDates()
+
+		// All-day durations are whole days
+		set.duration = set.normalizeDuration(set.duration)
+		set.normalizeOverrides()
 	}
 
 	if set.hasRule {
 		set.rebuildRule()
 	}
+	for _, r := range set.extraRules {
+		r.rebase(set.dtstart, allDay, set.floating)
+	}
+	for _, r := range set.exrules {
+		r.rebase(set.dtstart, allDay, set.floating)
+	}
+}
+
+// SetFloating sets the floating flag for a set of timed events. Floating
+// recurrences keep the wall clock of their times without binding them to a
+// zone, as DATE-TIME values without TZID and without a trailing Z do: 9:00
+// means 9:00 wherever the occurrence is observed. Floating times are
+// represented in UTC; use InLocation to expand them in a location.
+// When set to true, existing times keep their wall clock in their own location.
+// It has no effect on all-day sets, which are always floating.
+func (set *Recurrence) SetFloating(floating bool) {
+	if set.allDay {
+		return
+	}
+	dtstart := set.dtstart
+	if floating && !dtstart.IsZero() {
+		dtstart = wallClock(dtstart)
+	}
+	if set.hasRule {
+		set.compiledRule.rebase(dtstart, set.allDay, floating)
+	}
+	set.floating = floating
+	if floating {
+		set.normalizeFloatingTimes()
+		set.normalizeOverrides()
+	}
+	for _, r := range set.extraRules {
+		r.rebase(set.dtstart, set.allDay, floating)
+	}
+	for _, r := range set.exrules {
+		r.rebase(set.dtstart, set.allDay, floating)
+	}
+}
+
+// IsFloating returns whether the set is configured for floating timed events.
+func (set *Recurrence) IsFloating() bool {
+	return set.floating
+}
+
+// InLocation returns the recurrence a floating set stands for in loc: every
+// time of the set is bound to loc with its wall clock, so that occurrences can
+// be expanded and queried as instants. Sets that are not floating are returned
+// as is.
+func (set *Recurrence) InLocation(loc *time.Location) *Recurrence {
+	if !set.floating {
+		return set
+	}
+	project := func(t time.Time) time.Time {
+		year, month, day := t.Date()
+		hour, minute, second := t.Clock()
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+	res := &Recurrence{limits: set.limits, duration: set.duration, tzidPolicy: set.tzidPolicy}
+	if !set.dtstart.IsZero() {
+		res.DTStart(project(set.dtstart))
+	}
+	projectRule := func(option ROption) ROption {
+		option.Floating = false
+		option.Dtstart = project(option.Dtstart)
+		if !option.Until.IsZero() {
+			option.Until = project(option.Until)
+		}
+		return option
+	}
+	// The rules were validated when they were added to the set.
+	for _, option := range set.Rules() {
+		_ = res.AddRule(projectRule(option))
+	}
+	for _, option := range set.ExRules() {
+		_ = res.AddExRule(projectRule(option))
+	}
+	for _, rdate := range set.rdate {
+		res.RDate(project(rdate))
+	}
+	for _, exdate := range set.exdate {
+		res.ExDate(project(exdate))
+	}
+	for _, p := range set.rperiod {
+		res.RDatePeriod(Period{Start: project(p.Start), End: project(p.End)})
+	}
+	for _, o := range set.GetOverrides() {
+		o.RecurrenceID = project(o.RecurrenceID)
+		if !o.Start.IsZero() {
+			o.Start = project(o.Start)
+		}
+		res.SetOverride(o)
+	}
+	return res
 }
 
 // IsAllDay returns whether the set is configured for all-day events.
@@ -944,69 +1462,80 @@ func (set *Recurrence) IsAllDay() bool {
 // rrulePropertiesString returns the RRULE value without the "RRULE:" prefix.
 // Example: FREQ=DAILY;COUNT=5
 // Example: FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR
-func (set *Recurrence) rrulePropertiesString() string {
-	result := []string{fmt.Sprintf("FREQ=%v", set.freq)}
-	if set.intervalExplicit && set.interval != 1 {
-		result = append(result, fmt.Sprintf("INTERVAL=%v", set.interval))
-	}
-	if set.wkst != MO.weekday {
-		result = append(result, fmt.Sprintf("WKST=%v", Weekday{weekday: set.wkst}))
-	}
-	if set.count > 0 {
-		result = append(result, fmt.Sprintf("COUNT=%v", set.count))
-	}
-	if !set.until.IsZero() {
-		maxUntil := set.dtstart.Add(time.Duration(1<<63 - 1))
-		if !set.until.Equal(maxUntil) {
-			if set.allDay {
-				until := set.until.In(set.dtstart.Location())
+func (r *compiledRule) rrulePropertiesString() string {
+	var result []string
+	if r.rscale != "" {
+		result = append(result, fmt.Sprintf("RSCALE=%v", r.rscale))
+	} else if r.skip != OMIT {
+		// RFC 7529 only allows SKIP together with RSCALE.
+		result = append(result, "RSCALE=GREGORIAN")
+	}
+	result = append(result, fmt.Sprintf("FREQ=%v", r.freq))
+	if r.intervalExplicit && r.interval != 1 {
+		result = append(result, fmt.Sprintf("INTERVAL=%v", r.interval))
+	}
+	if r.wkst != MO.weekday {
+		result = append(result, fmt.Sprintf("WKST=%v", Weekday{weekday: r.wkst}))
+	}
+	if r.count > 0 {
+		result = append(result, fmt.Sprintf("COUNT=%v", r.count))
+	}
+	if !r.until.IsZero() {
+		maxUntil := r.dtstart.Add(time.Duration(1<<63 - 1))
+		if !r.until.Equal(maxUntil) {
+			if r.allDay {
+				until := r.until.In(r.dtstart.Location())
 				result = append(result, fmt.Sprintf("UNTIL=%v", until.Format(DateFormat)))
+			} else if r.floating {
+				result = append(result, fmt.Sprintf("UNTIL=%v", r.until.Format(LocalDateTimeFormat)))
 			} else {
-				result = append(result, fmt.Sprintf("UNTIL=%v", timeToUTCStr(set.until)))
+				result = append(result, fmt.Sprintf("UNTIL=%v", timeToUTCStr(r.until)))
 			}
 		}
 	}
-	result = appendIntsOption(result, "BYSETPOS", set.bysetpos)
-	if set.bymonthExplicit {
-		result = appendIntsOption(result, "BYMONTH", set.bymonth)
+	result = appendIntsOption(result, "BYSETPOS", r.bysetpos)
+	if r.bymonthExplicit {
+		result = appendMonthsOption(result, "BYMONTH", r.bymonth, r.bymonthleap)
 	}
-	if set.bymonthdayExplicit {
-		byMonthDay := make([]int, 0, len(set.bymonthday)+len(set.bynmonthday))
-		byMonthDay = append(byMonthDay, set.bymonthday...)
-		byMonthDay = append(byMonthDay, set.bynmonthday...)
+	if r.bymonthdayExplicit {
+		byMonthDay := make([]int, 0, len(r.bymonthday)+len(r.bynmonthday))
+		byMonthDay = append(byMonthDay, r.bymonthday...)
+		byMonthDay = append(byMonthDay, r.bynmonthday...)
 		result = appendIntsOption(result, "BYMONTHDAY", byMonthDay)
 	}
-	result = appendIntsOption(result, "BYYEARDAY", set.byyearday)
-	result = appendIntsOption(result, "BYWEEKNO", set.byweekno)
-	if set.byweekdayExplicit {
-		byWeekday := make([]Weekday, 0, len(set.byweekday)+len(set.bynweekday))
-		for _, wday := range set.byweekday {
+	result = appendIntsOption(result, "BYYEARDAY", r.byyearday)
+	result = appendIntsOption(result, "BYWEEKNO", r.byweekno)
+	if r.byweekdayExplicit {
+		byWeekday := make([]Weekday, 0, len(r.byweekday)+len(r.bynweekday))
+		for _, wday := range r.byweekday {
 			byWeekday = append(byWeekday, Weekday{weekday: wday})
 		}
-		byWeekday = append(byWeekday, set.bynweekday...)
+		byWeekday = append(byWeekday, r.bynweekday...)
 		valueStr := make([]string, len(byWeekday))
 		for i, wday := range byWeekday {
 			valueStr[i] = wday.String()
 		}
 		result = append(result, fmt.Sprintf("BYDAY=%s", strings.Join(valueStr, ",")))
 	}
-	if !set.allDay {
-		if set.byhourExplicit {
-			result = appendIntsOption(result, "BYHOUR", set.byhour)
+	if !r.allDay {
+		if r.byhourExplicit {
+			result = appendIntsOption(result, "BYHOUR", r.byhour)
 		}
-		if set.byminuteExplicit {
-			result = appendIntsOption(result, "BYMINUTE", set.byminute)
+		if r.byminuteExplicit {
+			result = appendIntsOption(result, "BYMINUTE", r.byminute)
 		}
-		if set.bysecondExplicit {
-			result = appendIntsOption(result, "BYSECOND", set.bysecond)
+		if r.bysecondExplicit {
+			result = appendIntsOption(result, "BYSECOND", r.bysecond)
 		}
 	}
-	result = appendIntsOption(result, "BYEASTER", set.byeaster)
+	result = appendIntsOption(result, "BYEASTER", r.byeaster)
+	if r.skip != OMIT {
+		result = append(result, fmt.Sprintf("SKIP=%v", r.skip))
+	}
 	return strings.Join(result, ";")
 }
 
 func parseROptionFromString(rfcString string) (*ROption, error) {
-	defaultLoc := time.UTC
 	rfcString = strings.TrimSpace(rfcString)
 	strs := strings.Split(rfcString, "\n")
 	var rruleStr, dtstartStr string
@@ -1020,28 +1549,43 @@ func parseROptionFromString(rfcString string) (*ROption, error) {
 		return nil, errors.New("invalid RRULE string")
 	}
 
-	result := ROption{}
-	var dtstartIsDate bool
-	var dtstartHasTZID bool
-	var dtstartIsUTC bool
-	freqSet := false
-
+	var dtstart *ContentLine
 	if dtstartStr != "" {
-		firstName, err := processRRuleName(dtstartStr)
+		l, err := ParseContentLine(dtstartStr)
 		if err != nil {
 			return nil, fmt.Errorf("expect DTSTART but: %s", err)
 		}
-		if firstName != "DTSTART" {
-			return nil, fmt.Errorf("expect DTSTART but: %s", firstName)
+		if l.Name != "DTSTART" {
+			return nil, fmt.Errorf("expect DTSTART but: %s", l.Name)
 		}
+		dtstart = &l
+	}
+	if l, err := ParseContentLine(rruleStr); err == nil && l.Name == "RRULE" {
+		rruleStr = l.Value
+	}
+	return parseROption(rruleStr, dtstart, nil)
+}
+
+// parseROption parses the rule parts of an RRULE value. When dtstart is set,
+// the rule starts at it and UNTIL must be of the same kind.
+func parseROption(rruleStr string, dtstart *ContentLine, resolver LocationResolver) (*ROption, error) {
+	defaultLoc := time.UTC
+	result := ROption{}
+	var dtstartIsDate bool
+	var dtstartHasTZID bool
+	var dtstartIsUTC bool
+	freqSet := false
 
-		dtstartValue := dtstartStr[len(firstName)+1:]
-		dtstartIsDate, dtstartHasTZID, dtstartIsUTC = detectDtstartKind(dtstartValue)
+	if dtstart != nil {
+		dtstartIsDate, dtstartHasTZID, dtstartIsUTC = dtstart.dateKind()
 		if dtstartIsDate {
 			result.AllDay = true
+		} else if !dtstartHasTZID && !dtstartIsUTC {
+			result.Floating = true
 		}
 
-		result.Dtstart, err = StrToDtStart(dtstartValue, defaultLoc)
+		var err error
+		result.Dtstart, err = parseDtStart(*dtstart, defaultLoc, resolver)
 		if err != nil {
 			return nil, fmt.Errorf("StrToDtStart failed: %s", err)
 		}
@@ -1050,7 +1594,8 @@ func parseROptionFromString(rfcString string) (*ROption, error) {
 		}
 	}
 
-	rruleStr = strings.TrimPrefix(rruleStr, "RRULE:")
+	// Rule part names and values are case-insensitive.
+	rruleStr = strings.ToUpper(rruleStr)
 	for _, attr := range strings.Split(rruleStr, ";") {
 		keyValue := strings.Split(attr, "=")
 		if len(keyValue) != 2 {
@@ -1087,7 +1632,7 @@ func parseROptionFromString(rfcString string) (*ROption, error) {
 		case "BYSETPOS":
 			result.Bysetpos, err = strToInts(value)
 		case "BYMONTH":
-			result.Bymonth, err = strToInts(value)
+			result.Bymonth, result.BymonthLeap, err = strToMonths(value)
 		case "BYMONTHDAY":
 			result.Bymonthday, err = strToInts(value)
 		case "BYYEARDAY":
@@ -1104,6 +1649,10 @@ func parseROptionFromString(rfcString string) (*ROption, error) {
 			result.Bysecond, err = strToInts(value)
 		case "BYEASTER":
 			result.Byeaster, err = strToInts(value)
+		case "SKIP":
+			result.Skip, err = StrToSkip(value)
+		case "RSCALE":
+			result.Rscale = strings.ToUpper(value)
 		default:
 			return nil, errors.New("unknown RRULE property: " + key)
 		}
@@ -1123,100 +1672,196 @@ type genItem struct {
 	gen Next
 }
 
-type genItemSlice []genItem
-
-func (s genItemSlice) Len() int           { return len(s) }
-func (s genItemSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s genItemSlice) Less(i, j int) bool { return s[i].dt.Before(s[j].dt) }
+// genHeap is a heap of generators keyed by their pending value.
+// It orders values ascending, or descending when desc is set.
+type genHeap struct {
+	items []genItem
+	desc  bool
+}
 
-func addGenList(genList *[]genItem, next Next) {
-	dt, ok := next()
-	if ok {
-		*genList = append(*genList, genItem{dt, next})
+func (h *genHeap) Len() int { return len(h.items) }
+func (h *genHeap) Less(i, j int) bool {
+	if h.desc {
+		return h.items[i].dt.After(h.items[j].dt)
 	}
+	return h.items[i].dt.Before(h.items[j].dt)
+}
+func (h *genHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *genHeap) Push(x any)    { h.items = append(h.items, x.(genItem)) }
+func (h *genHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
 }
 
-// Iterator returns an iterator for Recurrence
-func (set *Recurrence) Iterator() (next func() (time.Time, bool)) {
-	rlist := []genItem{}
-	exlist := []genItem{}
+// add primes next and pushes it on the heap unless it is already exhausted.
+// It maintains the heap with heap.Fix rather than heap.Push and heap.Pop,
+// which box every item in an interface.
+func (h *genHeap) add(next Next) {
+	if dt, ok := next(); ok {
+		h.items = append(h.items, genItem{dt, next})
+		heap.Fix(h, len(h.items)-1)
+	}
+}
 
-	// Normalize rdate times for all-day events
-	rdates := set.rdate
-	if set.allDay && len(set.rdate) > 0 {
-		rdates = make([]time.Time, len(set.rdate))
-		for i, t := range set.rdate {
-			// Convert to floating time (00:00:00 UTC represents floating time)
-			rdates[i] = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+// advance replaces the top pending value with the next value of its generator.
+func (h *genHeap) advance() {
+	var ok bool
+	if h.items[0].dt, ok = h.items[0].gen(); !ok {
+		last := len(h.items) - 1
+		h.items[0] = h.items[last]
+		h.items[last] = genItem{}
+		h.items = h.items[:last]
+		if last == 0 {
+			return
 		}
 	}
+	heap.Fix(h, 0)
+}
 
-	sort.Sort(timeSlice(rdates))
-	addGenList(&rlist, timeSliceIterator(rdates))
-	if set.hasRule {
-		addGenList(&rlist, set.ruleIterator())
+// setIterator merges the rule and RDATE streams and removes EXDATE values.
+// With desc set, all streams run backwards and values are yielded in
+// descending order.
+type setIterator struct {
+	allDay bool
+	desc   bool
+	rlist  genHeap
+	exlist genHeap
+	lastdt time.Time
+}
+
+func newSetIterator(allDay, desc bool) *setIterator {
+	return &setIterator{
+		allDay: allDay,
+		desc:   desc,
+		rlist:  genHeap{items: make([]genItem, 0, 2), desc: desc},
+		exlist: genHeap{items: make([]genItem, 0, 1), desc: desc},
 	}
-	sort.Sort(genItemSlice(rlist))
+}
 
-	// Normalize exdate times for all-day events
-	exdates := set.exdate
-	if set.allDay && len(set.exdate) > 0 {
-		exdates = make([]time.Time, len(set.exdate))
-		for i, t := range set.exdate {
-			// Convert to floating time (00:00:00 UTC represents floating time)
-			exdates[i] = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+func (it *setIterator) next() (time.Time, bool) {
+	for it.rlist.Len() != 0 {
+		dt := it.rlist.items[0].dt
+		it.rlist.advance()
+
+		// Normalize dt for all-day events to ensure consistent comparison
+		if it.allDay {
+			dt = time.Date(dt.Year(), dt.Month(), dt.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		if !it.lastdt.IsZero() && it.lastdt.Equal(dt) {
+			continue
+		}
+		for it.exlist.Len() != 0 && it.precedes(it.exlist.items[0].dt, dt) {
+			it.exlist.advance()
+		}
+		it.lastdt = dt
+		if it.exlist.Len() == 0 || !dt.Equal(it.exlist.items[0].dt) {
+			return dt, true
 		}
 	}
+	return time.Time{}, false
+}
 
-	sort.Sort(timeSlice(exdates))
-	addGenList(&exlist, timeSliceIterator(exdates))
-	sort.Sort(genItemSlice(exlist))
+// precedes reports whether a comes before b in iteration order.
+func (it *setIterator) precedes(a, b time.Time) bool {
+	if it.desc {
+		return a.After(b)
+	}
+	return a.Before(b)
+}
 
-	lastdt := time.Time{}
+// Iterator returns an iterator for Recurrence
+func (set *Recurrence) Iterator() (next func() (time.Time, bool)) {
+	return set.iteratorFrom(time.Time{})
+}
+
+// iteratorFrom returns an iterator that starts near dt instead of DTSTART.
+// Values before dt may still be yielded and must be filtered by the caller.
+func (set *Recurrence) iteratorFrom(dt time.Time) Next {
+	it := newSetIterator(set.allDay, false)
+	it.rlist.add(timeSliceIterator(set.sortedRDate[searchTimes(set.sortedRDate, dt):]))
+	for _, r := range set.rules() {
+		it.rlist.add(r.ruleIteratorFrom(dt, set.limits))
+	}
+	it.exlist.add(timeSliceIterator(set.sortedExDate[searchTimes(set.sortedExDate, dt):]))
+	for _, r := range set.exrules {
+		it.exlist.add(r.ruleIteratorFrom(dt, set.limits))
+	}
+	return it.next
+}
+
+// IteratorBackward returns an iterator over the occurrences at or before from,
+// in descending order. Periods are generated backwards from from, so the cost
+// does not depend on how long ago DTSTART was, except for rules with COUNT,
+// which are anchored at DTSTART and are expanded forward first.
+func (set *Recurrence) IteratorBackward(from time.Time) (next func() (time.Time, bool)) {
+	it := newSetIterator(set.allDay, true)
+	it.rlist.add(reverseTimeSliceIterator(set.sortedRDate[:searchTimesAfter(set.sortedRDate, from)]))
+	for _, r := range set.rules() {
+		it.rlist.add(r.ruleIteratorBackward(from, set.limits))
+	}
+	it.exlist.add(reverseTimeSliceIterator(set.sortedExDate[:searchTimesAfter(set.sortedExDate, from)]))
+	for _, r := range set.exrules {
+		it.exlist.add(r.ruleIteratorBackward(from, set.limits))
+	}
 	return func() (time.Time, bool) {
-		for len(rlist) != 0 {
-			dt := rlist[0].dt
-			var ok bool
-			rlist[0].dt, ok = rlist[0].gen()
-			if !ok {
-				rlist = rlist[1:]
+		for {
+			dt, ok := it.next()
+			if !ok || !dt.After(from) {
+				return dt, ok
 			}
-			sort.Sort(genItemSlice(rlist))
+		}
+	}
+}
 
-			// Normalize dt for all-day events to ensure consistent comparison
-			normalizedDt := dt
-			if set.allDay {
-				normalizedDt = time.Date(dt.Year(), dt.Month(), dt.Day(), 0, 0, 0, 0, time.UTC)
-			}
+// Occurrences returns a sequence over all occurrences of the Recurrence.
+// Each range creates its own generator, so breaking out of the loop early
+// leaves no iteration state behind.
+func (set *Recurrence) Occurrences() iter.Seq[time.Time] {
+	return toSeq(func() Next { return set.Iterator() })
+}
 
-			// Use normalized time for comparison
-			normalizedLastdt := lastdt
-			if set.allDay && !lastdt.IsZero() {
-				normalizedLastdt = time.Date(lastdt.Year(), lastdt.Month(), lastdt.Day(), 0, 0, 0, 0, time.UTC)
-			}
+// OccurrencesBackward returns a sequence over the occurrences at or before from,
+// in descending order.
+func (set *Recurrence) OccurrencesBackward(from time.Time) iter.Seq[time.Time] {
+	return toSeq(func() Next { return set.IteratorBackward(from) })
+}
 
-			if lastdt.IsZero() || !normalizedLastdt.Equal(normalizedDt) {
-				for len(exlist) != 0 && exlist[0].dt.Before(normalizedDt) {
-					exlist[0].dt, ok = exlist[0].gen()
-					if !ok {
-						exlist = exlist[1:]
-					}
-					sort.Sort(genItemSlice(exlist))
-				}
-				lastdt = normalizedDt
-				if len(exlist) == 0 || !normalizedDt.Equal(exlist[0].dt) {
-					return normalizedDt, true
-				}
+// occurrencesNear returns a sequence that starts near dt instead of DTSTART.
+// It may yield values before dt.
+func (set *Recurrence) occurrencesNear(dt time.Time) iter.Seq[time.Time] {
+	return toSeq(func() Next { return set.iteratorFrom(dt) })
+}
+
+// IndexedOccurrences returns a sequence over all occurrences of the Recurrence
+// paired with their zero-based position in the recurrence set.
+func (set *Recurrence) IndexedOccurrences() iter.Seq2[int, time.Time] {
+	return func(yield func(int, time.Time) bool) {
+		i := 0
+		for v := range set.Occurrences() {
+			if !yield(i, v) {
+				return
 			}
+			i++
 		}
-		return time.Time{}, false
 	}
 }
 
+// OccurrencesBetween returns a sequence over the occurrences between after and before.
+// The inc keyword has the same meaning as in Between.
+func (set *Recurrence) OccurrencesBetween(after, before time.Time, inc bool) iter.Seq[time.Time] {
+	return betweenSeq(set.occurrencesNear(after), after, before, inc)
+}
+
+// OccurrencesFrom returns a sequence over the occurrences at or after dt.
+func (set *Recurrence) OccurrencesFrom(dt time.Time) iter.Seq[time.Time] {
+	return fromSeq(set.occurrencesNear(dt), dt, true)
+}
+
 // All returns all occurrences of the Recurrence.
 // It is only supported second precision.
 func (set *Recurrence) All() []time.Time {
-	return all(set.Iterator())
+	return all(set.Occurrences())
 }
 
 // Between returns all the occurrences of the rrule between after and before.
@@ -1224,7 +1869,7 @@ func (set *Recurrence) All() []time.Time {
 // With inc == True, they will be included in the list, if they are found in the recurrence set.
 // It is only supported second precision.
 func (set *Recurrence) Between(after, before time.Time, inc bool) []time.Time {
-	return between(set.Iterator(), after, before, inc)
+	return set.AppendBetween([]time.Time{}, after, before, inc)
 }
 
 // Before Returns the last recurrence before the given datetime instance,
@@ -1233,7 +1878,18 @@ func (set *Recurrence) Between(after, before time.Time, inc bool) []time.Time {
 // With inc == True, if dt itself is an occurrence, it will be returned.
 // It is only supported second precision.
 func (set *Recurrence) Before(dt time.Time, inc bool) time.Time {
-	return before(set.Iterator(), dt, inc)
+	return before(set.OccurrencesBackward(dt), dt, inc)
+}
+
+// Prev returns the last occurrence strictly before dt.
+// The boolean is false if there is no such occurrence.
+func (set *Recurrence) Prev(dt time.Time) (time.Time, bool) {
+	for v := range set.OccurrencesBackward(dt) {
+		if v.Before(dt) {
+			return v, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // After returns the first recurrence after the given datetime instance,
@@ -1242,29 +1898,15 @@ func (set *Recurrence) Before(dt time.Time, inc bool) time.Time {
 // With inc == True, if dt itself is an occurrence, it will be returned.
 // It is only supported second precision.
 func (set *Recurrence) After(dt time.Time, inc bool) time.Time {
-	return after(set.Iterator(), dt, inc)
+	return after(set.occurrencesNear(dt), dt, inc)
 }
 
-// StrToRRuleSet converts string to RRuleSet
+// StrToRRuleSet converts string to RRuleSet. The string holds content
+// lines, which may be folded and end with CRLF or LF.
 func StrToRRuleSet(s string) (*Recurrence, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
 		return nil, errors.New("empty string")
 	}
-	ss := strings.Split(s, "\n")
-	return Parse(ss...)
-}
-
-func containsValueDateParam(rule string) bool {
-	upper := strings.ToUpper(rule)
-	paramSection := upper
-	if idx := strings.Index(paramSection, ":"); idx != -1 {
-		paramSection = paramSection[:idx]
-	}
-	for _, part := range strings.Split(paramSection, ";") {
-		if strings.TrimSpace(part) == "VALUE=DATE" {
-			return true
-		}
-	}
-	return false
+	return Parse(s)
 }
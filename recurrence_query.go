@@ -0,0 +1,328 @@
+package rrule
+
+import (
+	"time"
+)
+
+// Nth returns the n-th occurrence of the recurrence set, counting from zero,
+// after RDATE values are merged in and EXDATE values removed.
+// The boolean is false if the set has fewer than n+1 occurrences.
+func (set *Recurrence) Nth(n int) (time.Time, bool) {
+	if n < 0 {
+		return time.Time{}, false
+	}
+	if set.isSimple() && set.hasRule {
+		// Every rdate may precede the target, so the rule occurrence
+		// n-len(rdate) is a safe place to seek to.
+		if k := n - len(set.sortedRDate); k > 0 {
+			from := set.ruleOccurrence(k)
+			i := set.countBefore(from)
+			for v := range fromSeq(set.occurrencesNear(from), from, true) {
+				if i == n {
+					return v, true
+				}
+				i++
+			}
+			return time.Time{}, false
+		}
+	}
+	for i, v := range set.IndexedOccurrences() {
+		if i == n {
+			return v, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// IndexOf returns the zero-based position of dt in the recurrence set.
+// The boolean is false if dt is not an occurrence.
+func (set *Recurrence) IndexOf(dt time.Time) (int, bool) {
+	if set.isSimple() {
+		if !set.includes(dt) || timeSearch(set.sortedExDate, dt) {
+			return 0, false
+		}
+		return set.countBefore(dt), true
+	}
+	for i, v := range set.IndexedOccurrences() {
+		if v.Equal(dt) {
+			return i, true
+		}
+		if v.After(dt) {
+			break
+		}
+	}
+	return 0, false
+}
+
+// Contains reports whether dt is an occurrence of the recurrence set.
+// EXDATE and RDATE values are looked up directly. Each rule and exclusion rule
+// is evaluated against
+// dt alone when possible: in closed form for simple FREQ/INTERVAL rules, and
+// with the BY* filters of dt's year otherwise. Rules with BYSETPOS, COUNT or
+// SKIP fall back to a bounded iteration.
+func (set *Recurrence) Contains(dt time.Time) bool {
+	if timeSearch(set.sortedExDate, dt) {
+		return false
+	}
+	for _, r := range set.exrules {
+		if r.ruleContains(dt, set.limits) {
+			return false
+		}
+	}
+	if timeSearch(set.sortedRDate, dt) {
+		return true
+	}
+	for _, r := range set.rules() {
+		if r.ruleContains(dt, set.limits) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleContains reports whether dt is an occurrence of the rule.
+func (r *compiledRule) ruleContains(dt time.Time, limits Limits) bool {
+	if !r.hasRule || dt.Before(r.dtstart) || dt.After(r.until) || dt.Year() > MAXYEAR || dt.Nanosecond() != 0 {
+		return false
+	}
+	if r.isSimple() {
+		return r.ruleIncludes(dt)
+	}
+	if r.count > 0 || len(r.bysetpos) != 0 || r.skip != OMIT || r.calendar != nil {
+		next := r.ruleIteratorFrom(dt, limits)
+		for v, ok := next(); ok && !v.After(dt); v, ok = next() {
+			if v.Equal(dt) {
+				return true
+			}
+		}
+		return false
+	}
+
+	loc := r.dtstart.Location()
+	local := dt.In(loc)
+	year, month, day := local.Date()
+	hour, minute, second := local.Clock()
+	// The iterator builds occurrences with time.Date, so a matching wall-clock
+	// time repeated by a DST transition yields only the instant it picks.
+	matches := func(year int, month time.Month, day, hour, minute, second int) bool {
+		return time.Date(year, month, day, hour, minute, second, 0, loc).Equal(dt) &&
+			r.matchesCivil(year, month, day, hour, minute, second)
+	}
+	if matches(year, month, day, hour, minute, second) {
+		return true
+	}
+	// dt may be the normalized form of a wall-clock time skipped by a DST
+	// transition, which time.Date moves by the size of the gap, backward into
+	// the offset before it or forward into the offset after it.
+	_, offset := local.Zone()
+	start, end := local.ZoneBounds()
+	var gaps []int
+	if !end.IsZero() {
+		if _, after := end.Zone(); after > offset {
+			gaps = append(gaps, after-offset)
+		}
+	}
+	if !start.IsZero() {
+		if _, before := start.Add(-time.Second).Zone(); before < offset {
+			gaps = append(gaps, before-offset)
+		}
+	}
+	for _, gap := range gaps {
+		skipped := time.Date(year, month, day, hour, minute, second+gap, 0, time.UTC)
+		y, m, d := skipped.Date()
+		h, mi, sec := skipped.Clock()
+		if matches(y, m, d, h, mi, sec) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesCivil evaluates the rule predicates against a wall-clock time in
+// DTSTART's location. It ignores BYSETPOS, COUNT and UNTIL.
+func (r *compiledRule) matchesCivil(year int, month time.Month, day, hour, minute, second int) bool {
+	units := r.unitsSinceCivil(year, month, day, hour, minute, second)
+	if units < 0 || pymod(units, r.interval) != 0 {
+		return false
+	}
+	if r.freq < HOURLY {
+		if !contains(r.byhour, hour) || !contains(r.byminute, minute) || !contains(r.bysecond, second) {
+			return false
+		}
+	} else if len(r.byhour) != 0 && !contains(r.byhour, hour) ||
+		len(r.byminute) != 0 && !contains(r.byminute, minute) ||
+		len(r.bysecond) != 0 && !contains(r.bysecond, second) {
+		return false
+	}
+
+	info := iterInfo{recurrence: r}
+	days := civilDays(year, month, day)
+	periodYear, periodMonth := year, month
+	if r.freq == WEEKLY {
+		// A week crossing the new year is evaluated with the masks of the
+		// year it starts in, as the iterator does.
+		weekday := toPyWeekday(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday())
+		start := days - pymod(weekday-r.wkst, 7)
+		year0, month0, day0 := r.dtstart.Date()
+		if first := civilDays(year0, month0, day0); start < first {
+			start = first
+		}
+		periodYear, periodMonth, _ = civilDate(start)
+	}
+	info.rebuild(periodYear, periodMonth)
+	return !info.excludes(days - civilDays(periodYear, time.January, 1))
+}
+
+// Count returns the number of occurrences of the recurrence set.
+// finite is false, and n is 0, when a rule has neither COUNT nor UNTIL.
+func (set *Recurrence) Count() (n int, finite bool) {
+	for _, r := range set.rules() {
+		if r.count == 0 && r.isUnbounded() {
+			return 0, false
+		}
+	}
+	if set.isSimple() {
+		return set.countBefore(endOfTime), true
+	}
+	for range set.Occurrences() {
+		n++
+	}
+	return n, true
+}
+
+// endOfTime is later than any occurrence the iterators can produce.
+var endOfTime = time.Date(MAXYEAR+1, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(1, 0, 0)
+
+// isUnbounded reports whether the rule has no UNTIL.
+func (r *compiledRule) isUnbounded() bool {
+	return r.until.Equal(r.dtstart.Add(time.Duration(1<<63 - 1)))
+}
+
+// isSimple reports whether the set has at most one rule, no exclusion rule,
+// and a simple rule, so that set occurrences can be counted in closed form.
+func (set *Recurrence) isSimple() bool {
+	return len(set.extraRules) == 0 && len(set.exrules) == 0 && set.compiledRule.isSimple()
+}
+
+// isSimple reports whether every period of the rule yields exactly one
+// occurrence at a fixed wall-clock offset from DTSTART, so that occurrences
+// can be computed in closed form instead of being iterated.
+// A recurrence without a rule is trivially simple.
+func (r *compiledRule) isSimple() bool {
+	if !r.hasRule {
+		return true
+	}
+	if r.calendar != nil {
+		return false
+	}
+	if len(r.bysetpos) != 0 || len(r.byyearday) != 0 || len(r.byweekno) != 0 || len(r.byeaster) != 0 ||
+		r.bymonthExplicit || r.bymonthdayExplicit || r.byweekdayExplicit ||
+		r.byhourExplicit || r.byminuteExplicit || r.bysecondExplicit {
+		return false
+	}
+	switch r.freq {
+	case YEARLY:
+		return r.dtstart.Month() != time.February || r.dtstart.Day() != 29
+	case MONTHLY:
+		return r.dtstart.Day() <= 28
+	case HOURLY, MINUTELY, SECONDLY:
+		// Stepping the wall clock skips or repeats occurrences around an
+		// offset change, so only locations whose offset stays fixed after
+		// DTSTART are simple.
+		_, end := r.dtstart.ZoneBounds()
+		return end.IsZero()
+	}
+	return true
+}
+
+// ruleOccurrence returns the k-th occurrence of a simple rule, ignoring COUNT
+// and UNTIL. Like the iterator, it steps DTSTART's wall clock.
+func (r *compiledRule) ruleOccurrence(k int) time.Time {
+	year, month, day := r.dtstart.Date()
+	hour, minute, second := r.dtstart.Clock()
+	n := k * r.interval
+	switch r.freq {
+	case YEARLY:
+		year += n
+	case MONTHLY:
+		month += time.Month(n)
+	case WEEKLY:
+		day += 7 * n
+	case DAILY:
+		day += n
+	case HOURLY:
+		hour += n
+	case MINUTELY:
+		minute += n
+	case SECONDLY:
+		second += n
+	}
+	return time.Date(year, month, day, hour, minute, second, 0, r.dtstart.Location())
+}
+
+// ruleCountBefore returns the number of occurrences of a simple rule before dt.
+func (r *compiledRule) ruleCountBefore(dt time.Time) int {
+	if !r.hasRule {
+		return 0
+	}
+	if end := r.until.Add(time.Second); end.Before(dt) {
+		dt = end
+	}
+	if end := time.Date(MAXYEAR+1, 1, 1, 0, 0, 0, 0, r.dtstart.Location()); end.Before(dt) {
+		dt = end
+	}
+	if !dt.After(r.dtstart) {
+		return 0
+	}
+	k, _ := divmod(r.unitsSince(dt), r.interval)
+	for k >= 0 && !r.ruleOccurrence(k).Before(dt) {
+		k--
+	}
+	for r.ruleOccurrence(k + 1).Before(dt) {
+		k++
+	}
+	n := k + 1
+	if r.count > 0 && n > r.count {
+		n = r.count
+	}
+	return n
+}
+
+// ruleIncludes reports whether dt is an occurrence of a simple rule.
+func (r *compiledRule) ruleIncludes(dt time.Time) bool {
+	if !r.hasRule {
+		return false
+	}
+	k := r.ruleCountBefore(dt)
+	return (r.count == 0 || k < r.count) && r.ruleOccurrence(k).Equal(dt) &&
+		!dt.After(r.until) && dt.Year() <= MAXYEAR
+}
+
+// includes reports whether dt is produced by a simple rule or an RDATE,
+// before EXDATE values are applied.
+func (set *Recurrence) includes(dt time.Time) bool {
+	return set.ruleIncludes(dt) || timeSearch(set.sortedRDate, dt)
+}
+
+// countBefore returns the number of occurrences of a recurrence set with a
+// simple rule that are before dt.
+func (set *Recurrence) countBefore(dt time.Time) int {
+	n := set.ruleCountBefore(dt)
+	for i, rdate := range set.sortedRDate {
+		if !rdate.Before(dt) {
+			break
+		}
+		if (i == 0 || !rdate.Equal(set.sortedRDate[i-1])) && !set.ruleIncludes(rdate) {
+			n++
+		}
+	}
+	for i, exdate := range set.sortedExDate {
+		if !exdate.Before(dt) {
+			break
+		}
+		if (i == 0 || !exdate.Equal(set.sortedExDate[i-1])) && set.includes(exdate) {
+			n--
+		}
+	}
+	return n
+}
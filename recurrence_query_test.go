@@ -0,0 +1,207 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func queryTestRecurrences(t *testing.T) []*Recurrence {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	options := []ROption{
+		{Freq: DAILY, Count: 30, Dtstart: time.Date(2024, 3, 1, 2, 30, 0, 0, newYork)},
+		{Freq: HOURLY, Interval: 5, Until: time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC), Dtstart: time.Date(2024, 3, 1, 2, 30, 0, 0, newYork)},
+		{Freq: WEEKLY, Interval: 2, Count: 10, Dtstart: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Count: 14, Dtstart: time.Date(2024, 1, 28, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Count: 14, Dtstart: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{Freq: YEARLY, Count: 9, Dtstart: time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)},
+		{Freq: DAILY, Count: 20, Dtstart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), AllDay: true},
+		{Freq: MONTHLY, Count: 6, Byweekday: []Weekday{MO.Nth(-1)}, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: MINUTELY, Interval: 45, Until: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		// Sub-daily rules crossing DST transitions.
+		{Freq: HOURLY, Count: 48, Dtstart: time.Date(2024, 3, 9, 0, 0, 0, 0, newYork)},
+		{Freq: HOURLY, Count: 48, Dtstart: time.Date(2024, 11, 2, 12, 0, 0, 0, newYork)},
+		{Freq: HOURLY, Count: 48, Dtstart: time.Date(2024, 3, 30, 12, 0, 0, 0, london)},
+		{Freq: MINUTELY, Interval: 20, Count: 30, Dtstart: time.Date(2024, 10, 27, 0, 0, 0, 0, london)},
+		{Freq: HOURLY, Count: 48, Dtstart: time.Date(2024, 3, 9, 0, 0, 0, 0, time.FixedZone("EST", -5*3600))},
+	}
+
+	var result []*Recurrence
+	for _, opt := range options {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		all := r.All()
+		// An rdate that duplicates an occurrence, one between occurrences,
+		// one before DTSTART and an exdate that removes an occurrence.
+		r.RDate(all[3])
+		r.RDate(all[5].Add(time.Minute))
+		r.RDate(r.GetDTStart().AddDate(0, 0, -3))
+		r.ExDate(all[2])
+		r.ExDate(all[4].Add(time.Minute))
+		result = append(result, r)
+	}
+	return result
+}
+
+func TestNth(t *testing.T) {
+	for _, r := range queryTestRecurrences(t) {
+		all := r.All()
+		for i, want := range all {
+			if value, ok := r.Nth(i); !ok || value != want {
+				t.Errorf("%s: Nth(%d) get %v, %v, want %v", r.RRuleString(), i, value, ok, want)
+			}
+		}
+		if value, ok := r.Nth(len(all)); ok {
+			t.Errorf("%s: Nth(%d) get %v, want no occurrence", r.RRuleString(), len(all), value)
+		}
+		if _, ok := r.Nth(-1); ok {
+			t.Errorf("%s: Nth(-1) should fail", r.RRuleString())
+		}
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	for _, r := range queryTestRecurrences(t) {
+		for i, dt := range r.All() {
+			if index, ok := r.IndexOf(dt); !ok || index != i {
+				t.Errorf("%s: IndexOf(%v) get %d, %v, want %d", r.RRuleString(), dt, index, ok, i)
+			}
+			if index, ok := r.IndexOf(dt.Add(time.Second)); ok {
+				t.Errorf("%s: IndexOf(%v) get %d, want not found", r.RRuleString(), dt.Add(time.Second), index)
+			}
+		}
+		for _, dt := range r.GetExDate() {
+			if index, ok := r.IndexOf(dt); ok {
+				t.Errorf("%s: IndexOf(%v) get %d for an exdate, want not found", r.RRuleString(), dt, index)
+			}
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	for _, r := range queryTestRecurrences(t) {
+		n, finite := r.Count()
+		if want := len(r.All()); !finite || n != want {
+			t.Errorf("%s: Count() get %d, %v, want %d", r.RRuleString(), n, finite, want)
+		}
+	}
+
+	r, _ := newRecurrence(ROption{Freq: DAILY, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)})
+	if n, finite := r.Count(); finite || n != 0 {
+		t.Errorf("unbounded Count() get %d, %v, want 0, false", n, finite)
+	}
+
+	r = &Recurrence{}
+	r.RDate(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	r.RDate(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	r.RDate(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	if n, finite := r.Count(); !finite || n != 2 {
+		t.Errorf("rdate only Count() get %d, %v, want 2, true", n, finite)
+	}
+}
+
+func TestNthLongRunning(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: HOURLY, Dtstart: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)})
+	r.ExDate(time.Date(2000, 1, 1, 5, 0, 0, 0, time.UTC))
+	want := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(1_000_001 * time.Hour)
+	if value, ok := r.Nth(1_000_000); !ok || value != want {
+		t.Errorf("get %v, %v, want %v", value, ok, want)
+	}
+	if index, ok := r.IndexOf(want); !ok || index != 1_000_000 {
+		t.Errorf("get %d, %v, want 1000000", index, ok)
+	}
+}
+
+func TestContains(t *testing.T) {
+	options := seekTestOptions(t)
+	options = append(options,
+		ROption{Freq: DAILY, Count: 40, Interval: 3, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		ROption{Freq: MONTHLY, Bysetpos: []int{2, -1}, Byweekday: []Weekday{SA, SU}, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		ROption{Freq: WEEKLY, Byweekno: []int{1, 52, 53, -1}, Byweekday: []Weekday{MO, TH, SU}, Wkst: TH, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		ROption{Freq: DAILY, Byhour: []int{2, 3}, Byminute: []int{0, 30}, Dtstart: time.Date(2005, 3, 2, 2, 0, 0, 0, time.UTC)},
+	)
+	newYork, _ := time.LoadLocation("America/New_York")
+	options = append(options,
+		ROption{Freq: DAILY, Byhour: []int{2, 3}, Byminute: []int{0, 30}, Dtstart: time.Date(2005, 3, 2, 2, 0, 0, 0, newYork)},
+	)
+	windowStart := time.Date(2005, 12, 20, 0, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2007, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, opt := range options {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		if exdate := r.After(windowStart, true); !exdate.IsZero() {
+			r.ExDate(exdate)
+		}
+		r.RDate(time.Date(2006, 7, 4, 12, 0, 0, 0, time.UTC))
+
+		occurrences := between(r.Occurrences(), windowStart.AddDate(0, 0, -8), windowEnd.AddDate(0, 0, 2), true)
+		isOccurrence := make(map[time.Time]bool, len(occurrences))
+		for _, dt := range occurrences {
+			isOccurrence[dt.UTC()] = true
+		}
+		for _, dt := range between(r.Occurrences(), windowStart, windowEnd, true) {
+			for _, candidate := range []time.Time{dt, dt.Add(time.Second), dt.Add(-time.Hour), dt.Add(time.Hour), dt.AddDate(0, 0, 1), dt.AddDate(0, 0, -7)} {
+				if got, want := r.Contains(candidate), isOccurrence[candidate.UTC()]; got != want {
+					t.Errorf("%s: Contains(%v) get %v, want %v", r.RRuleString(), candidate, got, want)
+				}
+			}
+		}
+		for _, dt := range r.GetExDate() {
+			if r.Contains(dt) {
+				t.Errorf("%s: Contains(%v) get true for an exdate", r.RRuleString(), dt)
+			}
+		}
+		if rdate := r.GetRDate()[0]; !r.Contains(rdate) {
+			t.Errorf("%s: Contains should report the rdate", r.RRuleString())
+		}
+	}
+}
+
+func TestContainsDST(t *testing.T) {
+	location := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("LoadLocation failed: %v", err)
+		}
+		return loc
+	}
+	newYork, london, lordHowe := location("America/New_York"), location("Europe/London"), location("Australia/Lord_Howe")
+	options := []ROption{
+		{Freq: HOURLY, Byminute: []int{30}, Dtstart: time.Date(2024, 11, 1, 0, 30, 0, 0, newYork)},
+		{Freq: HOURLY, Byminute: []int{30}, Dtstart: time.Date(2024, 3, 8, 0, 30, 0, 0, newYork)},
+		{Freq: HOURLY, Dtstart: time.Date(2024, 11, 2, 0, 0, 0, 0, newYork)},
+		{Freq: MINUTELY, Interval: 30, Dtstart: time.Date(2024, 10, 26, 0, 0, 0, 0, london)},
+		{Freq: DAILY, Byhour: []int{1}, Byminute: []int{30}, Dtstart: time.Date(2024, 3, 29, 1, 30, 0, 0, london)},
+		{Freq: DAILY, Byhour: []int{1}, Byminute: []int{30}, Dtstart: time.Date(2024, 10, 25, 1, 30, 0, 0, london)},
+		{Freq: DAILY, Byhour: []int{2}, Byminute: []int{0, 15, 45}, Dtstart: time.Date(2024, 10, 4, 2, 0, 0, 0, lordHowe)},
+		{Freq: DAILY, Byhour: []int{1}, Byminute: []int{30, 45}, Dtstart: time.Date(2024, 4, 5, 1, 30, 0, 0, lordHowe)},
+		{Freq: HOURLY, Byminute: []int{0, 15, 30, 45}, Dtstart: time.Date(2024, 4, 6, 0, 0, 0, 0, lordHowe)},
+	}
+	for _, opt := range options {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		end := opt.Dtstart.AddDate(0, 0, 4)
+		isOccurrence := make(map[time.Time]bool)
+		for _, dt := range r.Between(opt.Dtstart, end, true) {
+			isOccurrence[dt.UTC()] = true
+		}
+		for dt := opt.Dtstart.Add(-time.Hour); dt.Before(end); dt = dt.Add(15 * time.Minute) {
+			if got, want := r.Contains(dt), isOccurrence[dt.UTC()]; got != want {
+				t.Errorf("%s: Contains(%v) get %v, want %v", r.RRuleString(), dt, got, want)
+			}
+		}
+	}
+}
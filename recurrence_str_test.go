@@ -34,6 +34,39 @@ func TestCompatibility(t *testing.T) {
 	}
 }
 
+func TestSkipString(t *testing.T) {
+	str := "DTSTART;VALUE=DATE:20160229\nRRULE:RSCALE=GREGORIAN;FREQ=YEARLY;COUNT=2;SKIP=FORWARD"
+	r, err := StrToRRuleSet(str)
+	require.NoError(t, err)
+	assert.Equal(t, str, r.String())
+	assert.Equal(t, FORWARD, r.Rules()[0].Skip)
+	assert.Equal(t, "RSCALE=GREGORIAN;FREQ=MONTHLY;SKIP=BACKWARD", rruleFromOption(t, ROption{Freq: MONTHLY, Skip: BACKWARD, Dtstart: time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC)}))
+	assert.Equal(t, "FREQ=MONTHLY", rruleFromOption(t, ROption{Freq: MONTHLY, Skip: OMIT, Dtstart: time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC)}))
+
+	_, err = StrToRRuleSet("DTSTART;VALUE=DATE:20160229\nRRULE:FREQ=YEARLY;SKIP=SIDEWAYS")
+	assert.Error(t, err)
+
+	// SKIP is written with the RSCALE RFC 7529 requires it to follow.
+	r, err = StrToRRuleSet("DTSTART:20240131T090000Z\nRRULE:FREQ=MONTHLY;COUNT=6;SKIP=BACKWARD")
+	require.NoError(t, err)
+	assert.Equal(t, "DTSTART:20240131T090000Z\nRRULE:RSCALE=GREGORIAN;FREQ=MONTHLY;COUNT=6;SKIP=BACKWARD", r.String())
+	reparsed, err := StrToRRuleSet(r.String())
+	require.NoError(t, err)
+	assert.Equal(t, r.All(), reparsed.All())
+}
+
+func TestRscaleString(t *testing.T) {
+	str := "DTSTART;VALUE=DATE:20140208\nRRULE:RSCALE=HEBREW;FREQ=YEARLY;COUNT=5;BYMONTH=5,5L;BYMONTHDAY=8;SKIP=FORWARD"
+	r, err := StrToRRuleSet(str)
+	require.NoError(t, err)
+	assert.Equal(t, str, r.String())
+	option := r.Rules()[0]
+	assert.Equal(t, "HEBREW", option.Rscale)
+	assert.Equal(t, []int{5}, option.Bymonth)
+	assert.Equal(t, []int{5}, option.BymonthLeap)
+	assert.Equal(t, "RSCALE=ISLAMIC-CIVIL;FREQ=MONTHLY", rruleFromOption(t, ROption{Freq: MONTHLY, Rscale: "islamic-civil", Dtstart: time.Date(2014, 2, 8, 0, 0, 0, 0, time.UTC)}))
+}
+
 func TestInvalidString(t *testing.T) {
 	cases := []string{
 		"",
@@ -144,7 +177,7 @@ func TestSetStrCompatibility(t *testing.T) {
 		}
 	}
 	rruleStr := strings.Join(rruleLines, "\n")
-	if rruleStr != "DTSTART;TZID=America/New_York:20180101T090000\nRRULE:FREQ=DAILY;UNTIL=20180517T235959Z" {
+	if rruleStr != "DTSTART;TZID=America/New_York:20180101T090000\nRRULE:FREQ=DAILY;UNTIL=20180517T235959Z\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU" {
 		t.Errorf("Unexpected rrule: %s", rruleStr)
 	}
 	if !dtWantTime.Equal(set.GetDTStart()) {
@@ -169,7 +202,8 @@ func TestSetStrCompatibility(t *testing.T) {
 		t.Errorf("Unexpected exDates: %v", exDates)
 	}
 
-	dtWantAfter := time.Date(2018, 1, 2, 9, 0, 0, 0, nyLoc)
+	// The EXRULE removes the 2nd and 3rd of January.
+	dtWantAfter := time.Date(2018, 1, 4, 9, 0, 0, 0, nyLoc)
 	dtAfter := set.After(dtWantTime, false)
 	if !dtWantAfter.Equal(dtAfter) {
 		t.Errorf("Next time wrong should be %s but is %s", dtWantAfter, dtAfter)
@@ -874,12 +908,13 @@ func TestNormalizeRecurrenceRuleset(t *testing.T) {
 			},
 			expected: []string{
 				"RRULE:FREQ=DAILY;COUNT=5",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR",
 				"RDATE:20240115T100000Z",
 				"EXDATE:20240120T100000Z",
 			},
 		},
 		{
-			name: "multiple RRULE lines keeps first",
+			name: "multiple RRULE lines are all kept",
 			input: []string{
 				"RRULE:FREQ=DAILY;COUNT=5",
 				"RRULE:FREQ=WEEKLY;BYDAY=MO",
@@ -887,9 +922,21 @@ func TestNormalizeRecurrenceRuleset(t *testing.T) {
 			},
 			expected: []string{
 				"RRULE:FREQ=DAILY;COUNT=5",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO",
 				"RDATE:20240115T100000Z",
 			},
 		},
+		{
+			name: "EXRULE kept",
+			input: []string{
+				"RRULE:FREQ=DAILY",
+				"EXRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+			},
+			expected: []string{
+				"RRULE:FREQ=DAILY",
+				"EXRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+			},
+		},
 		{
 			name:     "RDATE without modification",
 			input:    []string{"RDATE:20240115T100000Z"},
@@ -4012,6 +4012,53 @@ func BenchmarkIterator(b *testing.B) {
 	}
 }
 
+func BenchmarkIteratorMerge(b *testing.B) {
+	dtstart := time.Date(2000, 03, 22, 12, 0, 0, 0, time.UTC)
+	rrule, err := newRecurrence(ROption{Freq: HOURLY, Dtstart: dtstart})
+	if err != nil {
+		b.Errorf("failed to init rrule: %s", err)
+	}
+	for i := 0; i < 500; i++ {
+		rrule.RDate(dtstart.Add(time.Duration(i)*7*time.Hour + 30*time.Minute))
+		rrule.ExDate(dtstart.Add(time.Duration(i) * 11 * time.Hour))
+	}
+
+	next := rrule.Iterator()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := next(); !ok {
+			next = rrule.Iterator()
+		}
+	}
+}
+
+func BenchmarkBetweenWeekWindow(b *testing.B) {
+	window := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		Name    string
+		Dtstart time.Time
+	}{
+		{Name: "new series", Dtstart: time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)},
+		{Name: "20 year old series", Dtstart: time.Date(2005, 6, 1, 9, 0, 0, 0, time.UTC)},
+	} {
+		c := c
+		b.Run(c.Name, func(b *testing.B) {
+			rrule, err := newRecurrence(ROption{Freq: DAILY, Dtstart: c.Dtstart})
+			if err != nil {
+				b.Errorf("failed to init rrule: %s", err)
+			}
+
+			for i := 0; i < b.N; i++ {
+				res := rrule.Between(window, window.AddDate(0, 0, 7), true)
+				if len(res) != 7 {
+					b.Errorf("expected 7 occurrences, got %d", len(res))
+				}
+			}
+		})
+	}
+}
+
 func iterateNum(iter Next, num int) (last time.Time) {
 	for i := 0; i < num; i++ {
 		var ok bool
@@ -4643,6 +4690,274 @@ func TestSetRecurrence(t *testing.T) {
 	}
 }
 
+func TestSetMultipleRules(t *testing.T) {
+	r, err := Parse(
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO;COUNT=3",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,TH;BYHOUR=9,14;UNTIL=20240111T235959Z",
+		"EXDATE:20240108T140000Z",
+	)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 4, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 11, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 11, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+	}
+	if value := r.All(); !timesEqual(value, want) {
+		t.Errorf("All: get %v, want %v", value, want)
+	}
+
+	var backward []time.Time
+	for v := range r.OccurrencesBackward(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		backward = append([]time.Time{v}, backward...)
+	}
+	if !timesEqual(backward, want) {
+		t.Errorf("OccurrencesBackward: get %v, want %v", backward, want)
+	}
+	if value := r.AppendBetween(nil, want[2], want[6], true); !timesEqual(value, want[2:7]) {
+		t.Errorf("AppendBetween: get %v, want %v", value, want[2:7])
+	}
+	if got := pageThrough(t, r, 3, 100); !timesEqual(got, want) {
+		t.Errorf("pageThrough: get %v, want %v", got, want)
+	}
+	if n, finite := r.Count(); n != len(want) || !finite {
+		t.Errorf("Count: get %d, %v, want %d, true", n, finite, len(want))
+	}
+	if v, ok := r.Nth(7); !ok || !v.Equal(want[7]) {
+		t.Errorf("Nth(7): get %v, %v, want %v", v, ok, want[7])
+	}
+	if i, ok := r.IndexOf(want[5]); !ok || i != 5 {
+		t.Errorf("IndexOf: get %d, %v, want 5", i, ok)
+	}
+	for _, v := range want {
+		if !r.Contains(v) {
+			t.Errorf("Contains(%v): get false, want true", v)
+		}
+	}
+	if r.Contains(time.Date(2024, 1, 8, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("Contains: an EXDATE value is reported as an occurrence")
+	}
+
+	wantStr := `DTSTART:20240101T090000Z
+RRULE:FREQ=WEEKLY;COUNT=3;BYDAY=MO
+RRULE:FREQ=WEEKLY;UNTIL=20240111T235959Z;BYDAY=MO,TH;BYHOUR=9,14
+EXDATE:20240108T140000Z`
+	if r.String() != wantStr {
+		t.Errorf("String: get\n%s\nwant\n%s", r.String(), wantStr)
+	}
+	parsed, err := StrToRRuleSet(r.String())
+	if err != nil || parsed.String() != wantStr {
+		t.Errorf("round trip: get %v and error %v, want\n%s", parsed, err, wantStr)
+	}
+
+	rules := r.Rules()
+	if len(rules) != 2 || rules[0].Count != 3 || rules[1].Freq != WEEKLY || len(rules[1].Byhour) != 2 {
+		t.Errorf("Rules: get %+v", rules)
+	}
+
+	// Moving DTSTART moves every rule.
+	r.DTStart(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC))
+	if v := r.After(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC), false); !v.Equal(time.Date(2024, 1, 11, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("After DTStart: get %v", v)
+	}
+}
+
+func TestSetAddRule(t *testing.T) {
+	r := &Recurrence{}
+	if err := r.AddRule(ROption{Freq: WEEKLY, Byweekday: []Weekday{MO}, Byhour: []int{9}, Count: 2,
+		Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	// The second rule keeps the DTSTART of the set.
+	if err := r.AddRule(ROption{Freq: WEEKLY, Byweekday: []Weekday{TH}, Byhour: []int{14}, Count: 2,
+		Dtstart: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 4, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 11, 14, 0, 0, 0, time.UTC),
+	}
+	if value := r.All(); !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+	if err := r.AddRule(ROption{Freq: DAILY, Byhour: []int{25}}); err == nil || len(r.Rules()) != 2 {
+		t.Errorf("AddRule: get error %v and %d rules, want an error and 2 rules", err, len(r.Rules()))
+	}
+}
+
+func TestSetExRule(t *testing.T) {
+	r, err := Parse(
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=DAILY;COUNT=14",
+		"EXRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+		"RDATE:20240106T120000Z",
+	)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var want []time.Time
+	for day := 1; day <= 14; day++ {
+		if dt := time.Date(2024, 1, day, 9, 0, 0, 0, time.UTC); dt.Weekday() != time.Saturday && dt.Weekday() != time.Sunday {
+			want = append(want, dt)
+		}
+		if day == 6 {
+			// The RDATE is not at the time of the exclusion rule.
+			want = append(want, time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC))
+		}
+	}
+	if value := r.All(); !timesEqual(value, want) {
+		t.Errorf("All: get %v, want %v", value, want)
+	}
+	var backward []time.Time
+	for v := range r.OccurrencesBackward(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		backward = append([]time.Time{v}, backward...)
+	}
+	if !timesEqual(backward, want) {
+		t.Errorf("OccurrencesBackward: get %v, want %v", backward, want)
+	}
+	if value := r.AppendBetween(nil, want[3], want[8], true); !timesEqual(value, want[3:9]) {
+		t.Errorf("AppendBetween: get %v, want %v", value, want[3:9])
+	}
+	if got := pageThrough(t, r, 4, 100); !timesEqual(got, want) {
+		t.Errorf("pageThrough: get %v, want %v", got, want)
+	}
+	if n, _ := r.Count(); n != len(want) {
+		t.Errorf("Count: get %d, want %d", n, len(want))
+	}
+	if i, ok := r.IndexOf(want[7]); !ok || i != 7 {
+		t.Errorf("IndexOf: get %d, %v, want 7", i, ok)
+	}
+	if r.Contains(time.Date(2024, 1, 7, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Contains: a value of the exclusion rule is reported as an occurrence")
+	}
+	if res, err := r.AllWithLimits(DefaultLimits); err != nil || !timesEqual(res, want) {
+		t.Errorf("AllWithLimits: get %v and error %v, want %v", res, err, want)
+	}
+
+	wantStr := `DTSTART:20240101T090000Z
+RRULE:FREQ=DAILY;COUNT=14
+EXRULE:FREQ=WEEKLY;BYDAY=SA,SU
+RDATE:20240106T120000Z`
+	if r.String() != wantStr {
+		t.Errorf("String: get\n%s\nwant\n%s", r.String(), wantStr)
+	}
+	if parsed, err := StrToRRuleSet(r.String()); err != nil || parsed.String() != wantStr {
+		t.Errorf("round trip: get %v and error %v, want\n%s", parsed, err, wantStr)
+	}
+	if exrules := r.ExRules(); len(exrules) != 1 || exrules[0].Freq != WEEKLY || len(exrules[0].Byweekday) != 2 {
+		t.Errorf("ExRules: get %+v", exrules)
+	}
+}
+
+func TestSetExpandExRules(t *testing.T) {
+	r, _ := Parse(
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=DAILY",
+		"EXRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+		"EXDATE:20240106T090000Z",
+	)
+	want := r.Between(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true)
+	r.ExpandExRules(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+
+	if len(r.ExRules()) != 0 {
+		t.Errorf("ExRules: get %v, want none", r.ExRules())
+	}
+	if value := r.Between(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true); !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+	// Eight weekend days, the existing EXDATE counted once.
+	if n := len(r.GetExDate()); n != 8 {
+		t.Errorf("get %d EXDATE values, want 8: %v", n, r.GetExDate())
+	}
+	if !r.Contains(time.Date(2024, 2, 3, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("occurrences after the horizon are expected back")
+	}
+}
+
+func TestSetFloating(t *testing.T) {
+	r, err := Parse(
+		"DTSTART:20240309T090000",
+		"RRULE:FREQ=DAILY;UNTIL=20240312T090000",
+		"RDATE:20240320T183000",
+		"EXDATE:20240310T090000",
+	)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !r.IsFloating() {
+		t.Fatalf("IsFloating: get false, want true")
+	}
+	wantStr := `DTSTART:20240309T090000
+RRULE:FREQ=DAILY;UNTIL=20240312T090000
+RDATE:20240320T183000
+EXDATE:20240310T090000`
+	if r.String() != wantStr {
+		t.Errorf("String: get\n%s\nwant\n%s", r.String(), wantStr)
+	}
+	if parsed, err := StrToRRuleSet(r.String()); err != nil || parsed.String() != wantStr {
+		t.Errorf("round trip: get %v and error %v, want\n%s", parsed, err, wantStr)
+	}
+	if rules := r.Rules(); len(rules) != 1 || !rules[0].Floating {
+		t.Errorf("Rules: get %+v, want a floating rule", rules)
+	}
+
+	// The wall clock is kept in every location, across the DST change of
+	// March 10 in New York.
+	newYork, _ := time.LoadLocation("America/New_York")
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	for _, loc := range []*time.Location{newYork, tokyo, time.UTC} {
+		want := []time.Time{
+			time.Date(2024, 3, 9, 9, 0, 0, 0, loc),
+			time.Date(2024, 3, 11, 9, 0, 0, 0, loc),
+			time.Date(2024, 3, 12, 9, 0, 0, 0, loc),
+			time.Date(2024, 3, 20, 18, 30, 0, 0, loc),
+		}
+		projected := r.InLocation(loc)
+		if value := projected.All(); !timesEqual(value, want) {
+			t.Errorf("InLocation(%s).All: get %v, want %v", loc, value, want)
+		}
+		if projected.IsFloating() || !projected.Contains(want[1]) {
+			t.Errorf("InLocation(%s): get a floating set or a missing occurrence", loc)
+		}
+	}
+}
+
+func TestSetFloatingToggle(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	r, _ := newRecurrence(ROption{Freq: WEEKLY, Count: 2, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, newYork)})
+	r.RDate(time.Date(2024, 1, 3, 12, 0, 0, 0, newYork))
+	r.SetFloating(true)
+
+	want := "DTSTART:20240101T090000\nRRULE:FREQ=WEEKLY;COUNT=2\nRDATE:20240103T120000"
+	if r.String() != want {
+		t.Errorf("String: get %q, want %q", r.String(), want)
+	}
+	wantAll := []time.Time{
+		time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+	}
+	if value := r.All(); !timesEqual(value, wantAll) {
+		t.Errorf("All: get %v, want %v", value, wantAll)
+	}
+
+	// All-day sets are floating by nature and ignore the flag.
+	allDay, _ := newRecurrence(ROption{Freq: DAILY, Count: 1, AllDay: true, Dtstart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	allDay.SetFloating(true)
+	if allDay.IsFloating() || allDay.InLocation(newYork) != allDay {
+		t.Errorf("SetFloating changed an all-day set")
+	}
+}
+
 func TestSetDate(t *testing.T) {
 	r, _ := newRecurrence(ROption{Freq: YEARLY, Count: 1, Byweekday: []Weekday{TU},
 		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
@@ -5552,3 +5867,159 @@ func TestSetRRulePreservesTimezoneForTimedEvents(t *testing.T) {
 	ensureContains("RDATE;TZID=America/Los_Angeles:20241103T144500")
 	ensureContains("EXDATE;TZID=America/New_York:20241102T093000")
 }
+
+func TestOccurrences(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY,
+		Count:   5,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	r.RDate(time.Date(1997, 9, 10, 9, 0, 0, 0, time.UTC))
+	r.ExDate(time.Date(1997, 9, 4, 9, 0, 0, 0, time.UTC))
+
+	var value []time.Time
+	for dt := range r.Occurrences() {
+		value = append(value, dt)
+	}
+	want := r.All()
+	if !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+
+	// The sequence is reusable after an early break.
+	for range r.Occurrences() {
+		break
+	}
+	value = value[:0]
+	for dt := range r.Occurrences() {
+		value = append(value, dt)
+	}
+	if !timesEqual(value, want) {
+		t.Errorf("get %v after break, want %v", value, want)
+	}
+}
+
+func TestOccurrencesUnboundedBreak(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: HOURLY,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	want := []time.Time{time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 2, 10, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 2, 11, 0, 0, 0, time.UTC)}
+	var value []time.Time
+	for dt := range r.Occurrences() {
+		value = append(value, dt)
+		if len(value) == 3 {
+			break
+		}
+	}
+	if !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+}
+
+func TestIndexedOccurrences(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: WEEKLY,
+		Count:   3,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	want := r.All()
+	n := 0
+	for i, dt := range r.IndexedOccurrences() {
+		if i != n {
+			t.Errorf("get index %d, want %d", i, n)
+		}
+		if dt != want[i] {
+			t.Errorf("get %v at %d, want %v", dt, i, want[i])
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Errorf("get %d occurrences, want %d", n, len(want))
+	}
+}
+
+func TestOccurrencesBetween(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	after := time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)
+	before := time.Date(1997, 9, 6, 9, 0, 0, 0, time.UTC)
+	for _, inc := range []bool{false, true} {
+		var value []time.Time
+		for dt := range r.OccurrencesBetween(after, before, inc) {
+			value = append(value, dt)
+		}
+		want := r.Between(after, before, inc)
+		if !timesEqual(value, want) {
+			t.Errorf("inc=%v: get %v, want %v", inc, value, want)
+		}
+	}
+}
+
+func TestOccurrencesFrom(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY,
+		Count:   5,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	want := []time.Time{time.Date(1997, 9, 4, 9, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 5, 9, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 6, 9, 0, 0, 0, time.UTC)}
+	var value []time.Time
+	for dt := range r.OccurrencesFrom(time.Date(1997, 9, 4, 9, 0, 0, 0, time.UTC)) {
+		value = append(value, dt)
+	}
+	if !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+}
+
+func TestBeforeLongRunning(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: HOURLY,
+		Interval: 5,
+		Byhour:   []int{0, 5, 10},
+		Dtstart:  time.Date(1997, 9, 2, 0, 0, 0, 0, time.UTC)})
+	dt := time.Date(2024, 9, 2, 12, 0, 0, 0, time.UTC)
+	for _, inc := range []bool{false, true} {
+		var want time.Time
+		for v := range r.Occurrences() {
+			if inc && v.After(dt) || !inc && !v.Before(dt) {
+				break
+			}
+			want = v
+		}
+		if value := r.Before(dt, inc); value != want {
+			t.Errorf("inc=%v: get %v, want %v", inc, value, want)
+		}
+	}
+}
+
+func TestPrev(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: DAILY,
+		Count:   5,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	r.ExDate(time.Date(1997, 9, 4, 9, 0, 0, 0, time.UTC))
+
+	value, ok := r.Prev(time.Date(1997, 9, 5, 9, 0, 0, 0, time.UTC))
+	want := time.Date(1997, 9, 3, 9, 0, 0, 0, time.UTC)
+	if !ok || value != want {
+		t.Errorf("get %v, %v, want %v", value, ok, want)
+	}
+	value, ok = r.Prev(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	want = time.Date(1997, 9, 6, 9, 0, 0, 0, time.UTC)
+	if !ok || value != want {
+		t.Errorf("get %v, %v, want %v", value, ok, want)
+	}
+	if value, ok = r.Prev(time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("get %v, want no occurrence", value)
+	}
+}
+
+func TestOccurrencesBackward(t *testing.T) {
+	r, _ := newRecurrence(ROption{Freq: WEEKLY,
+		Dtstart: time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)})
+	want := []time.Time{time.Date(1997, 9, 16, 9, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 9, 9, 0, 0, 0, time.UTC),
+		time.Date(1997, 9, 2, 9, 0, 0, 0, time.UTC)}
+	var value []time.Time
+	for dt := range r.OccurrencesBackward(time.Date(1997, 9, 16, 9, 0, 0, 0, time.UTC)) {
+		value = append(value, dt)
+	}
+	if !timesEqual(value, want) {
+		t.Errorf("get %v, want %v", value, want)
+	}
+}
@@ -1,12 +1,13 @@
 package rrule
 
 import (
+	"context"
 	"sort"
 	"time"
 )
 
 type iterInfo struct {
-	recurrence  *Recurrence
+	recurrence  *compiledRule
 	lastyear    int
 	lastmonth   time.Month
 	yearlen     int
@@ -45,9 +46,9 @@ func (info *iterInfo) rebuild(year int, month time.Month) {
 			info.mrange = M366RANGE
 		}
 		if len(info.recurrence.byweekno) == 0 {
-			info.wnomask = nil
+			info.wnomask = info.wnomask[:0]
 		} else {
-			info.wnomask = make([]int, info.yearlen+7)
+			info.wnomask = zeroedInts(info.wnomask, info.yearlen+7)
 			firstwkst := pymod(7-info.yearweekday+info.recurrence.wkst, 7)
 			no1wkst := firstwkst
 			var wyearlen int
@@ -136,44 +137,24 @@ func (info *iterInfo) rebuild(year int, month time.Month) {
 		}
 	}
 	if len(info.recurrence.bynweekday) != 0 && (month != info.lastmonth || year != info.lastyear) {
-		var ranges [][]int
+		// Weekly frequency won't get here, so we may not
+		// care about cross-year weekly periods.
 		if info.recurrence.freq == YEARLY {
+			info.nwdaymask = zeroedInts(info.nwdaymask, info.yearlen)
 			if len(info.recurrence.bymonth) != 0 {
 				for _, month := range info.recurrence.bymonth {
-					ranges = append(ranges, info.mrange[month-1:month+1])
+					info.markNWeekdays(info.mrange[month-1], info.mrange[month])
 				}
 			} else {
-				ranges = [][]int{{0, info.yearlen}}
+				info.markNWeekdays(0, info.yearlen)
 			}
 		} else if info.recurrence.freq == MONTHLY {
-			ranges = [][]int{info.mrange[month-1 : month+1]}
-		}
-		if len(ranges) != 0 {
-			// Weekly frequency won't get here, so we may not
-			// care about cross-year weekly periods.
-			info.nwdaymask = make([]int, info.yearlen)
-			for _, x := range ranges {
-				first, last := x[0], x[1]
-				last--
-				for _, y := range info.recurrence.bynweekday {
-					wday, n := y.weekday, y.n
-					var i int
-					if n < 0 {
-						i = last + (n+1)*7
-						i -= pymod(info.wdaymask[i]-wday, 7)
-					} else {
-						i = first + (n-1)*7
-						i += pymod(7-info.wdaymask[i]+wday, 7)
-					}
-					if first <= i && i <= last {
-						info.nwdaymask[i] = 1
-					}
-				}
-			}
+			info.nwdaymask = zeroedInts(info.nwdaymask, info.yearlen)
+			info.markNWeekdays(info.mrange[month-1], info.mrange[month])
 		}
 	}
 	if len(info.recurrence.byeaster) != 0 {
-		info.eastermask = make([]int, info.yearlen+7)
+		info.eastermask = zeroedInts(info.eastermask, info.yearlen+7)
 		eyday := easter(year).YearDay() - 1
 		for _, offset := range info.recurrence.byeaster {
 			info.eastermask[eyday+offset] = 1
@@ -183,6 +164,46 @@ func (info *iterInfo) rebuild(year int, month time.Month) {
 	info.lastmonth = month
 }
 
+// markNWeekdays sets nwdaymask for the nth weekdays of BYDAY within the days
+// [first, end) of the year.
+func (info *iterInfo) markNWeekdays(first, end int) {
+	last := end - 1
+	for _, y := range info.recurrence.bynweekday {
+		wday, n := y.weekday, y.n
+		var i int
+		if n < 0 {
+			i = last + (n+1)*7
+			i -= pymod(info.wdaymask[i]-wday, 7)
+		} else {
+			i = first + (n-1)*7
+			i += pymod(7-info.wdaymask[i]+wday, 7)
+		}
+		if first <= i && i <= last {
+			info.nwdaymask[i] = 1
+		}
+	}
+}
+
+// excludes reports whether the BY* day filters reject day i of the year.
+func (info *iterInfo) excludes(i int) bool {
+	r := info.recurrence
+	return len(r.bymonth) != 0 && !contains(r.bymonth, info.mmask[i]) ||
+		len(r.byweekno) != 0 && info.wnomask[i] == 0 ||
+		len(r.byweekday) != 0 && !contains(r.byweekday, info.wdaymask[i]) ||
+		len(info.nwdaymask) != 0 && info.nwdaymask[i] == 0 ||
+		len(r.byeaster) != 0 && info.eastermask[i] == 0 ||
+		(len(r.bymonthday) != 0 || len(r.bynmonthday) != 0) &&
+			!contains(r.bymonthday, info.mdaymask[i]) &&
+			!contains(r.bynmonthday, info.nmdaymask[i]) ||
+		len(r.byyearday) != 0 &&
+			(i < info.yearlen &&
+				!contains(r.byyearday, i+1) &&
+				!contains(r.byyearday, -info.yearlen+i) ||
+				i >= info.yearlen &&
+					!contains(r.byyearday, i+1-info.yearlen) &&
+					!contains(r.byyearday, -info.nextyearlen+i-info.yearlen))
+}
+
 func (info *iterInfo) calcDaySet(freq Frequency, year int, month time.Month, day int) (start, end int) {
 	switch freq {
 	case YEARLY:
@@ -226,13 +247,13 @@ func (info *iterInfo) fillTimeSet(set *[]time.Time, freq Frequency, hour, minute
 				*set = append(*set, time.Date(1, 1, 1, hour, minute, second, 0, info.recurrence.dtstart.Location()))
 			}
 		}
-		sort.Sort(timeSlice(*set))
+		sortTimes(*set)
 	case MINUTELY:
 		prepareTimeSet(set, len(info.recurrence.bysecond))
 		for _, second := range info.recurrence.bysecond {
 			*set = append(*set, time.Date(1, 1, 1, hour, minute, second, 0, info.recurrence.dtstart.Location()))
 		}
-		sort.Sort(timeSlice(*set))
+		sortTimes(*set)
 	case SECONDLY:
 		prepareTimeSet(set, 1)
 		*set = append(*set, time.Date(1, 1, 1, hour, minute, second, 0, info.recurrence.dtstart.Location()))
@@ -257,6 +278,21 @@ type rIterator struct {
 	remain   reusingRemainSlice
 	finished bool
 	dayset   []optInt
+	// periodset holds the candidates of the current period.
+	periodset  []time.Time
+	timesetBuf []time.Time // owned timeset buffer of sub-daily rules
+	limits     Limits
+	empty      int             // consecutive periods scanned without a candidate
+	ctx        context.Context // checked every ctxCheckInterval periods when set
+	scanned    int
+	err        error // set when iteration stopped on a limit or ctx
+	// lastres is the last candidate kept. SKIP may move a date into the
+	// next period, where it must not be repeated.
+	lastres time.Time
+	// cyear and cmonth locate the YEARLY or MONTHLY period of a rule with a
+	// calendar: cmonth indexes the months of cyear, and year, month and day
+	// hold the Gregorian date of the first day of the period.
+	cyear, cmonth int
 }
 
 func (iterator *rIterator) generate() {
@@ -267,180 +303,560 @@ func (iterator *rIterator) generate() {
 	r := iterator.ii.recurrence
 
 	for iterator.remain.Len() == 0 {
-		// Get dayset with the right frequency
-		setStart, setEnd := iterator.ii.calcDaySet(r.freq, iterator.year, iterator.month, iterator.day)
-		iterator.fillDaySetMonotonic(setStart, setEnd)
+		if !iterator.checkpoint() {
+			iterator.finished = true
+			return
+		}
+		filtered := iterator.expand()
+		for _, res := range iterator.periodset {
+			if !r.until.IsZero() && res.After(r.until) {
+				iterator.finished = true
+				return
+			} else if !res.Before(r.dtstart) && (r.skip == OMIT || res.After(iterator.lastres)) {
+				iterator.lastres = res
+				iterator.total++
+				iterator.remain.Append(res)
+				if iterator.count > 0 {
+					iterator.count--
+					if iterator.count == 0 {
+						iterator.finished = true
+						return
+					}
+				}
+			}
+		}
+		if len(iterator.periodset) != 0 {
+			iterator.empty = 0
+		} else if !iterator.skipEmpty() {
+			iterator.finished = true
+			return
+		}
+		if !iterator.advance(filtered) {
+			iterator.finished = true
+			return
+		}
+		if max := iterator.limits.MaxYear; max > 0 && iterator.year > max {
+			iterator.err = &LimitError{Limit: "MaxYear", Value: max}
+			iterator.finished = true
+			return
+		}
+	}
+}
 
-		dayset := iterator.dayset
-		filtered := false
+// skipEmpty records a period without candidates. It returns false, and sets
+// err, once more than MaxEmptyPeriods consecutive empty periods were scanned
+// or ctx is done.
+func (iterator *rIterator) skipEmpty() bool {
+	iterator.empty++
+	if max := iterator.limits.MaxEmptyPeriods; max > 0 && iterator.empty > max {
+		iterator.err = &LimitError{Limit: "MaxEmptyPeriods", Value: max}
+		return false
+	}
+	return iterator.checkpoint()
+}
 
-		// Do the "hard" work ;-)
-		for dayIndex, day := range dayset {
-			i := day.Int
-			if len(r.bymonth) != 0 && !contains(r.bymonth, iterator.ii.mmask[i]) ||
-				len(r.byweekno) != 0 && iterator.ii.wnomask[i] == 0 ||
-				len(r.byweekday) != 0 && !contains(r.byweekday, iterator.ii.wdaymask[i]) ||
-				len(iterator.ii.nwdaymask) != 0 && iterator.ii.nwdaymask[i] == 0 ||
-				len(r.byeaster) != 0 && iterator.ii.eastermask[i] == 0 ||
-				(len(r.bymonthday) != 0 || len(r.bynmonthday) != 0) &&
-					!contains(r.bymonthday, iterator.ii.mdaymask[i]) &&
-					!contains(r.bynmonthday, iterator.ii.nmdaymask[i]) ||
-				len(r.byyearday) != 0 &&
-					(i < iterator.ii.yearlen &&
-						!contains(r.byyearday, i+1) &&
-						!contains(r.byyearday, -iterator.ii.yearlen+i) ||
-						i >= iterator.ii.yearlen &&
-							!contains(r.byyearday, i+1-iterator.ii.yearlen) &&
-							!contains(r.byyearday, -iterator.ii.nextyearlen+i-iterator.ii.yearlen)) {
-				dayset[dayIndex].Defined = false
-				filtered = true
-			}
+// ctxCheckInterval is the number of periods scanned between two ctx checks.
+const ctxCheckInterval = 256
+
+// checkpoint is called for every scanned period. It returns false, and sets
+// err, if ctx is done.
+func (iterator *rIterator) checkpoint() bool {
+	if iterator.ctx == nil {
+		return true
+	}
+	iterator.scanned++
+	if iterator.scanned%ctxCheckInterval != 0 {
+		return true
+	}
+	if err := iterator.ctx.Err(); err != nil {
+		iterator.err = err
+		return false
+	}
+	return true
+}
+
+// position returns the start of the period the iterator is at.
+func (iterator *rIterator) position() time.Time {
+	return time.Date(iterator.year, iterator.month, iterator.day,
+		iterator.hour, iterator.minute, iterator.second, 0,
+		iterator.ii.recurrence.dtstart.Location())
+}
+
+// expand fills periodset with the candidates of the current period in
+// ascending order, before DTSTART, UNTIL and COUNT are applied.
+// It reports whether any day of the period was filtered out.
+func (iterator *rIterator) expand() (filtered bool) {
+	r := iterator.ii.recurrence
+	iterator.periodset = iterator.periodset[:0]
+	if r.calendar != nil {
+		first, filtered := iterator.calendarDaySet()
+		year, month, day := civilDate(first)
+		iterator.output(iterator.dayset, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		return filtered
+	}
+
+	// Get dayset with the right frequency
+	setStart, setEnd := iterator.ii.calcDaySet(r.freq, iterator.year, iterator.month, iterator.day)
+	iterator.fillDaySetMonotonic(setStart, setEnd)
+
+	dayset := iterator.dayset
+
+	// Do the "hard" work ;-)
+	for dayIndex, day := range dayset {
+		if iterator.ii.excludes(day.Int) {
+			dayset[dayIndex].Defined = false
+			filtered = true
 		}
+	}
+	if r.skip != OMIT && (r.freq == YEARLY || r.freq == MONTHLY) &&
+		len(r.byyearday) == 0 && len(r.byweekno) == 0 {
+		iterator.addSkippedDays()
+		dayset = iterator.dayset
+	}
+	iterator.output(dayset, iterator.ii.firstyday)
+	return filtered
+}
 
-		// Output results
-		if len(r.bysetpos) != 0 && len(iterator.timeset) != 0 {
-			var poslist []time.Time
-			for _, pos := range r.bysetpos {
-				var daypos, timepos int
-				if pos < 0 {
-					daypos, timepos = divmod(pos, len(iterator.timeset))
-				} else {
-					daypos, timepos = divmod(pos-1, len(iterator.timeset))
-				}
-				var temp []int
-				for _, day := range dayset {
-					if day.Defined {
-						temp = append(temp, day.Int)
-					}
-				}
-				i, err := pySubscript(temp, daypos)
-				if err != nil {
-					continue
+// output appends to periodset the candidates of the defined days of dayset,
+// given as offsets from the date of first, after applying BYSETPOS.
+func (iterator *rIterator) output(dayset []optInt, first time.Time) {
+	r := iterator.ii.recurrence
+	if len(r.bysetpos) != 0 && len(iterator.timeset) != 0 {
+		for _, pos := range r.bysetpos {
+			var daypos, timepos int
+			if pos < 0 {
+				daypos, timepos = divmod(pos, len(iterator.timeset))
+			} else {
+				daypos, timepos = divmod(pos-1, len(iterator.timeset))
+			}
+			var temp []int
+			for _, day := range dayset {
+				if day.Defined {
+					temp = append(temp, day.Int)
 				}
-				timeTemp := iterator.timeset[timepos]
-				dateYear, dateMonth, dateDay := iterator.ii.firstyday.AddDate(0, 0, i).Date()
+			}
+			i, err := pySubscript(temp, daypos)
+			if err != nil {
+				continue
+			}
+			timeTemp := iterator.timeset[timepos]
+			dateYear, dateMonth, dateDay := first.AddDate(0, 0, i).Date()
+			tempHour, tempMinute, tempSecond := timeTemp.Clock()
+			res := time.Date(dateYear, dateMonth, dateDay,
+				tempHour, tempMinute, tempSecond,
+				timeTemp.Nanosecond(), timeTemp.Location())
+			if !timeContains(iterator.periodset, res) {
+				iterator.periodset = append(iterator.periodset, res)
+			}
+		}
+		sort.Sort(timeSlice(iterator.periodset))
+	} else {
+		for _, day := range dayset {
+			if !day.Defined {
+				continue
+			}
+			i := day.Int
+			dateYear, dateMonth, dateDay := first.AddDate(0, 0, i).Date()
+			for _, timeTemp := range iterator.timeset {
 				tempHour, tempMinute, tempSecond := timeTemp.Clock()
-				res := time.Date(dateYear, dateMonth, dateDay,
+				iterator.periodset = append(iterator.periodset, time.Date(dateYear, dateMonth, dateDay,
 					tempHour, tempMinute, tempSecond,
-					timeTemp.Nanosecond(), timeTemp.Location())
-				if !timeContains(poslist, res) {
-					poslist = append(poslist, res)
-				}
+					timeTemp.Nanosecond(), timeTemp.Location()))
 			}
-			sort.Sort(timeSlice(poslist))
-			for _, res := range poslist {
-				if !r.until.IsZero() && res.After(r.until) {
-					r.len = iterator.total
-					iterator.finished = true
-					return
-				} else if !res.Before(r.dtstart) {
-					iterator.total++
-					iterator.remain.Append(res)
-					if iterator.count > 0 {
-						iterator.count--
-						if iterator.count == 0 {
-							r.len = iterator.total
-							iterator.finished = true
-							return
-						}
-					}
-				}
+		}
+	}
+}
+
+// addSkippedDays merges into dayset the days that SKIP=BACKWARD or FORWARD
+// substitute for the invalid BYMONTHDAY dates of the months of the period,
+// such as February 30. RFC 7529 applies SKIP before BYSETPOS. A substitute
+// may lie in the previous or next month, and must match the BYDAY filters.
+func (iterator *rIterator) addSkippedDays() {
+	ii := &iterator.ii
+	r := ii.recurrence
+	first, last := time.January, time.December
+	if r.freq == MONTHLY {
+		first, last = iterator.month, iterator.month
+	}
+	n := len(iterator.dayset)
+	for month := first; month <= last; month++ {
+		if len(r.bymonth) != 0 && !contains(r.bymonth, int(month)) {
+			continue
+		}
+		start := ii.mrange[month-1]
+		length := ii.mrange[month] - start
+		for _, day := range r.bymonthday {
+			if day > length {
+				iterator.addSkippedDay(start+length-1, start+length)
 			}
-		} else {
-			for _, day := range dayset {
-				if !day.Defined {
-					continue
+		}
+		for _, day := range r.bynmonthday {
+			if -day > length {
+				iterator.addSkippedDay(start-1, start)
+			}
+		}
+	}
+	if len(iterator.dayset) == n {
+		return
+	}
+
+	dayset := iterator.dayset
+	sort.SliceStable(dayset, func(i, j int) bool { return dayset[i].Int < dayset[j].Int })
+	for i := 1; i < len(dayset); i++ {
+		if dayset[i].Int == dayset[i-1].Int && dayset[i-1].Defined {
+			dayset[i].Defined = false
+		}
+	}
+}
+
+// addSkippedDay adds the substitute of an invalid date, given as the day
+// before and after it, to dayset.
+func (iterator *rIterator) addSkippedDay(backward, forward int) {
+	ii := &iterator.ii
+	r := ii.recurrence
+	i := backward
+	if r.skip == FORWARD {
+		i = forward
+	}
+	if len(r.byweekday) != 0 && !contains(r.byweekday, pymod(ii.yearweekday+i, 7)) ||
+		len(ii.nwdaymask) != 0 && (i < 0 || i >= len(ii.nwdaymask) || ii.nwdaymask[i] == 0) ||
+		len(r.byeaster) != 0 && (i < 0 || i >= len(ii.eastermask) || ii.eastermask[i] == 0) {
+		return
+	}
+	iterator.dayset = append(iterator.dayset, optInt{Int: i, Defined: true})
+}
+
+// skipCrossesPeriods reports whether SKIP may move an invalid date of a
+// period into the previous or next one: a Gregorian month, or a calendar
+// month or year that may end with a short month.
+func (r *compiledRule) skipCrossesPeriods() bool {
+	return r.freq == MONTHLY || r.calendar != nil && r.freq == YEARLY
+}
+
+// calendarDaySet fills dayset with the days of the current period matching
+// the BY* day parts evaluated in the calendar of the rule, as offsets from the
+// returned first day of the period, in civilDays. Like addSkippedDays, it
+// adds the substitutes of invalid dates when SKIP is set.
+// It reports whether any day of the period was filtered out.
+func (iterator *rIterator) calendarDaySet() (first int, filtered bool) {
+	r := iterator.ii.recurrence
+	cal := r.calendar
+	iterator.dayset = iterator.dayset[:0]
+	first = civilDays(iterator.year, iterator.month, iterator.day)
+
+	if r.freq > MONTHLY {
+		n := 1
+		if r.freq == WEEKLY {
+			n = 7 - pymod(iterator.weekday-r.wkst, 7)
+		}
+		for i := 0; i < n; i++ {
+			date := calendarDate(cal, first+i)
+			if date != (CalendarDate{}) && r.matchesCalendarMonth(date.Year, date.Month) && r.matchesCalendarDay(date, first+i) {
+				iterator.dayset = append(iterator.dayset, optInt{Int: i, Defined: true})
+			} else {
+				filtered = true
+			}
+		}
+		return first, filtered
+	}
+
+	months := cal.Months(iterator.cyear)
+	if len(months) == 0 {
+		// The iterator has moved out of the range of the calendar.
+		return first, true
+	}
+	if r.freq == MONTHLY {
+		months = months[iterator.cmonth : iterator.cmonth+1]
+	}
+	skipped := false
+	start := first
+	for _, month := range months {
+		n := cal.DaysInMonth(iterator.cyear, month)
+		if !r.matchesCalendarMonth(iterator.cyear, month) {
+			start += n
+			filtered = true
+			continue
+		}
+		for day := 1; day <= n; day++ {
+			if r.matchesCalendarDay(CalendarDate{Year: iterator.cyear, Month: month, Day: day}, start+day-1) {
+				iterator.dayset = append(iterator.dayset, optInt{Int: start + day - 1 - first, Defined: true})
+			} else {
+				filtered = true
+			}
+		}
+		if r.skip != OMIT && len(r.byyearday) == 0 {
+			for _, day := range r.bymonthday {
+				if day > n {
+					skipped = iterator.addCalendarSkippedDay(first, start+n-1, start+n) || skipped
 				}
-				i := day.Int
-				dateYear, dateMonth, dateDay := iterator.ii.firstyday.AddDate(0, 0, i).Date()
-				for _, timeTemp := range iterator.timeset {
-					tempHour, tempMinute, tempSecond := timeTemp.Clock()
-					res := time.Date(dateYear, dateMonth, dateDay,
-						tempHour, tempMinute, tempSecond,
-						timeTemp.Nanosecond(), timeTemp.Location())
-					if !r.until.IsZero() && res.After(r.until) {
-						r.len = iterator.total
-						iterator.finished = true
-						return
-					} else if !res.Before(r.dtstart) {
-						iterator.total++
-						iterator.remain.Append(res)
-						if iterator.count > 0 {
-							iterator.count--
-							if iterator.count == 0 {
-								r.len = iterator.total
-								iterator.finished = true
-								return
-							}
-						}
-					}
+			}
+			for _, day := range r.bynmonthday {
+				if -day > n {
+					skipped = iterator.addCalendarSkippedDay(first, start-1, start) || skipped
 				}
 			}
 		}
-		// Handle frequency and interval
-		fixday := false
-		if r.freq == YEARLY {
-			iterator.year += r.interval
+		start += n
+	}
+	if skipped {
+		dayset := iterator.dayset
+		sort.Slice(dayset, func(i, j int) bool { return dayset[i].Int < dayset[j].Int })
+		for i := 1; i < len(dayset); i++ {
+			if dayset[i].Int == dayset[i-1].Int {
+				dayset[i].Defined = false
+			}
+		}
+	}
+	return first, filtered
+}
+
+// addCalendarSkippedDay adds the substitute of an invalid date, given as the
+// days before and after it, to dayset. It reports whether it was added.
+func (iterator *rIterator) addCalendarSkippedDay(first, backward, forward int) bool {
+	r := iterator.ii.recurrence
+	days := backward
+	if r.skip == FORWARD {
+		days = forward
+	}
+	if !r.matchesCalendarWeekday(calendarDate(r.calendar, days), days) {
+		return false
+	}
+	iterator.dayset = append(iterator.dayset, optInt{Int: days - first, Defined: true})
+	return true
+}
+
+// matchesCalendarMonth reports whether month of year passes BYMONTH. With
+// SKIP, a leap month missing from year is replaced by the month it follows
+// (BACKWARD) or the next one (FORWARD), as RFC 7529 specifies.
+func (r *compiledRule) matchesCalendarMonth(year int, month CalendarMonth) bool {
+	if len(r.bymonth) == 0 && len(r.bymonthleap) == 0 {
+		return true
+	}
+	if month.Leap {
+		return contains(r.bymonthleap, month.Number)
+	}
+	if contains(r.bymonth, month.Number) {
+		return true
+	}
+	if r.skip == OMIT {
+		return false
+	}
+	for _, leap := range r.bymonthleap {
+		if r.calendar.DaysInMonth(year, CalendarMonth{Number: leap, Leap: true}) != 0 {
+			continue
+		}
+		if r.skip == BACKWARD && month.Number == leap {
+			return true
+		}
+		if r.skip == FORWARD {
+			months := r.calendar.Months(year)
+			i := monthIndex(r.calendar, year, CalendarMonth{Number: leap})
+			if i >= 0 && i+1 < len(months) && months[i+1] == month {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesCalendarDay reports whether date, lying on days in civilDays, passes
+// the BYMONTHDAY, BYYEARDAY and BYDAY parts.
+func (r *compiledRule) matchesCalendarDay(date CalendarDate, days int) bool {
+	cal := r.calendar
+	if len(r.bymonthday) != 0 || len(r.bynmonthday) != 0 {
+		n := cal.DaysInMonth(date.Year, date.Month)
+		if !contains(r.bymonthday, date.Day) && !contains(r.bynmonthday, date.Day-n-1) {
+			return false
+		}
+	}
+	if len(r.byyearday) != 0 {
+		yday := dayOfYear(cal, date)
+		if !contains(r.byyearday, yday) && !contains(r.byyearday, yday-cal.DaysInYear(date.Year)-1) {
+			return false
+		}
+	}
+	return r.matchesCalendarWeekday(date, days)
+}
+
+// matchesCalendarWeekday reports whether date, lying on days in civilDays,
+// passes BYDAY. The nth weekdays are counted within the month for MONTHLY
+// rules and YEARLY rules with BYMONTH, and within the year otherwise.
+func (r *compiledRule) matchesCalendarWeekday(date CalendarDate, days int) bool {
+	weekday := pymod(days+3, 7) // 1970-01-01 is a Thursday
+	if len(r.byweekday) != 0 && !contains(r.byweekday, weekday) {
+		return false
+	}
+	if len(r.bynweekday) == 0 {
+		return true
+	}
+	cal := r.calendar
+	day, length := date.Day, cal.DaysInMonth(date.Year, date.Month)
+	if r.freq == YEARLY && len(r.bymonth) == 0 && len(r.bymonthleap) == 0 {
+		day, length = dayOfYear(cal, date), cal.DaysInYear(date.Year)
+	}
+	n, nn := (day-1)/7+1, -((length-day)/7 + 1)
+	for _, wday := range r.bynweekday {
+		if wday.weekday == weekday && (wday.n == n || wday.n == nn) {
+			return true
+		}
+	}
+	return false
+}
+
+// moveCalendar moves the iterator forward by units calendar years or months,
+// depending on the frequency, onto the first day of the period. It returns
+// false, and moves the iterator past MAXYEAR, once the period is out of the
+// range of the calendar.
+func (iterator *rIterator) moveCalendar(units int) bool {
+	r := iterator.ii.recurrence
+	cal := r.calendar
+	var months []CalendarMonth
+	if r.freq == YEARLY {
+		iterator.cyear += units
+		months = cal.Months(iterator.cyear)
+	} else {
+		iterator.cmonth += units
+		months = cal.Months(iterator.cyear)
+		for len(months) != 0 && iterator.cmonth >= len(months) {
+			iterator.cmonth -= len(months)
+			iterator.cyear++
+			months = cal.Months(iterator.cyear)
+		}
+	}
+	if len(months) == 0 {
+		iterator.year = MAXYEAR + 1
+		return false
+	}
+	iterator.year, iterator.month, iterator.day = cal.ToCivil(CalendarDate{Year: iterator.cyear, Month: months[iterator.cmonth], Day: 1})
+	return true
+}
+
+// calendarUnitsSince is unitsSinceCivil for the YEARLY and MONTHLY rules of
+// a calendar, counted in calendar years or months.
+func (r *compiledRule) calendarUnitsSince(year int, month time.Month, day int) int {
+	cal := r.calendar
+	date, date0 := cal.FromCivil(year, month, day), cal.FromCivil(r.dtstart.Date())
+	if r.freq == YEARLY {
+		return date.Year - date0.Year
+	}
+	units := monthIndex(cal, date.Year, date.Month) - monthIndex(cal, date0.Year, date0.Month)
+	for y := date0.Year; y < date.Year; y++ {
+		units += len(cal.Months(y))
+	}
+	for y := date.Year; y < date0.Year; y++ {
+		units -= len(cal.Months(y))
+	}
+	return units
+}
+
+// advance moves the iterator to the next period of the rule.
+// It returns false once the iterator has moved past MAXYEAR, or when
+// skipping periods that cannot match exceeds MaxEmptyPeriods.
+func (iterator *rIterator) advance(filtered bool) bool {
+	r := iterator.ii.recurrence
+
+	if r.calendar != nil && r.freq <= MONTHLY {
+		if !iterator.moveCalendar(r.interval) || iterator.year > MAXYEAR {
+			return false
+		}
+		iterator.ii.rebuild(iterator.year, iterator.month)
+		return true
+	}
+
+	// Handle frequency and interval
+	fixday := false
+	if r.freq == YEARLY {
+		iterator.year += r.interval
+		if iterator.year > MAXYEAR {
+			return false
+		}
+		iterator.ii.rebuild(iterator.year, iterator.month)
+	} else if r.freq == MONTHLY {
+		iterator.month += time.Month(r.interval)
+		if iterator.month > 12 {
+			div, mod := divmod(int(iterator.month), 12)
+			iterator.month = time.Month(mod)
+			iterator.year += div
+			if iterator.month == 0 {
+				iterator.month = 12
+				iterator.year--
+			}
 			if iterator.year > MAXYEAR {
-				r.len = iterator.total
-				iterator.finished = true
-				return
+				return false
 			}
-			iterator.ii.rebuild(iterator.year, iterator.month)
-		} else if r.freq == MONTHLY {
-			iterator.month += time.Month(r.interval)
-			if iterator.month > 12 {
-				div, mod := divmod(int(iterator.month), 12)
-				iterator.month = time.Month(mod)
-				iterator.year += div
-				if iterator.month == 0 {
-					iterator.month = 12
-					iterator.year--
-				}
-				if iterator.year > MAXYEAR {
-					r.len = iterator.total
-					iterator.finished = true
-					return
-				}
+		}
+		iterator.ii.rebuild(iterator.year, iterator.month)
+	} else if r.freq == WEEKLY {
+		if r.wkst > iterator.weekday {
+			iterator.day += -(iterator.weekday + 1 + (6 - r.wkst)) + r.interval*7
+		} else {
+			iterator.day += -(iterator.weekday - r.wkst) + r.interval*7
+		}
+		iterator.weekday = r.wkst
+		fixday = true
+	} else if r.freq == DAILY {
+		iterator.day += r.interval
+		fixday = true
+	} else if r.freq == HOURLY {
+		if filtered {
+			// Jump to one iteration before next day
+			iterator.hour += ((23 - iterator.hour) / r.interval) * r.interval
+		}
+		for {
+			iterator.hour += r.interval
+			div, mod := divmod(iterator.hour, 24)
+			if div != 0 {
+				iterator.hour = mod
+				iterator.day += div
+				fixday = true
 			}
-			iterator.ii.rebuild(iterator.year, iterator.month)
-		} else if r.freq == WEEKLY {
-			if r.wkst > iterator.weekday {
-				iterator.day += -(iterator.weekday + 1 + (6 - r.wkst)) + r.interval*7
-			} else {
-				iterator.day += -(iterator.weekday - r.wkst) + r.interval*7
+			if len(r.byhour) == 0 || contains(r.byhour, iterator.hour) {
+				break
 			}
-			iterator.weekday = r.wkst
-			fixday = true
-		} else if r.freq == DAILY {
-			iterator.day += r.interval
-			fixday = true
-		} else if r.freq == HOURLY {
-			if filtered {
-				// Jump to one iteration before next day
-				iterator.hour += ((23 - iterator.hour) / r.interval) * r.interval
-			}
-			for {
-				iterator.hour += r.interval
-				div, mod := divmod(iterator.hour, 24)
+			if !iterator.skipEmpty() {
+				return false
+			}
+		}
+		iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
+	} else if r.freq == MINUTELY {
+		if filtered {
+			// Jump to one iteration before next day
+			iterator.minute += ((1439 - (iterator.hour*60 + iterator.minute)) / r.interval) * r.interval
+		}
+		for {
+			iterator.minute += r.interval
+			div, mod := divmod(iterator.minute, 60)
+			if div != 0 {
+				iterator.minute = mod
+				iterator.hour += div
+				div, mod = divmod(iterator.hour, 24)
 				if div != 0 {
 					iterator.hour = mod
 					iterator.day += div
 					fixday = true
 				}
-				if len(r.byhour) == 0 || contains(r.byhour, iterator.hour) {
-					break
-				}
 			}
-			iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
-		} else if r.freq == MINUTELY {
-			if filtered {
-				// Jump to one iteration before next day
-				iterator.minute += ((1439 - (iterator.hour*60 + iterator.minute)) / r.interval) * r.interval
-			}
-			for {
-				iterator.minute += r.interval
-				div, mod := divmod(iterator.minute, 60)
+			if (len(r.byhour) == 0 || contains(r.byhour, iterator.hour)) &&
+				(len(r.byminute) == 0 || contains(r.byminute, iterator.minute)) {
+				break
+			}
+			if !iterator.skipEmpty() {
+				return false
+			}
+		}
+		iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
+	} else if r.freq == SECONDLY {
+		if filtered {
+			// Jump to one iteration before next day
+			iterator.second += (((86399 - (iterator.hour*3600 + iterator.minute*60 + iterator.second)) / r.interval) * r.interval)
+		}
+		for {
+			iterator.second += r.interval
+			div, mod := divmod(iterator.second, 60)
+			if div != 0 {
+				iterator.second = mod
+				iterator.minute += div
+				div, mod = divmod(iterator.minute, 60)
 				if div != 0 {
 					iterator.minute = mod
 					iterator.hour += div
@@ -451,64 +867,42 @@ func (iterator *rIterator) generate() {
 						fixday = true
 					}
 				}
-				if (len(r.byhour) == 0 || contains(r.byhour, iterator.hour)) &&
-					(len(r.byminute) == 0 || contains(r.byminute, iterator.minute)) {
-					break
-				}
 			}
-			iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
-		} else if r.freq == SECONDLY {
-			if filtered {
-				// Jump to one iteration before next day
-				iterator.second += (((86399 - (iterator.hour*3600 + iterator.minute*60 + iterator.second)) / r.interval) * r.interval)
-			}
-			for {
-				iterator.second += r.interval
-				div, mod := divmod(iterator.second, 60)
-				if div != 0 {
-					iterator.second = mod
-					iterator.minute += div
-					div, mod = divmod(iterator.minute, 60)
-					if div != 0 {
-						iterator.minute = mod
-						iterator.hour += div
-						div, mod = divmod(iterator.hour, 24)
-						if div != 0 {
-							iterator.hour = mod
-							iterator.day += div
-							fixday = true
-						}
-					}
-				}
-				if (len(r.byhour) == 0 || contains(r.byhour, iterator.hour)) &&
-					(len(r.byminute) == 0 || contains(r.byminute, iterator.minute)) &&
-					(len(r.bysecond) == 0 || contains(r.bysecond, iterator.second)) {
-					break
-				}
+			if (len(r.byhour) == 0 || contains(r.byhour, iterator.hour)) &&
+				(len(r.byminute) == 0 || contains(r.byminute, iterator.minute)) &&
+				(len(r.bysecond) == 0 || contains(r.bysecond, iterator.second)) {
+				break
+			}
+			if !iterator.skipEmpty() {
+				return false
 			}
-			iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
 		}
-		if fixday && iterator.day > 28 {
-			daysinmonth := daysIn(iterator.month, iterator.year)
-			if iterator.day > daysinmonth {
-				for iterator.day > daysinmonth {
-					iterator.day -= daysinmonth
-					iterator.month++
-					if iterator.month == 13 {
-						iterator.month = 1
-						iterator.year++
-						if iterator.year > MAXYEAR {
-							r.len = iterator.total
-							iterator.finished = true
-							return
-						}
+		iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
+	}
+	if fixday && iterator.day > 28 {
+		daysinmonth := daysIn(iterator.month, iterator.year)
+		if iterator.day > daysinmonth {
+			for iterator.day > daysinmonth {
+				iterator.day -= daysinmonth
+				iterator.month++
+				if iterator.month == 13 {
+					iterator.month = 1
+					iterator.year++
+					if iterator.year > MAXYEAR {
+						return false
 					}
-					daysinmonth = daysIn(iterator.month, iterator.year)
 				}
-				iterator.ii.rebuild(iterator.year, iterator.month)
+				daysinmonth = daysIn(iterator.month, iterator.year)
 			}
+			iterator.ii.rebuild(iterator.year, iterator.month)
 		}
 	}
+	if fixday && r.calendar != nil {
+		if last, ok := r.calendarLastDay(); ok && civilDays(iterator.year, iterator.month, iterator.day) > last {
+			return false
+		}
+	}
+	return true
 }
 
 func (iterator *rIterator) fillDaySetMonotonic(start, end int) {
@@ -528,6 +922,222 @@ func (iterator *rIterator) fillDaySetMonotonic(start, end int) {
 	}
 }
 
+// seekMargin is subtracted from a seek target before locating its period, so
+// that wall-clock shifts around DST transitions never skip an occurrence.
+const seekMargin = 2 * time.Hour
+
+// unitsSince returns the number of frequency units (years, months, weeks, days,
+// hours, minutes or seconds) between the period containing DTSTART and the
+// period containing dt, counted in DTSTART's wall clock.
+func (r *compiledRule) unitsSince(dt time.Time) int {
+	dt = dt.In(r.dtstart.Location())
+	year, month, day := dt.Date()
+	hour, minute, second := dt.Clock()
+	return r.unitsSinceCivil(year, month, day, hour, minute, second)
+}
+
+// unitsSinceCivil is unitsSince for a wall-clock time in DTSTART's location.
+func (r *compiledRule) unitsSinceCivil(year int, month time.Month, day, hour, minute, second int) int {
+	if r.calendar != nil && r.freq <= MONTHLY {
+		return r.calendarUnitsSince(year, month, day)
+	}
+	year0, month0, day0 := r.dtstart.Date()
+	hour0, minute0, second0 := r.dtstart.Clock()
+	days := civilDays(year, month, day) - civilDays(year0, month0, day0)
+
+	switch r.freq {
+	case YEARLY:
+		return year - year0
+	case MONTHLY:
+		return (year-year0)*12 + int(month-month0)
+	case WEEKLY:
+		weekday := toPyWeekday(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday())
+		weekday0 := toPyWeekday(r.dtstart.Weekday())
+		days += pymod(weekday0-r.wkst, 7) - pymod(weekday-r.wkst, 7)
+		div, _ := divmod(days, 7)
+		return div
+	case DAILY:
+		return days
+	case HOURLY:
+		return days*24 + hour - hour0
+	case MINUTELY:
+		return (days*24+hour-hour0)*60 + minute - minute0
+	default:
+		return ((days*24+hour-hour0)*60+minute-minute0)*60 + second - second0
+	}
+}
+
+// seekUnits returns the offset, in frequency units and aligned to INTERVAL,
+// of the first period that may yield an occurrence at or after dt.
+func (r *compiledRule) seekUnits(dt time.Time) int {
+	div, _ := divmod(r.unitsSince(dt.Add(-seekMargin)), r.interval)
+	if r.skip == FORWARD && r.skipCrossesPeriods() {
+		// The previous period may move an invalid date into this one.
+		div--
+	}
+	if div < 0 {
+		return 0
+	}
+	return div * r.interval
+}
+
+// seek positions the iterator on the period lying units frequency units after
+// the period containing DTSTART, and rebuilds the year masks for it only.
+// units must be a multiple of the rule interval.
+func (iterator *rIterator) seek(units int) {
+	r := iterator.ii.recurrence
+	iterator.year, iterator.month, iterator.day = r.dtstart.Date()
+	if r.allDay {
+		iterator.hour, iterator.minute, iterator.second = 0, 0, 0
+	} else {
+		iterator.hour, iterator.minute, iterator.second = r.dtstart.Clock()
+	}
+	iterator.weekday = toPyWeekday(r.dtstart.Weekday())
+
+	if r.calendar != nil && r.freq <= MONTHLY {
+		date := r.calendar.FromCivil(iterator.year, iterator.month, iterator.day)
+		iterator.cyear, iterator.cmonth = date.Year, 0
+		if r.freq == MONTHLY {
+			iterator.cmonth = monthIndex(r.calendar, date.Year, date.Month)
+		}
+		iterator.moveCalendar(units)
+	} else if units != 0 {
+		days := civilDays(iterator.year, iterator.month, iterator.day)
+		switch r.freq {
+		case YEARLY:
+			iterator.year += units
+		case MONTHLY:
+			div, mod := divmod(int(iterator.month)-1+units, 12)
+			iterator.year += div
+			iterator.month = time.Month(mod + 1)
+		case WEEKLY:
+			days += units*7 - pymod(iterator.weekday-r.wkst, 7)
+			iterator.year, iterator.month, iterator.day = civilDate(days)
+			iterator.weekday = r.wkst
+		case DAILY:
+			iterator.year, iterator.month, iterator.day = civilDate(days + units)
+		case HOURLY:
+			div, mod := divmod(iterator.hour+units, 24)
+			iterator.hour = mod
+			iterator.year, iterator.month, iterator.day = civilDate(days + div)
+		case MINUTELY:
+			div, mod := divmod(iterator.hour*60+iterator.minute+units, 1440)
+			iterator.hour, iterator.minute = mod/60, mod%60
+			iterator.year, iterator.month, iterator.day = civilDate(days + div)
+		case SECONDLY:
+			div, mod := divmod(iterator.hour*3600+iterator.minute*60+iterator.second+units, 86400)
+			iterator.hour, iterator.minute, iterator.second = mod/3600, mod/60%60, mod%60
+			iterator.year, iterator.month, iterator.day = civilDate(days + div)
+		}
+	}
+
+	iterator.ii.rebuild(iterator.year, iterator.month)
+
+	if r.freq < HOURLY {
+		iterator.timeset = r.timeset
+	} else {
+		iterator.timeset = iterator.timesetBuf[:0]
+		filtered := r.freq >= HOURLY && len(r.byhour) != 0 && !contains(r.byhour, iterator.hour) ||
+			r.freq >= MINUTELY && len(r.byminute) != 0 && !contains(r.byminute, iterator.minute) ||
+			r.freq >= SECONDLY && len(r.bysecond) != 0 && !contains(r.bysecond, iterator.second)
+		if !filtered {
+			iterator.ii.fillTimeSet(&iterator.timeset, r.freq, iterator.hour, iterator.minute, iterator.second)
+			iterator.timesetBuf = iterator.timeset
+		}
+	}
+}
+
+// reset positions the iterator like Recurrence.ruleIteratorFrom, keeping the
+// buffers of a previous use. It returns false if r has no period at or after dt.
+func (iterator *rIterator) reset(r *compiledRule, dt time.Time, limits Limits) bool {
+	units := 0
+	if r.count == 0 && dt.After(r.dtstart) {
+		if dt.After(r.until) {
+			return false
+		}
+		if last, ok := r.calendarLastDay(); ok && civilDays(dt.In(r.dtstart.Location()).Date()) > last {
+			return false
+		}
+		units = r.seekUnits(dt)
+	}
+
+	ii := iterator.ii
+	*iterator = rIterator{
+		ii: iterInfo{
+			recurrence: r,
+			wnomask:    ii.wnomask[:0],
+			nwdaymask:  ii.nwdaymask[:0],
+			eastermask: ii.eastermask[:0],
+		},
+		timesetBuf: iterator.timesetBuf,
+		count:      r.count,
+		remain:     reusingRemainSlice{storage: iterator.remain.backup[:0], backup: iterator.remain.backup[:0]},
+		dayset:     iterator.dayset[:0],
+		periodset:  iterator.periodset[:0],
+		limits:     limits,
+	}
+	iterator.seek(units)
+	return true
+}
+
+// rBackIterator walks the periods of a rule backwards, from the period
+// containing a given point down to the period containing DTSTART.
+type rBackIterator struct {
+	rIterator
+	units   int         // offset of the next period to expand, -1 once DTSTART's period is done
+	pending []time.Time // candidates of the last expanded period, ascending
+	floor   time.Time   // first candidate of the periods already expanded
+}
+
+func (iterator *rBackIterator) next() (time.Time, bool) {
+	r := iterator.ii.recurrence
+	for len(iterator.pending) == 0 {
+		if iterator.units < 0 {
+			return time.Time{}, false
+		}
+		iterator.seek(iterator.units)
+		filtered := iterator.expand()
+		for _, res := range iterator.periodset {
+			if !res.Before(r.dtstart) && (r.until.IsZero() || !res.After(r.until)) &&
+				(r.skip == OMIT || iterator.floor.IsZero() || res.Before(iterator.floor)) {
+				iterator.pending = append(iterator.pending, res)
+			}
+		}
+		if len(iterator.pending) != 0 {
+			iterator.floor = iterator.pending[0]
+		}
+		if len(iterator.periodset) != 0 {
+			iterator.empty = 0
+		} else if !iterator.skipEmpty() {
+			return time.Time{}, false
+		}
+		iterator.units = iterator.previous(filtered)
+	}
+	res := iterator.pending[len(iterator.pending)-1]
+	iterator.pending = iterator.pending[:len(iterator.pending)-1]
+	return res, true
+}
+
+// previous returns the offset of the period preceding the current one.
+func (iterator *rBackIterator) previous(filtered bool) int {
+	r := iterator.ii.recurrence
+	units := iterator.units
+	if filtered && r.freq >= HOURLY {
+		// The whole day was filtered: jump to its first period.
+		var elapsed int
+		switch r.freq {
+		case HOURLY:
+			elapsed = iterator.hour
+		case MINUTELY:
+			elapsed = iterator.hour*60 + iterator.minute
+		default:
+			elapsed = iterator.hour*3600 + iterator.minute*60 + iterator.second
+		}
+		units -= elapsed / r.interval * r.interval
+	}
+	return units - r.interval
+}
+
 // next returns next occurrence and true if it exists, else zero value and false
 func (iterator *rIterator) next() (time.Time, bool) {
 	iterator.generate()
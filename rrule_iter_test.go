@@ -1519,3 +1519,277 @@ func TestIteratorConcurrency(t *testing.T) {
 		}
 	}
 }
+
+// seekTestOptions returns unbounded rules covering every frequency, used to
+// compare seeked iteration against a full replay from DTSTART.
+func seekTestOptions(t *testing.T) []ROption {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	return []ROption{
+		{Freq: YEARLY, Dtstart: time.Date(2005, 2, 28, 9, 0, 0, 0, time.UTC)},
+		{Freq: YEARLY, Interval: 3, Bymonth: []int{1, 6}, Byweekday: []Weekday{MO.Nth(1), FR.Nth(-1)}, Dtstart: time.Date(2003, 1, 6, 9, 0, 0, 0, newYork)},
+		{Freq: YEARLY, Byweekno: []int{1, 20, -1}, Byweekday: []Weekday{MO}, Dtstart: time.Date(2004, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: YEARLY, Byyearday: []int{1, 100, -1}, Dtstart: time.Date(2004, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: YEARLY, Byeaster: []int{0, -2}, Dtstart: time.Date(2004, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Dtstart: time.Date(2005, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Interval: 5, Bysetpos: []int{-1}, Byweekday: []Weekday{MO, TU, WE, TH, FR}, Dtstart: time.Date(2005, 3, 1, 18, 0, 0, 0, newYork)},
+		{Freq: MONTHLY, Bymonthday: []int{1, 15, -1}, Byhour: []int{8, 20}, Dtstart: time.Date(2005, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{Freq: WEEKLY, Dtstart: time.Date(2005, 3, 2, 9, 30, 0, 0, newYork)},
+		{Freq: WEEKLY, Interval: 3, Wkst: SU, Byweekday: []Weekday{SU, TU, SA}, Dtstart: time.Date(2005, 3, 2, 9, 30, 0, 0, time.UTC)},
+		{Freq: WEEKLY, Interval: 2, Byweekday: []Weekday{MO, SU}, Dtstart: time.Date(2005, 3, 2, 0, 0, 0, 0, time.UTC), AllDay: true},
+		{Freq: DAILY, Dtstart: time.Date(2005, 3, 2, 2, 30, 0, 0, newYork)},
+		{Freq: DAILY, Interval: 17, Bymonth: []int{3, 11}, Dtstart: time.Date(2005, 3, 2, 0, 0, 0, 0, time.UTC), AllDay: true},
+		{Freq: HOURLY, Interval: 7, Dtstart: time.Date(2005, 3, 2, 1, 15, 0, 0, newYork)},
+		{Freq: HOURLY, Interval: 5, Byhour: []int{1, 2, 3, 13}, Byweekday: []Weekday{SA}, Dtstart: time.Date(2005, 3, 2, 1, 0, 0, 0, time.UTC)},
+		{Freq: MINUTELY, Interval: 97, Byminute: []int{0, 30, 45}, Dtstart: time.Date(2005, 3, 2, 1, 0, 0, 0, newYork)},
+		{Freq: SECONDLY, Interval: 7919, Dtstart: time.Date(2005, 3, 2, 1, 0, 5, 0, time.UTC)},
+	}
+}
+
+// TestIteratorSeekMatchesReplay verifies that seeking directly to a window gives
+// the same occurrences as replaying the rule from DTSTART.
+func TestIteratorSeekMatchesReplay(t *testing.T) {
+	windows := []struct {
+		after, before time.Time
+	}{
+		{time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2005, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2007, 3, 10, 5, 0, 0, 0, time.UTC), time.Date(2007, 3, 12, 12, 0, 0, 0, time.UTC)},
+		{time.Date(2008, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2008, 11, 8, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2012, 12, 24, 0, 0, 0, 0, time.UTC), time.Date(2013, 4, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2015, 6, 30, 23, 59, 59, 0, time.UTC), time.Date(2015, 7, 1, 0, 0, 1, 0, time.UTC)},
+	}
+
+	for _, opt := range seekTestOptions(t) {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		r.RDate(time.Date(2007, 3, 11, 3, 0, 0, 0, time.UTC))
+		r.ExDate(r.After(time.Date(2008, 11, 1, 0, 0, 0, 0, time.UTC), true))
+		for _, w := range windows {
+			for _, inc := range []bool{false, true} {
+				want := between(r.Occurrences(), w.after, w.before, inc)
+				got := r.Between(w.after, w.before, inc)
+				if !timesEqual(got, want) {
+					t.Errorf("%s between %v and %v (inc=%v): got %v, want %v",
+						r.RRuleString(), w.after, w.before, inc, got, want)
+				}
+			}
+			want := after(r.Occurrences(), w.after, false)
+			if got := r.After(w.after, false); got != want {
+				t.Errorf("%s after %v: got %v, want %v", r.RRuleString(), w.after, got, want)
+			}
+		}
+	}
+}
+
+// TestIteratorSeekUntil verifies that seeking past UNTIL yields nothing.
+func TestIteratorSeekUntil(t *testing.T) {
+	r, err := newRecurrence(ROption{
+		Freq:    DAILY,
+		Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC),
+		Until:   time.Date(2006, 3, 2, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create RRule: %v", err)
+	}
+	if got := r.After(time.Date(2006, 3, 2, 0, 0, 0, 0, time.UTC), false); !got.Equal(time.Date(2006, 3, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("After: got %v", got)
+	}
+	if got := r.Between(time.Date(2007, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC), true); len(got) != 0 {
+		t.Errorf("Between after UNTIL: got %v", got)
+	}
+}
+
+// TestIteratorSteadyStateAllocations verifies that advancing a set iterator
+// does not allocate once it has been created.
+func TestIteratorSteadyStateAllocations(t *testing.T) {
+	for _, freq := range []Frequency{YEARLY, MONTHLY, WEEKLY, DAILY, HOURLY, MINUTELY, SECONDLY} {
+		r, err := newRecurrence(ROption{
+			Freq:    freq,
+			Dtstart: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create RRule: %v", err)
+		}
+		start := r.GetDTStart()
+		for i := 0; i < 300; i++ {
+			r.RDate(start.Add(time.Duration(i)*37*time.Hour + time.Minute))
+			r.ExDate(start.Add(time.Duration(i) * 53 * time.Hour))
+		}
+
+		next := r.Iterator()
+		next() // warm up the reusable buffers
+		allocs := testing.AllocsPerRun(200, func() {
+			if _, ok := next(); !ok {
+				t.Fatalf("%v: iterator ended prematurely", freq)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%v: got %v allocations per occurrence, want 0", freq, allocs)
+		}
+	}
+}
+
+// TestIteratorBackwardMatchesForward verifies that walking backwards yields the
+// forward occurrences in reverse order.
+func TestIteratorBackwardMatchesForward(t *testing.T) {
+	options := seekTestOptions(t)
+	options = append(options,
+		ROption{Freq: DAILY, Count: 40, Interval: 3, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		ROption{Freq: MONTHLY, Count: 12, Bysetpos: []int{2}, Byweekday: []Weekday{SA, SU}, Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+		ROption{Freq: WEEKLY, Until: time.Date(2006, 3, 2, 9, 0, 0, 0, time.UTC), Dtstart: time.Date(2005, 3, 2, 9, 0, 0, 0, time.UTC)},
+	)
+	from := time.Date(2006, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, opt := range options {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		r.RDate(time.Date(2005, 7, 4, 12, 0, 0, 0, time.UTC))
+		r.RDate(time.Date(2007, 7, 4, 12, 0, 0, 0, time.UTC))
+		r.ExDate(r.After(time.Date(2005, 11, 1, 0, 0, 0, 0, time.UTC), true))
+
+		forward := between(r.Occurrences(), r.GetDTStart(), from, true)
+		var want []time.Time
+		for i := len(forward) - 1; i >= 0; i-- {
+			want = append(want, forward[i])
+		}
+		var got []time.Time
+		next := r.IteratorBackward(from)
+		for v, ok := next(); ok; v, ok = next() {
+			got = append(got, v)
+		}
+		if !timesEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", r.RRuleString(), got, want)
+		}
+	}
+}
+
+// TestIteratorBackwardEmpty verifies backward iteration before DTSTART.
+func TestIteratorBackwardEmpty(t *testing.T) {
+	r, err := newRecurrence(ROption{Freq: HOURLY, Dtstart: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("Failed to create RRule: %v", err)
+	}
+	next := r.IteratorBackward(time.Date(2020, 1, 1, 9, 59, 59, 0, time.UTC))
+	if v, ok := next(); ok {
+		t.Errorf("got %v, want no occurrence", v)
+	}
+}
+
+// TestIteratorSkip tests the RFC 7529 SKIP rule part.
+func TestIteratorSkip(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		opt      ROption
+		expected []time.Time
+	}{
+		{
+			name:     "monthly_31st_omit",
+			opt:      ROption{Freq: MONTHLY, Count: 4, Dtstart: day(2015, 1, 31)},
+			expected: []time.Time{day(2015, 1, 31), day(2015, 3, 31), day(2015, 5, 31), day(2015, 7, 31)},
+		},
+		{
+			name:     "monthly_31st_backward",
+			opt:      ROption{Freq: MONTHLY, Count: 5, Skip: BACKWARD, Dtstart: day(2015, 1, 31)},
+			expected: []time.Time{day(2015, 1, 31), day(2015, 2, 28), day(2015, 3, 31), day(2015, 4, 30), day(2015, 5, 31)},
+		},
+		{
+			name:     "monthly_31st_forward",
+			opt:      ROption{Freq: MONTHLY, Count: 5, Skip: FORWARD, Dtstart: day(2015, 1, 31)},
+			expected: []time.Time{day(2015, 1, 31), day(2015, 3, 1), day(2015, 3, 31), day(2015, 5, 1), day(2015, 5, 31)},
+		},
+		{
+			name:     "yearly_feb_29_backward",
+			opt:      ROption{Freq: YEARLY, Count: 5, Skip: BACKWARD, Dtstart: day(2016, 2, 29)},
+			expected: []time.Time{day(2016, 2, 29), day(2017, 2, 28), day(2018, 2, 28), day(2019, 2, 28), day(2020, 2, 29)},
+		},
+		{
+			name:     "yearly_feb_29_forward",
+			opt:      ROption{Freq: YEARLY, Count: 3, Skip: FORWARD, Dtstart: day(2016, 2, 29)},
+			expected: []time.Time{day(2016, 2, 29), day(2017, 3, 1), day(2018, 3, 1)},
+		},
+		{
+			// Substitutes are merged with the valid days before BYSETPOS.
+			name: "bysetpos_after_skip",
+			opt: ROption{Freq: MONTHLY, Count: 4, Skip: FORWARD, Bymonthday: []int{29, 30}, Bysetpos: []int{1},
+				Dtstart: day(2015, 1, 1)},
+			expected: []time.Time{day(2015, 1, 29), day(2015, 3, 1), day(2015, 3, 29), day(2015, 4, 29)},
+		},
+		{
+			// Several invalid dates moved to the same day give one occurrence.
+			name: "merged_substitutes",
+			opt: ROption{Freq: MONTHLY, Count: 3, Skip: BACKWARD, Bymonthday: []int{29, 30, 31},
+				Dtstart: day(2015, 2, 1)},
+			expected: []time.Time{day(2015, 2, 28), day(2015, 3, 29), day(2015, 3, 30)},
+		},
+		{
+			// A substitute moved into the next month is not repeated by it.
+			name: "forward_duplicate",
+			opt: ROption{Freq: MONTHLY, Count: 4, Skip: FORWARD, Bymonthday: []int{1, 30},
+				Dtstart: day(2015, 2, 1)},
+			expected: []time.Time{day(2015, 2, 1), day(2015, 3, 1), day(2015, 3, 30), day(2015, 4, 1)},
+		},
+		{
+			name: "substitute_matches_byday",
+			opt: ROption{Freq: MONTHLY, Count: 2, Skip: BACKWARD, Bymonthday: []int{31}, Byweekday: []Weekday{SA},
+				Dtstart: day(2015, 1, 1)},
+			expected: []time.Time{day(2015, 1, 31), day(2015, 2, 28)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRecurrence(tt.opt)
+			if err != nil {
+				t.Fatalf("Failed to create RRule: %v", err)
+			}
+			result := r.All()
+			if !timesEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestIteratorSkipSeek verifies that seeking and walking backwards find the
+// dates SKIP moves across periods.
+func TestIteratorSkipSeek(t *testing.T) {
+	for _, opt := range []ROption{
+		{Freq: MONTHLY, Skip: FORWARD, Dtstart: time.Date(2015, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Skip: BACKWARD, Bymonthday: []int{-31}, Dtstart: time.Date(2015, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{Freq: MONTHLY, Skip: BACKWARD, Interval: 2, Bymonthday: []int{31}, Dtstart: time.Date(2015, 1, 1, 9, 0, 0, 0, time.UTC)},
+	} {
+		r, err := newRecurrence(opt)
+		if err != nil {
+			t.Fatalf("newRecurrence(%v) failed: %v", opt, err)
+		}
+		end := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		all := between(r.Occurrences(), r.GetDTStart(), end, true)
+		for _, v := range all {
+			if got := r.Between(v, v, true); len(got) != 1 || !got[0].Equal(v) {
+				t.Errorf("%s: Between(%v): got %v", r.RRuleString(), v, got)
+			}
+			if got := r.Before(v, true); !got.Equal(v) {
+				t.Errorf("%s: Before(%v): got %v", r.RRuleString(), v, got)
+			}
+			if !r.Contains(v) {
+				t.Errorf("%s: Contains(%v): got false", r.RRuleString(), v)
+			}
+		}
+		var backward []time.Time
+		for v := range r.OccurrencesBackward(end) {
+			backward = append(backward, v)
+		}
+		if len(backward) != len(all) {
+			t.Errorf("%s: backward got %v, want %v reversed", r.RRuleString(), backward, all)
+		}
+	}
+}
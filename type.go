@@ -70,6 +70,36 @@ func StrToFreq(str string) (Frequency, error) {
 	return result, nil
 }
 
+// Skip tells how a rule handles the invalid dates it generates, such as
+// February 30 for BYMONTHDAY=30, as the RFC 7529 SKIP rule part.
+type Skip int
+
+// Constants
+const (
+	// OMIT drops invalid dates. It is the default.
+	OMIT Skip = iota
+	// BACKWARD moves an invalid date to the previous valid day.
+	BACKWARD
+	// FORWARD moves an invalid date to the next valid day.
+	FORWARD
+)
+
+func (s Skip) String() string {
+	return [...]string{"OMIT", "BACKWARD", "FORWARD"}[s]
+}
+
+// StrToSkip parses the value of the SKIP rule part.
+func StrToSkip(str string) (Skip, error) {
+	skipMap := map[string]Skip{
+		"OMIT": OMIT, "BACKWARD": BACKWARD, "FORWARD": FORWARD,
+	}
+	result, ok := skipMap[str]
+	if !ok {
+		return 0, errors.New("undefined skip: " + str)
+	}
+	return result, nil
+}
+
 // Weekday specifying the nth weekday.
 // Field N could be positive or negative (like MO(+2) or MO(-3).
 // Not specifying N (0) is the same as specifying +1.
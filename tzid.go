@@ -0,0 +1,263 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// ErrUnnamedLocation is returned, wrapped, by Lines under TZIDError when a
+// time is in a location without a TZID other clients can resolve.
+var ErrUnnamedLocation = errors.New("rrule: location has no resolvable TZID")
+
+// TZIDPolicy decides how a recurrence writes the times of a location whose
+// name is not a TZID other clients can resolve: the locations made by
+// time.FixedZone, time.Local, and those DefaultLocationResolver does not
+// find with the same offset.
+type TZIDPolicy int
+
+const (
+	// TZIDSynthetic writes such times with a synthetic TZID, defined by a
+	// VTIMEZONE component that Lines appends after the recurrence lines.
+	// Fixed offsets are named like "UTC+0800", which FixedOffsetLocations
+	// also resolves; other locations are named after their offset at the
+	// start of the recurrence and a hash of their transitions, such as
+	// "UTC-0500/1A2B3C4D". A fixed offset of zero is written as UTC.
+	TZIDSynthetic TZIDPolicy = iota
+	// TZIDUTC writes such times in UTC with a trailing Z. The instants are
+	// kept, but rules read back are expanded in UTC.
+	TZIDUTC
+	// TZIDError makes Lines return an error wrapping ErrUnnamedLocation.
+	// Strings, String and the helpers such as DTStartString, which cannot
+	// return it, return empty values instead.
+	TZIDError
+)
+
+// SetTZIDPolicy sets how the recurrence writes times in locations without a
+// resolvable TZID. The default is TZIDSynthetic.
+func (set *Recurrence) SetTZIDPolicy(policy TZIDPolicy) {
+	set.tzidPolicy = policy
+}
+
+// GetTZIDPolicy returns how the recurrence writes times in locations without
+// a resolvable TZID.
+func (set *Recurrence) GetTZIDPolicy() TZIDPolicy {
+	return set.tzidPolicy
+}
+
+// Lines returns the lines of the recurrence, as Strings does, following its
+// TZIDPolicy. Under TZIDSynthetic, the VTIMEZONE components defining the
+// synthetic TZIDs follow the recurrence properties; Parse reads them back.
+func (set *Recurrence) Lines() ([]string, error) {
+	w := set.tzidWriter()
+	lines := set.lines(w)
+	if w.err != nil {
+		return nil, w.err
+	}
+	if len(w.synthetic) > 0 {
+		from, to := set.span()
+		for _, loc := range w.synthetic {
+			lines = append(lines, VTimezone(w.tzids[loc], loc, from, to)...)
+		}
+	}
+	return lines, nil
+}
+
+// Properties returns the DTSTART, DURATION, RRULE, EXRULE, RDATE and EXDATE
+// lines of the recurrence, as Lines does but without VTIMEZONE components:
+// the lines of an event, whose TZIDs VTimezones defines.
+func (set *Recurrence) Properties() ([]string, error) {
+	w := set.tzidWriter()
+	lines := set.lines(w)
+	if w.err != nil {
+		return nil, w.err
+	}
+	return lines, nil
+}
+
+// VTimezones returns a VTIMEZONE component for each TZID of the lines of the
+// recurrence, named or synthetic, for clients without the IANA database. Each
+// covers the transitions of its location between the first and the last
+// value of the recurrence; see VTimezone. Rules without COUNT or UNTIL are
+// covered until 2100, past which the yearly rules of the components go on.
+func (set *Recurrence) VTimezones() ([]string, error) {
+	w := set.tzidWriter()
+	set.lines(w)
+	if w.err != nil {
+		return nil, w.err
+	}
+	var lines []string
+	from, to := set.span()
+	for _, loc := range w.used {
+		lines = append(lines, VTimezone(w.tzids[loc], loc, from, to)...)
+	}
+	return lines, nil
+}
+
+// tzidWriter chooses the TZIDs of the times written by one serialization of
+// a recurrence. A nil tzidWriter writes location names as they are, for the
+// lines parsed back internally.
+type tzidWriter struct {
+	set       *Recurrence
+	policy    TZIDPolicy
+	tzids     map[*time.Location]string // "" for UTC
+	used      []*time.Location          // written with a TZID, in order of first use
+	synthetic []*time.Location          // written with a synthetic TZID
+	err       error
+}
+
+// tzidWriter returns a writer following the TZIDPolicy of set.
+func (set *Recurrence) tzidWriter() *tzidWriter {
+	return &tzidWriter{set: set, policy: set.tzidPolicy}
+}
+
+// tzid returns the TZID t is written with, or "" when it is written in UTC
+// with a trailing Z.
+func (w *tzidWriter) tzid(t time.Time) string {
+	loc := t.Location()
+	if loc.String() == "UTC" {
+		return ""
+	}
+	if w == nil {
+		return loc.String()
+	}
+	if tzid, ok := w.tzids[loc]; ok {
+		return tzid
+	}
+	if w.tzids == nil {
+		w.tzids = map[*time.Location]string{}
+	}
+	tzid := loc.String()
+	if !resolvable(loc, t) {
+		switch w.policy {
+		case TZIDUTC:
+			tzid = ""
+		case TZIDError:
+			if w.err == nil {
+				w.err = fmt.Errorf("%w: %q", ErrUnnamedLocation, loc.String())
+			}
+		default:
+			tzid = w.syntheticTZID(loc)
+			if tzid != "" {
+				w.synthetic = append(w.synthetic, loc)
+			}
+		}
+	}
+	w.tzids[loc] = tzid
+	if tzid != "" {
+		w.used = append(w.used, loc)
+	}
+	return tzid
+}
+
+// checked returns str, or "" when w failed to write one of its times.
+func (w *tzidWriter) checked(str string) string {
+	if w.err != nil {
+		return ""
+	}
+	return str
+}
+
+// format returns the TZID parameter and the value of t, such as
+// ";TZID=Asia/Shanghai:20240101T090000" or ":20240101T010000Z".
+func (w *tzidWriter) format(t time.Time) string {
+	if tzid := w.tzid(t); tzid != "" {
+		return tzidParam(tzid) + ":" + t.Format(LocalDateTimeFormat)
+	}
+	return ":" + timeToUTCStr(t)
+}
+
+// syntheticTZID returns the synthetic TZID of loc over the span of the
+// recurrence, or "" when loc is UTC throughout.
+func (w *tzidWriter) syntheticTZID(loc *time.Location) string {
+	from, to := w.set.span()
+	initial, transitions := zoneTransitions(loc, from, to)
+	if len(transitions) == 0 {
+		if initial.offset == 0 {
+			return ""
+		}
+		return fixedOffsetTZID(initial.offset)
+	}
+	h := fnv.New32a()
+	fmt.Fprint(h, initial.offset)
+	for _, t := range transitions {
+		fmt.Fprintf(h, ",%d:%d", t.at.Unix(), t.zone.offset)
+	}
+	return fmt.Sprintf("%s/%08X", fixedOffsetTZID(initial.offset), h.Sum32())
+}
+
+// resolvable reports whether DefaultLocationResolver finds the name of loc,
+// with the offset of loc at t. Synthetic TZIDs read back stay synthetic, so
+// that their VTIMEZONE is written again.
+func resolvable(loc *time.Location, t time.Time) bool {
+	name := loc.String()
+	if name == "" || name == "Local" || fixedOffsetPattern.MatchString(name) {
+		return false
+	}
+	resolved, err := DefaultLocationResolver.ResolveLocation(name)
+	if err != nil {
+		return false
+	}
+	_, offset := t.In(loc).Zone()
+	_, resolvedOffset := t.In(resolved).Zone()
+	return offset == resolvedOffset
+}
+
+// tzidParam returns the TZID parameter of tzid, quoted when it holds
+// characters that end a parameter value.
+func tzidParam(tzid string) string {
+	if strings.ContainsAny(tzid, ";:,") {
+		tzid = `"` + tzid + `"`
+	}
+	return ";TZID=" + tzid
+}
+
+// fixedOffsetTZID returns the synthetic TZID of a fixed offset, such as
+// "UTC+0800" or "UTC-0330".
+func fixedOffsetTZID(offset int) string {
+	return "UTC" + utcOffsetString(offset)
+}
+
+// span returns the times between which the recurrence has values: its
+// DTSTART, RDATEs, EXDATEs and the occurrences of its rules. Rules without
+// COUNT or UNTIL are taken to run until transitionHorizon.
+func (set *Recurrence) span() (from, to time.Time) {
+	extend := func(t time.Time) {
+		if t.IsZero() {
+			return
+		}
+		if from.IsZero() || t.Before(from) {
+			from = t
+		}
+		if to.IsZero() || t.After(to) {
+			to = t
+		}
+	}
+	extend(set.dtstart)
+	for _, ts := range [][]time.Time{set.rdate, set.exdate} {
+		for _, t := range ts {
+			extend(t)
+		}
+	}
+	for _, p := range set.rperiod {
+		extend(p.Start)
+		extend(p.End)
+	}
+	for _, r := range append(set.rules(), set.exrules...) {
+		if !r.until.IsZero() && !r.until.Equal(r.dtstart.Add(time.Duration(1<<63-1))) {
+			extend(r.until)
+			continue
+		}
+		if r.count == 0 {
+			extend(transitionHorizon)
+			continue
+		}
+		next := r.ruleIterator(set.limits)
+		for t, ok := next(); ok; t, ok = next() {
+			extend(t)
+		}
+	}
+	return from, to
+}
@@ -0,0 +1,196 @@
+package rrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unnamedEastern is America/New_York under the name Go gives time.Local.
+func unnamedEastern(t *testing.T) *time.Location {
+	t.Helper()
+	lines, err := ParseContentLines("BEGIN:VTIMEZONE\n" +
+		"TZID:Local\n" +
+		"BEGIN:STANDARD\n" +
+		"DTSTART:16010101T020000\n" +
+		"TZOFFSETFROM:-0400\n" +
+		"TZOFFSETTO:-0500\n" +
+		"TZNAME:EST\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\n" +
+		"END:STANDARD\n" +
+		"BEGIN:DAYLIGHT\n" +
+		"DTSTART:16010101T020000\n" +
+		"TZOFFSETFROM:-0500\n" +
+		"TZOFFSETTO:-0400\n" +
+		"TZNAME:EDT\n" +
+		"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\n" +
+		"END:DAYLIGHT\n" +
+		"END:VTIMEZONE")
+	require.NoError(t, err)
+	loc, err := LoadVTimezone(lines...)
+	require.NoError(t, err)
+	return loc
+}
+
+// assertSameTimes checks that got holds the instants and wall clocks of want.
+func assertSameTimes(t *testing.T, want, got []time.Time) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Equal(got[i]), "%v != %v", want[i], got[i])
+		assert.Equal(t, want[i].Format(LocalDateTimeFormat), got[i].Format(LocalDateTimeFormat))
+	}
+}
+
+func TestSyntheticFixedOffset(t *testing.T) {
+	loc := time.FixedZone("", 8*3600)
+	s, err := New(ROption{Freq: DAILY, Count: 3, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, loc)})
+	require.NoError(t, err)
+	s.ExDate(time.Date(2024, 1, 2, 9, 0, 0, 0, loc))
+	s.RDatePeriod(Period{Start: time.Date(2024, 1, 5, 9, 0, 0, 0, loc), End: time.Date(2024, 1, 5, 10, 0, 0, 0, loc)})
+
+	lines := s.Strings()
+	assert.Equal(t, []string{
+		"DTSTART;TZID=UTC+0800:20240101T090000",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"RDATE;VALUE=PERIOD;TZID=UTC+0800:20240105T090000/20240105T100000",
+		"EXDATE;TZID=UTC+0800:20240102T090000",
+		"BEGIN:VTIMEZONE",
+		"TZID:UTC+0800",
+		"BEGIN:STANDARD",
+		"DTSTART:16010101T000000",
+		"TZOFFSETFROM:+0800",
+		"TZOFFSETTO:+0800",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+	}, lines)
+
+	parsed, err := Parse(lines...)
+	require.NoError(t, err)
+	assertSameTimes(t, s.All(), parsed.All())
+	assert.Equal(t, lines, parsed.Strings())
+
+	// The TZID is resolved without its VTIMEZONE too.
+	parsed, err = Parse(lines[:4]...)
+	require.NoError(t, err)
+	assertSameTimes(t, s.All(), parsed.All())
+}
+
+func TestSyntheticZeroOffset(t *testing.T) {
+	s, err := New(ROption{Freq: DAILY, Count: 1, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("", 0))})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY;COUNT=1"}, s.Strings())
+}
+
+func TestSyntheticTransitions(t *testing.T) {
+	loc := unnamedEastern(t)
+	s, err := New(ROption{Freq: WEEKLY, Count: 60, Dtstart: time.Date(2024, 10, 21, 9, 0, 0, 0, loc)})
+	require.NoError(t, err)
+	s.RDate(time.Date(2025, 3, 10, 9, 0, 0, 0, loc))
+
+	lines := s.Strings()
+	require.NotEmpty(t, lines)
+	tzid := strings.TrimPrefix(strings.SplitN(lines[0], ":", 3)[0], "DTSTART;TZID=")
+	assert.Regexp(t, `^UTC-0400/[0-9A-F]{8}$`, tzid)
+	assert.Contains(t, lines, "TZID:"+tzid)
+	// The span ends with the last occurrence, in December 2025.
+	assert.Contains(t, lines, "DTSTART:20241103T020000")
+	assert.Contains(t, lines, "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU")
+	assert.Contains(t, lines, "DTSTART:20250309T020000")
+	assert.Equal(t, lines, s.Strings(), "synthetic TZIDs are stable")
+
+	parsed, err := Parse(lines...)
+	require.NoError(t, err)
+	assertSameTimes(t, s.All(), parsed.All())
+	assert.Equal(t, lines, parsed.Strings())
+}
+
+func TestTZIDPolicies(t *testing.T) {
+	loc := time.FixedZone("", -3*3600-30*60)
+	s, err := New(ROption{Freq: DAILY, Count: 2, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, loc)})
+	require.NoError(t, err)
+	s.RDate(time.Date(2024, 1, 5, 9, 0, 0, 0, loc))
+	assert.Equal(t, TZIDSynthetic, s.GetTZIDPolicy())
+
+	s.SetTZIDPolicy(TZIDUTC)
+	lines, err := s.Lines()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DTSTART:20240101T123000Z",
+		"RRULE:FREQ=DAILY;COUNT=2",
+		"RDATE:20240105T123000Z",
+	}, lines)
+	parsed, err := Parse(lines...)
+	require.NoError(t, err)
+	want := s.All()
+	got := parsed.All()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Equal(got[i]))
+	}
+
+	s.SetTZIDPolicy(TZIDError)
+	_, err = s.Lines()
+	assert.ErrorIs(t, err, ErrUnnamedLocation)
+	assert.Nil(t, s.Strings())
+	assert.Equal(t, "", s.String())
+	assert.Equal(t, "", s.DTStartString())
+	assert.Equal(t, "", s.RDateString())
+
+	// Named locations are written under every policy.
+	ny, _ := time.LoadLocation("America/New_York")
+	s, err = New(ROption{Freq: DAILY, Count: 1, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, ny)})
+	require.NoError(t, err)
+	for _, policy := range []TZIDPolicy{TZIDSynthetic, TZIDUTC, TZIDError} {
+		s.SetTZIDPolicy(policy)
+		lines, err := s.Lines()
+		require.NoError(t, err)
+		assert.Equal(t, "DTSTART;TZID=America/New_York:20240101T090000", lines[0])
+	}
+}
+
+func TestUnresolvableName(t *testing.T) {
+	// EST is an IANA name, but with another offset.
+	loc := time.FixedZone("EST", 3*3600)
+	s, err := New(ROption{Freq: DAILY, Count: 1, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, loc)})
+	require.NoError(t, err)
+	assert.Equal(t, "DTSTART;TZID=UTC+0300:20240101T090000", s.Strings()[0])
+
+	loc = time.FixedZone("EST", -5*3600)
+	s, err = New(ROption{Freq: DAILY, Count: 1, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, loc)})
+	require.NoError(t, err)
+	assert.Equal(t, "DTSTART;TZID=EST:20240101T090000", s.Strings()[0])
+}
+
+func TestFixedOffsetLocations(t *testing.T) {
+	for tzid, offset := range map[string]int{
+		"UTC+0800":   8 * 3600,
+		"UTC-0330":   -(3*3600 + 30*60),
+		"UTC+055328": 5*3600 + 53*60 + 28,
+		"UTC+0000":   0,
+	} {
+		loc, err := FixedOffsetLocations.ResolveLocation(tzid)
+		if assert.NoError(t, err, tzid) {
+			name, got := time.Now().In(loc).Zone()
+			assert.Equal(t, tzid, name)
+			assert.Equal(t, offset, got, tzid)
+		}
+	}
+	for _, tzid := range []string{"UTC", "UTC+8", "UTC+2400", "UTC+08:00", "GMT+0800", "UTC+0800/1A2B3C4D"} {
+		_, err := FixedOffsetLocations.ResolveLocation(tzid)
+		assert.Error(t, err, tzid)
+	}
+}
+
+func TestParseVTimezoneErrors(t *testing.T) {
+	_, err := Parse("DTSTART;TZID=X:20240101T090000", "BEGIN:VTIMEZONE", "TZID:X")
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 2, syntaxErr.Line)
+
+	_, err = Parse("DTSTART;TZID=X:20240101T090000", "BEGIN:VTIMEZONE", "TZID:X", "END:VTIMEZONE")
+	assert.ErrorContains(t, err, "no STANDARD or DAYLIGHT component")
+}
@@ -5,7 +5,10 @@ package rrule
 import (
 	"errors"
 	"fmt"
+	"iter"
 	"math"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +32,14 @@ func timeToUTCStr(time time.Time) string {
 	return time.UTC().Format(DateTimeFormat)
 }
 
+// wallClock returns the wall clock of t, to second precision, as a UTC time.
+// Floating times are represented that way.
+func wallClock(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+}
+
 func strToTimeInLoc(str string, loc *time.Location) (time.Time, error) {
 	if len(str) == len(DateFormat) {
 		return time.ParseInLocation(DateFormat, str, loc)
@@ -51,6 +62,38 @@ func appendIntsOption(options []string, key string, value []int) []string {
 	return append(options, fmt.Sprintf("%s=%s", key, strings.Join(valueStr, ",")))
 }
 
+// appendMonthsOption appends a BYMONTH value, with the leap months suffixed
+// by L, e.g. BYMONTH=5,5L.
+func appendMonthsOption(options []string, key string, months, leap []int) []string {
+	if len(months) == 0 && len(leap) == 0 {
+		return options
+	}
+	valueStr := make([]string, 0, len(months)+len(leap))
+	for _, v := range months {
+		valueStr = append(valueStr, strconv.Itoa(v))
+	}
+	for _, v := range leap {
+		valueStr = append(valueStr, strconv.Itoa(v)+"L")
+	}
+	return append(options, fmt.Sprintf("%s=%s", key, strings.Join(valueStr, ",")))
+}
+
+// strToMonths parses a BYMONTH value into its months and its leap months.
+func strToMonths(value string) (months, leap []int, err error) {
+	for _, s := range strings.Split(value, ",") {
+		n, e := strconv.Atoi(strings.TrimSuffix(s, "L"))
+		if e != nil {
+			return nil, nil, e
+		}
+		if strings.HasSuffix(s, "L") {
+			leap = append(leap, n)
+		} else {
+			months = append(months, n)
+		}
+	}
+	return months, leap, nil
+}
+
 func strToInts(value string) ([]int, error) {
 	contents := strings.Split(value, ",")
 	result := make([]int, len(contents))
@@ -75,8 +118,8 @@ func timeToRFCDatetimeStr(time time.Time) string {
 	return fmt.Sprintf(":%s", time.Format(DateTimeFormat))
 }
 
-// StrToDates is intended to parse RDATE and EXDATE properties supporting only
-// VALUE=DATE-TIME (DATE and PERIOD are not supported).
+// StrToDates is intended to parse RDATE and EXDATE properties supporting
+// VALUE=DATE-TIME and VALUE=DATE. RDATE periods are parsed by StrToPeriodsInLoc.
 // Accepts string with format: "VALUE=DATE-TIME;[TZID=...]:{time},{time},...,{time}"
 // or simply "{time},{time},...{time}" and parses it to array of dates
 // In case no time zone specified in str, when all dates are parsed in UTC
@@ -87,27 +130,21 @@ func StrToDates(str string) (ts []time.Time, err error) {
 // StrToDatesInLoc same as StrToDates but it consideres default location to parse dates in
 // in case no location specified with TZID parameter
 func StrToDatesInLoc(str string, defaultLoc *time.Location) (ts []time.Time, err error) {
-	tmp := strings.Split(str, ":")
-	if len(tmp) > 2 {
-		return nil, fmt.Errorf("bad format")
-	}
-	loc := defaultLoc
-	if len(tmp) == 2 {
-		params := strings.Split(tmp[0], ";")
-		for _, param := range params {
-			if strings.HasPrefix(param, "TZID=") {
-				loc, err = parseTZID(param)
-			} else if param != "VALUE=DATE-TIME" && param != "VALUE=DATE" {
-				err = fmt.Errorf("unsupported: %v", param)
-			}
-			if err != nil {
-				return nil, fmt.Errorf("bad dates param: %s", err.Error())
-			}
-		}
-		tmp = tmp[1:]
+	l, err := lexPropertyTail("RDATE", str)
+	if err != nil {
+		return nil, err
 	}
-	for _, datestr := range strings.Split(tmp[0], ",") {
-		t, err := strToTimeInLoc(datestr, loc)
+	return parseDates(l, defaultLoc, nil)
+}
+
+// parseDates parses the values of an RDATE or EXDATE line.
+func parseDates(l ContentLine, defaultLoc *time.Location, resolver LocationResolver) (ts []time.Time, err error) {
+	_, loc, err := timeParams(l, defaultLoc, resolver, "DATE-TIME", "DATE")
+	if err != nil {
+		return nil, fmt.Errorf("bad dates param: %s", err.Error())
+	}
+	for _, datestr := range strings.Split(l.Value, ",") {
+		t, err := strToTimeInLoc(strings.ToUpper(datestr), loc)
 		if err != nil {
 			return nil, fmt.Errorf("strToTime failed: %v", err)
 		}
@@ -116,58 +153,130 @@ func StrToDatesInLoc(str string, defaultLoc *time.Location) (ts []time.Time, err
 	return
 }
 
-// processRRuleName processes the name of an RRule off a multi-line RRule set
-func processRRuleName(line string) (string, error) {
-	line = strings.ToUpper(strings.TrimSpace(line))
-	if line == "" {
-		return "", fmt.Errorf("bad format %v", line)
+// timeParams returns the VALUE of a date property, which must be one of
+// values, and the location of its TZID found by resolver, or defaultLoc.
+// Other parameters are rejected.
+func timeParams(l ContentLine, defaultLoc *time.Location, resolver LocationResolver, values ...string) (value string, loc *time.Location, err error) {
+	loc = defaultLoc
+	for _, p := range l.Params {
+		if len(p.Values) != 1 {
+			return "", nil, fmt.Errorf("%s takes a single value", p.Name)
+		}
+		switch p.Name {
+		case "VALUE":
+			value = strings.ToUpper(p.Values[0])
+			if !slices.Contains(values, value) {
+				return "", nil, fmt.Errorf("unsupported: VALUE=%s", value)
+			}
+		case "TZID":
+			if loc, err = parseTZID(p.Values[0], resolver); err != nil {
+				return "", nil, err
+			}
+		default:
+			return "", nil, fmt.Errorf("unsupported: %s", p.Name)
+		}
 	}
+	return value, loc, nil
+}
 
-	nameLen := strings.IndexAny(line, ";:")
-	if nameLen <= 0 {
-		return "", fmt.Errorf("bad format %v", line)
+// lexPropertyTail parses a property given without its name, such as
+// "TZID=America/New_York:19970714T133000" or "19970714T133000".
+func lexPropertyTail(name, str string) (ContentLine, error) {
+	if strings.Contains(str, ":") {
+		return ParseContentLine(name + ";" + str)
 	}
+	return ParseContentLine(name + ":" + str)
+}
 
-	name := line[:nameLen]
-	if strings.IndexAny(name, "=") > 0 {
-		return "", fmt.Errorf("bad format %v", line)
+// StrToDuration parses an RFC 5545 DURATION value such as "P1DT2H" or "-PT15M".
+// Weeks are converted to days.
+func StrToDuration(str string) (Duration, error) {
+	s := str
+	sign := 1
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
 	}
-
-	return name, nil
+	if !strings.HasPrefix(s, "P") || len(s) == 1 {
+		return Duration{}, fmt.Errorf("bad duration %q", str)
+	}
+	s = s[1:]
+	var d Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return Duration{}, fmt.Errorf("bad duration %q", str)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return Duration{}, fmt.Errorf("bad duration %q", str)
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return Duration{}, fmt.Errorf("bad duration %q", str)
+		}
+		switch unit := s[i]; {
+		case unit == 'W' && !inTime:
+			d.Days += 7 * n
+		case unit == 'D' && !inTime:
+			d.Days += n
+		case unit == 'H' && inTime:
+			d.Clock += time.Duration(n) * time.Hour
+		case unit == 'M' && inTime:
+			d.Clock += time.Duration(n) * time.Minute
+		case unit == 'S' && inTime:
+			d.Clock += time.Duration(n) * time.Second
+		default:
+			return Duration{}, fmt.Errorf("bad duration %q", str)
+		}
+		s = s[i+1:]
+	}
+	return Duration{Days: sign * d.Days, Clock: time.Duration(sign) * d.Clock}, nil
 }
 
 // StrToDtStart accepts string with format: "(TZID={timezone}:)?{time}" or "VALUE=DATE:{date}" and parses it to a date
 // may be used to parse DTSTART rules, without the DTSTART; part.
 func StrToDtStart(str string, defaultLoc *time.Location) (time.Time, error) {
-	// Handle VALUE=DATE parameter for all-day events
-	if strings.HasPrefix(str, "VALUE=DATE:") {
-		dateStr := str[len("VALUE=DATE:"):]
-		// Parse DATE format (YYYYMMDD) for all-day events
-		return strToTimeInLoc(dateStr, time.UTC) // All-day events use floating time (UTC)
+	l, err := lexPropertyTail("DTSTART", str)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return parseDtStart(l, defaultLoc, nil)
+}
 
-	tmp := strings.Split(str, ":")
-	if len(tmp) > 2 || len(tmp) == 0 {
-		return time.Time{}, fmt.Errorf("bad format")
+// parseDtStart parses the value of a DTSTART or DTEND line.
+func parseDtStart(l ContentLine, defaultLoc *time.Location, resolver LocationResolver) (time.Time, error) {
+	value, loc, err := timeParams(l, defaultLoc, resolver, "DATE-TIME", "DATE")
+	if err != nil {
+		return time.Time{}, err
 	}
-
-	if len(tmp) == 2 {
-		// tzid
-		loc, err := parseTZID(tmp[0])
-		if err != nil {
-			return time.Time{}, err
-		}
-		return strToTimeInLoc(tmp[1], loc)
+	if value == "DATE" {
+		// All-day events use floating time (UTC)
+		loc = time.UTC
 	}
-	// no tzid, len == 1
-	return strToTimeInLoc(tmp[0], defaultLoc)
+	return strToTimeInLoc(strings.ToUpper(l.Value), loc)
 }
 
-func parseTZID(s string) (*time.Location, error) {
-	if !strings.HasPrefix(s, "TZID=") || len(s) == len("TZID=") {
+// parseTZID returns the location of a TZID parameter, found by resolver or
+// by DefaultLocationResolver when resolver is nil.
+func parseTZID(tzid string, resolver LocationResolver) (*time.Location, error) {
+	if tzid == "" {
 		return nil, fmt.Errorf("bad TZID parameter format")
 	}
-	return time.LoadLocation(s[len("TZID="):])
+	if resolver == nil {
+		resolver = DefaultLocationResolver
+	}
+	return resolver.ResolveLocation(tzid)
 }
 
 // Python: MO-SU: 0 - 6
@@ -256,6 +365,17 @@ func pySubscript(slice []int, index int) (int, error) {
 	return slice[index], nil
 }
 
+// zeroedInts returns a slice of n zeros, reusing the backing array of buf
+// when it is large enough.
+func zeroedInts(buf []int, n int) []int {
+	if cap(buf) < n {
+		return make([]int, n)
+	}
+	buf = buf[:n]
+	clear(buf)
+	return buf
+}
+
 func timeSliceIterator(s []time.Time) func() (time.Time, bool) {
 	index := 0
 	return func() (time.Time, bool) {
@@ -268,6 +388,66 @@ func timeSliceIterator(s []time.Time) func() (time.Time, bool) {
 	}
 }
 
+// searchTimes returns the index of the first time in the sorted slice s
+// that is not before dt.
+func searchTimes(s []time.Time, dt time.Time) int {
+	return sort.Search(len(s), func(i int) bool { return !s[i].Before(dt) })
+}
+
+// sortedTimes returns a sorted copy of s.
+func sortedTimes(s []time.Time) []time.Time {
+	if len(s) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(s)
+	sortTimes(sorted)
+	return sorted
+}
+
+// insertSorted inserts t into the sorted slice s, keeping it sorted.
+func insertSorted(s []time.Time, t time.Time) []time.Time {
+	i := sort.Search(len(s), func(i int) bool { return s[i].After(t) })
+	return slices.Insert(s, i, t)
+}
+
+// sortTimes sorts s in ascending order without allocating.
+func sortTimes(s []time.Time) {
+	slices.SortFunc(s, func(a, b time.Time) int { return a.Compare(b) })
+}
+
+// timeSearch reports whether the sorted slice s contains dt.
+func timeSearch(s []time.Time, dt time.Time) bool {
+	i := searchTimes(s, dt)
+	return i < len(s) && s[i].Equal(dt)
+}
+
+// searchTimesAfter returns the index of the first time in the sorted slice s
+// that is after dt.
+func searchTimesAfter(s []time.Time, dt time.Time) int {
+	return sort.Search(len(s), func(i int) bool { return s[i].After(dt) })
+}
+
+// civilDays returns the number of days between 1970-01-01 and the given date.
+func civilDays(year int, month time.Month, day int) int {
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// civilDate is the inverse of civilDays.
+func civilDate(days int) (year int, month time.Month, day int) {
+	return time.Unix(int64(days)*86400, 0).UTC().Date()
+}
+
+func reverseTimeSliceIterator(s []time.Time) func() (time.Time, bool) {
+	index := len(s)
+	return func() (time.Time, bool) {
+		if index == 0 {
+			return time.Time{}, false
+		}
+		index--
+		return s[index], true
+	}
+}
+
 func easter(year int) time.Time {
 	g := year % 19
 	c := year / 100
@@ -280,51 +460,81 @@ func easter(year int) time.Time {
 	return time.Date(year, time.Month(m), d, 0, 0, 0, 0, time.UTC)
 }
 
-func all(next Next) []time.Time {
-	result := []time.Time{}
-	for {
-		v, ok := next()
-		if !ok {
-			return result
+// toSeq adapts a generator factory to a sequence. A fresh generator is
+// created for every range, so the sequence can be iterated repeatedly and an
+// early break leaves no generator state behind.
+func toSeq(newNext func() Next) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		next := newNext()
+		for {
+			v, ok := next()
+			if !ok || !yield(v) {
+				return
+			}
 		}
-		result = append(result, v)
 	}
 }
 
-func between(next Next, after, before time.Time, inc bool) []time.Time {
-	result := []time.Time{}
-	for {
-		v, ok := next()
-		if !ok || inc && v.After(before) || !inc && !v.Before(before) {
-			return result
-		}
-		if inc && !v.Before(after) || !inc && v.After(after) {
-			result = append(result, v)
+func betweenSeq(seq iter.Seq[time.Time], after, before time.Time, inc bool) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		for v := range seq {
+			if inc && v.After(before) || !inc && !v.Before(before) {
+				return
+			}
+			if inc && !v.Before(after) || !inc && v.After(after) {
+				if !yield(v) {
+					return
+				}
+			}
 		}
 	}
 }
 
-func before(next Next, dt time.Time, inc bool) time.Time {
-	result := time.Time{}
-	for {
-		v, ok := next()
-		if !ok || inc && v.After(dt) || !inc && !v.Before(dt) {
-			return result
+func fromSeq(seq iter.Seq[time.Time], dt time.Time, inc bool) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		for v := range seq {
+			if inc && v.Before(dt) || !inc && !v.After(dt) {
+				continue
+			}
+			if !yield(v) {
+				return
+			}
 		}
-		result = v
 	}
 }
 
-func after(next Next, dt time.Time, inc bool) time.Time {
-	for {
-		v, ok := next()
-		if !ok {
-			return time.Time{}
-		}
-		if inc && !v.Before(dt) || !inc && v.After(dt) {
+func all(seq iter.Seq[time.Time]) []time.Time {
+	result := []time.Time{}
+	for v := range seq {
+		result = append(result, v)
+	}
+	return result
+}
+
+func between(seq iter.Seq[time.Time], after, before time.Time, inc bool) []time.Time {
+	result := []time.Time{}
+	for v := range betweenSeq(seq, after, before, inc) {
+		result = append(result, v)
+	}
+	return result
+}
+
+// before returns the first value of the descending sequence seq that is
+// before dt, or at dt when inc is set.
+func before(seq iter.Seq[time.Time], dt time.Time, inc bool) time.Time {
+	for v := range seq {
+		if inc && !v.After(dt) || !inc && v.Before(dt) {
 			return v
 		}
 	}
+	return time.Time{}
+}
+
+func after(seq iter.Seq[time.Time], dt time.Time, inc bool) time.Time {
+	for v := range fromSeq(seq, dt, inc) {
+		return v
+	}
+	return time.Time{}
 }
 
 type optInt struct {
@@ -333,7 +543,7 @@ type optInt struct {
 }
 
 func prepareTimeSet(set *[]time.Time, length int) {
-	if len(*set) < length {
+	if cap(*set) < length {
 		*set = make([]time.Time, 0, length)
 		return
 	}
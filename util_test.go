@@ -104,7 +104,7 @@ func TestStrToDatesTimeIsCorrect(t *testing.T) {
 	}
 }
 
-func TestProcessRRuleName(t *testing.T) {
+func TestParseContentLineName(t *testing.T) {
 	validCases := []string{
 		"DTSTART;TZID=America/New_York:19970714T133000",
 		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU",
@@ -121,14 +121,14 @@ func TestProcessRRuleName(t *testing.T) {
 	}
 
 	for _, item := range validCases {
-		if _, e := processRRuleName(item); e != nil {
-			t.Errorf("processRRuleName(%q) error = %s, want nil", item, e.Error())
+		if _, e := ParseContentLine(item); e != nil {
+			t.Errorf("ParseContentLine(%q) error = %s, want nil", item, e.Error())
 		}
 	}
 
 	for _, item := range invalidCases {
-		if _, e := processRRuleName(item); e == nil {
-			t.Errorf("processRRuleName(%q) err = nil, want not nil", item)
+		if _, e := ParseContentLine(item); e == nil {
+			t.Errorf("ParseContentLine(%q) err = nil, want not nil", item)
 		}
 	}
 }
@@ -150,3 +150,29 @@ func TestStrToDates_UnsupportedValueParam(t *testing.T) {
 		t.Error("expected error for unsupported VALUE=PERIOD")
 	}
 }
+
+func TestStrToDuration(t *testing.T) {
+	cases := []struct {
+		str   string
+		days  int
+		clock time.Duration
+	}{
+		{"PT2H", 0, 2 * time.Hour},
+		{"P1DT2H30M", 1, 2*time.Hour + 30*time.Minute},
+		{"P2W", 14, 0},
+		{"-PT15M", 0, -15 * time.Minute},
+		{"+P1D", 1, 0},
+		{"PT90S", 0, 90 * time.Second},
+	}
+	for _, c := range cases {
+		d, err := StrToDuration(c.str)
+		if err != nil || d.Days != c.days || d.Clock != c.clock {
+			t.Errorf("StrToDuration(%q) = %+v, %v, want %d, %v", c.str, d, err, c.days, c.clock)
+		}
+	}
+	for _, str := range []string{"", "P", "PT", "P1H", "PT1D", "P1", "1D", "P1DT", "PTT1H"} {
+		if _, err := StrToDuration(str); err == nil {
+			t.Errorf("StrToDuration(%q) err = nil, want not nil", str)
+		}
+	}
+}
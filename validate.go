@@ -0,0 +1,260 @@
+package rrule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Mode selects the checks Validate makes.
+type Mode int
+
+const (
+	// Lenient checks the ranges of the rule parts, as New does.
+	Lenient Mode = iota
+	// Strict also checks the combinations of rule parts RFC 5545 and RFC 7529
+	// forbid, which other servers reject even though this package expands
+	// them.
+	Strict
+)
+
+// ViolationCode identifies the kind of a Violation.
+type ViolationCode string
+
+const (
+	// ViolationOutOfRange is a value outside the range of its rule part.
+	ViolationOutOfRange ViolationCode = "out-of-range"
+	// ViolationCountWithUntil is a rule with both COUNT and UNTIL.
+	ViolationCountWithUntil ViolationCode = "count-with-until"
+	// ViolationUntilBeforeDTStart is an UNTIL before DTSTART.
+	ViolationUntilBeforeDTStart ViolationCode = "until-before-dtstart"
+	// ViolationByWeekNoNotYearly is BYWEEKNO in a rule other than YEARLY.
+	ViolationByWeekNoNotYearly ViolationCode = "byweekno-not-yearly"
+	// ViolationByYearDayFreq is BYYEARDAY in a DAILY, WEEKLY or MONTHLY rule.
+	ViolationByYearDayFreq ViolationCode = "byyearday-freq"
+	// ViolationByMonthDayWeekly is BYMONTHDAY in a WEEKLY rule.
+	ViolationByMonthDayWeekly ViolationCode = "bymonthday-weekly"
+	// ViolationNumericByDay is a BYDAY with an ordinal, such as 1MO, in a
+	// rule other than MONTHLY or YEARLY, or in a YEARLY rule with BYWEEKNO.
+	ViolationNumericByDay ViolationCode = "numeric-byday"
+	// ViolationBySetPosAlone is BYSETPOS without any other BY rule part.
+	ViolationBySetPosAlone ViolationCode = "bysetpos-alone"
+	// ViolationSkipWithoutRscale is SKIP without RSCALE, which RFC 7529
+	// requires it to follow.
+	ViolationSkipWithoutRscale ViolationCode = "skip-without-rscale"
+)
+
+// Violation is a rule part that breaks RFC 5545.
+type Violation struct {
+	Part    string        // rule part, such as "BYWEEKNO"
+	Code    ViolationCode // kind of violation
+	Message string        // description for humans
+	Line    int           // line of the rule for ParseStrict, 0 for Validate
+}
+
+func (v Violation) String() string {
+	if v.Line > 0 {
+		return fmt.Sprintf("line %d: %s", v.Line, v.Message)
+	}
+	return v.Message
+}
+
+// ValidationError lists the violations of the rules given to ParseStrict.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.String()
+	}
+	return "rrule: invalid rule: " + strings.Join(messages, "; ")
+}
+
+// Validate returns every violation of option under mode, in the order of
+// the rule parts, or nil when the rule is valid. Unlike New, it does not stop
+// at the first one.
+func Validate(option ROption, mode Mode) []Violation {
+	violations := boundsViolations(option)
+	if mode == Strict {
+		violations = append(violations, strictViolations(option)...)
+	}
+	return violations
+}
+
+// ParseStrict is like Parse but checks the RRULE and EXRULE lines with
+// Validate in Strict mode. When a rule has violations, it returns a
+// *ValidationError listing those of all rules, located at their lines.
+func ParseStrict(lines ...string) (*Recurrence, error) {
+	contentLines, err := readContentLines(lines)
+	if err != nil {
+		return nil, err
+	}
+	contentLines, _, err = splitVTimezones(contentLines)
+	if err != nil {
+		return nil, err
+	}
+	// The options are read from the lines, as the recurrence keeps neither
+	// UNTIL with COUNT nor the ordinals of BYDAY it ignores.
+	type rule struct {
+		option *ROption
+		line   int
+	}
+	var rules []rule
+	for _, l := range contentLines {
+		if l.Name != "RRULE" && l.Name != "EXRULE" {
+			continue
+		}
+		// Lines Parse rejects are reported by Parse.
+		if option, err := parseROption(l.Value, nil, nil); err == nil {
+			rules = append(rules, rule{option, l.Line})
+		}
+	}
+
+	rec, parseErr := Parse(lines...)
+	var violations []Violation
+	for _, r := range rules {
+		if parseErr == nil {
+			r.option.Dtstart = rec.GetDTStart()
+			r.option.AllDay = rec.IsAllDay()
+			r.option.Floating = rec.IsFloating()
+		}
+		for _, v := range Validate(*r.option, Strict) {
+			v.Line = r.line
+			violations = append(violations, v)
+		}
+	}
+	if len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return rec, nil
+}
+
+// boundsViolations returns the values of arg outside the range of their
+// rule part.
+func boundsViolations(arg ROption) []Violation {
+	bounds := []struct {
+		field     []int
+		param     string
+		bound     []int
+		plusMinus bool
+	}{
+		{arg.Bysecond, "bysecond", []int{0, 59}, false},
+		{arg.Byminute, "byminute", []int{0, 59}, false},
+		{arg.Byhour, "byhour", []int{0, 23}, false},
+		{arg.Bymonthday, "bymonthday", []int{1, 31}, true},
+		{arg.Byyearday, "byyearday", []int{1, 366}, true},
+		{arg.Byweekno, "byweekno", []int{1, 53}, true},
+		{arg.Bymonth, "bymonth", []int{1, 12}, false},
+		{arg.BymonthLeap, "bymonth", []int{1, 12}, false},
+		{arg.Bysetpos, "bysetpos", []int{1, 366}, true},
+	}
+
+	var violations []Violation
+	outOfRange := func(part, message string) {
+		violations = append(violations, Violation{Part: part, Code: ViolationOutOfRange, Message: message})
+	}
+
+	for _, b := range bounds {
+		for _, value := range b.field {
+			if !(value >= b.bound[0] && value <= b.bound[1]) && (!b.plusMinus || !(value <= -b.bound[0] && value >= -b.bound[1])) {
+				plusMinusBounds := ""
+				if b.plusMinus {
+					plusMinusBounds = fmt.Sprintf(" or %d and %d", -b.bound[0], -b.bound[1])
+				}
+				outOfRange(strings.ToUpper(b.param), fmt.Sprintf("%s must be between %d and %d%s", b.param, b.bound[0], b.bound[1], plusMinusBounds))
+			}
+		}
+	}
+
+	for _, w := range arg.Byweekday {
+		if w.n > 53 || w.n < -53 {
+			outOfRange("BYDAY", "byday must be between 1 and 53 or -1 and -53")
+		}
+	}
+
+	if arg.Interval < 0 {
+		outOfRange("INTERVAL", "interval must be greater than 0")
+	}
+
+	if arg.Skip < OMIT || arg.Skip > FORWARD {
+		outOfRange("SKIP", "skip must be OMIT, BACKWARD or FORWARD")
+	}
+
+	return violations
+}
+
+// strictViolations returns the combinations of rule parts of arg that RFC
+// 5545 and RFC 7529 forbid.
+func strictViolations(arg ROption) []Violation {
+	var violations []Violation
+	add := func(part string, code ViolationCode, format string, a ...any) {
+		violations = append(violations, Violation{Part: part, Code: code, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if arg.Count != 0 && !arg.Until.IsZero() {
+		add("UNTIL", ViolationCountWithUntil, "until must not be used with count")
+	}
+	if !arg.Until.IsZero() && !arg.Dtstart.IsZero() && untilBeforeDtstart(arg) {
+		add("UNTIL", ViolationUntilBeforeDTStart, "until must not be before dtstart")
+	}
+	if len(arg.Byweekno) > 0 && arg.Freq != YEARLY {
+		add("BYWEEKNO", ViolationByWeekNoNotYearly, "byweekno must only be used with YEARLY, not %v", arg.Freq)
+	}
+	if len(arg.Byyearday) > 0 && (arg.Freq == DAILY || arg.Freq == WEEKLY || arg.Freq == MONTHLY) {
+		add("BYYEARDAY", ViolationByYearDayFreq, "byyearday must not be used with %v", arg.Freq)
+	}
+	if len(arg.Bymonthday) > 0 && arg.Freq == WEEKLY {
+		add("BYMONTHDAY", ViolationByMonthDayWeekly, "bymonthday must not be used with WEEKLY")
+	}
+	for _, w := range arg.Byweekday {
+		if w.n == 0 {
+			continue
+		}
+		if arg.Freq != MONTHLY && arg.Freq != YEARLY {
+			add("BYDAY", ViolationNumericByDay, "byday %v must not have an ordinal with %v", w, arg.Freq)
+		} else if arg.Freq == YEARLY && len(arg.Byweekno) > 0 {
+			add("BYDAY", ViolationNumericByDay, "byday %v must not have an ordinal with byweekno", w)
+		}
+	}
+	if len(arg.Bysetpos) > 0 && !hasByPart(arg) {
+		add("BYSETPOS", ViolationBySetPosAlone, "bysetpos must be used with another BY rule part")
+	}
+	if arg.Skip != OMIT && arg.Rscale == "" {
+		add("SKIP", ViolationSkipWithoutRscale, "skip must be used with rscale")
+	}
+	return violations
+}
+
+// untilBeforeDtstart reports whether the UNTIL of arg is before its DTSTART,
+// comparing dates for all-day rules and wall clocks for floating ones, as
+// the rule is expanded.
+func untilBeforeDtstart(arg ROption) bool {
+	switch {
+	case arg.AllDay:
+		y, m, d := arg.Until.Date()
+		until := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		y, m, d = arg.Dtstart.Date()
+		return until.Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	case arg.Floating:
+		return wallClock(arg.Until).Before(wallClock(arg.Dtstart))
+	}
+	return arg.Until.Before(arg.Dtstart)
+}
+
+// hasByPart reports whether arg has a BY rule part other than BYSETPOS.
+func hasByPart(arg ROption) bool {
+	for _, n := range []int{
+		len(arg.Bymonth) + len(arg.BymonthLeap), len(arg.Bymonthday), len(arg.Byyearday), len(arg.Byweekno),
+		len(arg.Byweekday), len(arg.Byhour), len(arg.Byminute), len(arg.Bysecond), len(arg.Byeaster),
+	} {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// violationCodes returns the parts and codes of violations.
+func violationCodes(violations []Violation) [][2]string {
+	var codes [][2]string
+	for _, v := range violations {
+		codes = append(codes, [2]string{v.Part, string(v.Code)})
+	}
+	return codes
+}
+
+func TestValidateStrict(t *testing.T) {
+	dtstart := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		name   string
+		option ROption
+		want   [][2]string
+	}{
+		{"valid", ROption{Freq: MONTHLY, Dtstart: dtstart, Count: 3, Byweekday: []Weekday{MO.Nth(1)}, Bysetpos: []int{1}}, nil},
+		{"count with until", ROption{Freq: DAILY, Dtstart: dtstart, Count: 3, Until: dtstart.AddDate(0, 1, 0)},
+			[][2]string{{"UNTIL", "count-with-until"}}},
+		{"until before dtstart", ROption{Freq: DAILY, Dtstart: dtstart, Until: dtstart.Add(-time.Hour)},
+			[][2]string{{"UNTIL", "until-before-dtstart"}}},
+		{"byweekno monthly", ROption{Freq: MONTHLY, Dtstart: dtstart, Byweekno: []int{1}},
+			[][2]string{{"BYWEEKNO", "byweekno-not-yearly"}}},
+		{"byyearday weekly", ROption{Freq: WEEKLY, Dtstart: dtstart, Byyearday: []int{100}},
+			[][2]string{{"BYYEARDAY", "byyearday-freq"}}},
+		{"byyearday hourly", ROption{Freq: HOURLY, Dtstart: dtstart, Byyearday: []int{100}}, nil},
+		{"bymonthday weekly", ROption{Freq: WEEKLY, Dtstart: dtstart, Bymonthday: []int{1}},
+			[][2]string{{"BYMONTHDAY", "bymonthday-weekly"}}},
+		{"numeric byday weekly", ROption{Freq: WEEKLY, Dtstart: dtstart, Byweekday: []Weekday{MO.Nth(1), TU}},
+			[][2]string{{"BYDAY", "numeric-byday"}}},
+		{"numeric byday byweekno", ROption{Freq: YEARLY, Dtstart: dtstart, Byweekno: []int{20}, Byweekday: []Weekday{MO.Nth(-1)}},
+			[][2]string{{"BYDAY", "numeric-byday"}}},
+		{"bysetpos alone", ROption{Freq: MONTHLY, Dtstart: dtstart, Bysetpos: []int{-1}},
+			[][2]string{{"BYSETPOS", "bysetpos-alone"}}},
+		{"skip without rscale", ROption{Freq: MONTHLY, Dtstart: dtstart, Skip: BACKWARD},
+			[][2]string{{"SKIP", "skip-without-rscale"}}},
+		{"skip with rscale", ROption{Freq: MONTHLY, Dtstart: dtstart, Skip: BACKWARD, Rscale: "GREGORIAN"}, nil},
+		{"all together", ROption{Freq: WEEKLY, Dtstart: dtstart, Count: 2, Until: dtstart.AddDate(-1, 0, 0), Byweekno: []int{1}, Byhour: []int{24}},
+			[][2]string{{"BYHOUR", "out-of-range"}, {"UNTIL", "count-with-until"}, {"UNTIL", "until-before-dtstart"}, {"BYWEEKNO", "byweekno-not-yearly"}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, violationCodes(Validate(c.option, Strict)))
+		})
+	}
+}
+
+func TestValidateLenient(t *testing.T) {
+	option := ROption{Freq: WEEKLY, Count: 2, Until: time.Now(), Bymonthday: []int{0, 40}, Bysecond: []int{60}, Interval: -1}
+	violations := Validate(option, Lenient)
+	assert.Equal(t, [][2]string{
+		{"BYSECOND", "out-of-range"},
+		{"BYMONTHDAY", "out-of-range"},
+		{"BYMONTHDAY", "out-of-range"},
+		{"INTERVAL", "out-of-range"},
+	}, violationCodes(violations))
+	assert.Equal(t, "bysecond must be between 0 and 59", violations[0].Message)
+
+	// New reports the first of them.
+	_, err := New(option)
+	assert.EqualError(t, err, violations[0].Message)
+}
+
+func TestValidateAllDayUntil(t *testing.T) {
+	// The time of day of an all-day UNTIL is ignored.
+	option := ROption{Freq: DAILY, AllDay: true, Dtstart: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Until: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.Empty(t, Validate(option, Strict))
+	option.Until = option.Until.AddDate(0, 0, -1)
+	assert.Equal(t, [][2]string{{"UNTIL", "until-before-dtstart"}}, violationCodes(Validate(option, Strict)))
+}
+
+func TestParseStrict(t *testing.T) {
+	rec, err := ParseStrict("DTSTART;TZID=America/New_York:20240101T090000", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1;COUNT=3")
+	require.NoError(t, err)
+	assert.Len(t, rec.All(), 3)
+
+	lines := []string{
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=WEEKLY;BYDAY=1MO;COUNT=3;UNTIL=20240301T000000Z",
+		"EXRULE:FREQ=DAILY;UNTIL=20231201T000000Z",
+	}
+	// Parse accepts the rules.
+	_, err = Parse(lines...)
+	require.NoError(t, err)
+
+	_, err = ParseStrict(lines...)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []Violation{
+		{Part: "UNTIL", Code: ViolationCountWithUntil, Message: "until must not be used with count", Line: 2},
+		{Part: "BYDAY", Code: ViolationNumericByDay, Message: "byday +1MO must not have an ordinal with WEEKLY", Line: 2},
+		{Part: "UNTIL", Code: ViolationUntilBeforeDTStart, Message: "until must not be before dtstart", Line: 3},
+	}, validationErr.Violations)
+	assert.EqualError(t, err, "rrule: invalid rule: line 2: until must not be used with count; "+
+		"line 2: byday +1MO must not have an ordinal with WEEKLY; line 3: until must not be before dtstart")
+
+	// Every range violation is reported, not only the first.
+	_, err = ParseStrict("DTSTART:20240101T090000Z", "RRULE:FREQ=DAILY;BYHOUR=25;BYMINUTE=60")
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, [][2]string{{"BYMINUTE", "out-of-range"}, {"BYHOUR", "out-of-range"}}, violationCodes(validationErr.Violations))
+
+	// Other errors are those of Parse.
+	_, err = ParseStrict("DTSTART:20240101T090000Z", "RRULE:FREQ=SOMETIMES")
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+}
@@ -0,0 +1,613 @@
+package rrule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transitionHorizon bounds the expansion of the transition rules that never
+// end. Past it, a location follows its last yearly rules when they can be
+// written as a POSIX TZ string, and its last offset otherwise.
+var transitionHorizon = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// zoneType is an offset from UTC in effect in a location.
+type zoneType struct {
+	offset int // seconds east of UTC
+	isDST  bool
+	name   string
+}
+
+// observance is a STANDARD or DAYLIGHT sub-component of a VTIMEZONE.
+type observance struct {
+	zoneType
+	from   int       // offset in effect before each onset
+	start  time.Time // DTSTART, in the offset from
+	rule   *ROption  // nil without RRULE
+	rdates []time.Time
+}
+
+// locationMap resolves the TZIDs of VTIMEZONE components read along with a
+// recurrence.
+type locationMap map[string]*time.Location
+
+func (m locationMap) ResolveLocation(tzid string) (*time.Location, error) {
+	if loc, ok := m[tzid]; ok {
+		return loc, nil
+	}
+	return nil, fmt.Errorf("no VTIMEZONE for TZID %q", tzid)
+}
+
+// LoadVTimezone returns the location defined by the content lines of a
+// VTIMEZONE component, from its BEGIN line to its END line, named after its
+// TZID. The onsets of its STANDARD and DAYLIGHT sub-components, given by their
+// DTSTART, RRULE and RDATE, are expanded and become the transitions of the
+// location.
+func LoadVTimezone(lines ...ContentLine) (*time.Location, error) {
+	if len(lines) == 0 || lines[0].Name != "BEGIN" {
+		return nil, errors.New("want a VTIMEZONE")
+	}
+	if name := strings.ToUpper(lines[0].Value); name != "VTIMEZONE" {
+		return nil, fmt.Errorf("want a VTIMEZONE, got a %s", name)
+	}
+	if last := lines[len(lines)-1]; len(lines) < 2 || last.Name != "END" || !strings.EqualFold(last.Value, "VTIMEZONE") {
+		return nil, errors.New("VTIMEZONE without END")
+	}
+
+	var tzid *ContentLine
+	var observances []observance
+	depth := 0
+	var sub []ContentLine
+	for _, l := range lines[1 : len(lines)-1] {
+		switch {
+		case l.Name == "BEGIN":
+			depth++
+		case l.Name == "END":
+			depth--
+		}
+		switch {
+		case depth == 0 && l.Name == "TZID":
+			tzid = &l
+		case depth == 1 && l.Name == "BEGIN":
+			sub = []ContentLine{l}
+		case depth == 1 && len(sub) > 0:
+			sub = append(sub, l)
+		case depth == 0 && l.Name == "END" && len(sub) > 0:
+			kind := strings.ToUpper(sub[0].Value)
+			if kind == "STANDARD" || kind == "DAYLIGHT" {
+				o, err := parseObservance(kind, sub[1:])
+				if err != nil {
+					return nil, fmt.Errorf("%s at line %d: %w", kind, sub[0].Line, err)
+				}
+				observances = append(observances, o)
+			}
+			sub = nil
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("line %d: END:%s without BEGIN", l.Line, l.Value)
+		}
+	}
+	if tzid == nil || tzid.Value == "" {
+		return nil, errors.New("missing TZID")
+	}
+	if len(observances) == 0 {
+		return nil, errors.New("no STANDARD or DAYLIGHT component")
+	}
+
+	type transition struct {
+		at   int64
+		zone int
+	}
+	var zones []zoneType
+	zoneIndex := func(z zoneType) int {
+		for i := range zones {
+			if zones[i] == z {
+				return i
+			}
+		}
+		zones = append(zones, z)
+		return len(zones) - 1
+	}
+	var transitions []transition
+	var first observance
+	firstAt := int64(0)
+	for _, o := range observances {
+		onsets, err := o.onsets()
+		if err != nil {
+			return nil, err
+		}
+		zone := zoneIndex(o.zoneType)
+		for _, t := range onsets {
+			transitions = append(transitions, transition{at: t.Unix(), zone: zone})
+			if len(transitions) == 1 || t.Unix() < firstAt {
+				first, firstAt = o, t.Unix()
+			}
+		}
+	}
+	sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].at < transitions[j].at })
+
+	// Times before the first onset are in the offset it starts from. The
+	// first zone type, left out of the transitions, stands for them.
+	initial := zoneType{offset: first.from, name: offsetName(first.from)}
+	for _, o := range observances {
+		if o.offset == first.from {
+			initial = o.zoneType
+			break
+		}
+	}
+	for i := range transitions {
+		transitions[i].zone++
+	}
+	zones = append([]zoneType{initial}, zones...)
+	if len(zones) > 255 {
+		return nil, errors.New("too many offsets")
+	}
+
+	var data bytes.Buffer
+	names := map[string]int{}
+	var chars []byte
+	for _, z := range zones {
+		if _, ok := names[z.name]; !ok {
+			names[z.name] = len(chars)
+			chars = append(append(chars, z.name...), 0)
+		}
+	}
+	header := func(transitions, zones, chars int) {
+		data.WriteString("TZif2")
+		data.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, transitions, zones, chars} {
+			binary.Write(&data, binary.BigEndian, uint32(n))
+		}
+	}
+	// A version 1 block describing the initial zone only, for old readers.
+	header(0, 1, len(initial.name)+1)
+	binary.Write(&data, binary.BigEndian, int32(initial.offset))
+	data.Write([]byte{boolByte(initial.isDST), 0})
+	data.WriteString(initial.name + "\x00")
+	// The version 2 block, with 64-bit transition times.
+	header(len(transitions), len(zones), len(chars))
+	for _, t := range transitions {
+		binary.Write(&data, binary.BigEndian, t.at)
+	}
+	for _, t := range transitions {
+		data.WriteByte(byte(t.zone))
+	}
+	for _, z := range zones {
+		binary.Write(&data, binary.BigEndian, int32(z.offset))
+		data.Write([]byte{boolByte(z.isDST), byte(names[z.name])})
+	}
+	data.Write(chars)
+	data.WriteString("\n" + posixRule(observances) + "\n")
+	return time.LoadLocationFromTZData(tzid.Text(), data.Bytes())
+}
+
+// parseObservance reads the properties of a STANDARD or DAYLIGHT component.
+func parseObservance(kind string, lines []ContentLine) (observance, error) {
+	property := func(name string) (ContentLine, bool) {
+		for _, l := range lines {
+			if l.Name == name {
+				return l, true
+			}
+		}
+		return ContentLine{}, false
+	}
+
+	o := observance{zoneType: zoneType{isDST: kind == "DAYLIGHT"}}
+	for _, name := range []string{"TZOFFSETFROM", "TZOFFSETTO"} {
+		l, ok := property(name)
+		if !ok {
+			return o, fmt.Errorf("missing %s", name)
+		}
+		offset, err := parseUTCOffset(l.Value)
+		if err != nil {
+			return o, fmt.Errorf("%s: %w", name, err)
+		}
+		if name == "TZOFFSETFROM" {
+			o.from = offset
+		} else {
+			o.offset = offset
+		}
+	}
+	o.name = offsetName(o.offset)
+	if l, ok := property("TZNAME"); ok && l.Text() != "" {
+		o.name = l.Text()
+	}
+
+	// Onsets are local times in the offset in effect before them.
+	loc := time.FixedZone(o.name, o.from)
+	dtstart, ok := property("DTSTART")
+	if !ok {
+		return o, errors.New("missing DTSTART")
+	}
+	dtstart.Params = nil
+	var err error
+	if o.start, err = parseDtStart(dtstart, loc, nil); err != nil {
+		return o, fmt.Errorf("DTSTART: %w", err)
+	}
+	if l, ok := property("RRULE"); ok {
+		rule, err := parseROption(l.Value, nil, nil)
+		if err != nil {
+			return o, fmt.Errorf("RRULE: %w", err)
+		}
+		rule.Dtstart = o.start
+		o.rule = rule
+	}
+	for _, l := range lines {
+		if l.Name != "RDATE" {
+			continue
+		}
+		l.Params = nil
+		ts, err := parseDates(l, loc, nil)
+		if err != nil {
+			return o, fmt.Errorf("RDATE: %w", err)
+		}
+		o.rdates = append(o.rdates, ts...)
+	}
+	return o, nil
+}
+
+// onsets returns the onsets of o until transitionHorizon. Without RRULE,
+// DTSTART is the first onset.
+func (o observance) onsets() ([]time.Time, error) {
+	if o.rule == nil {
+		onsets := []time.Time{o.start}
+		for _, t := range o.rdates {
+			if !t.Equal(o.start) {
+				onsets = append(onsets, t)
+			}
+		}
+		return onsets, nil
+	}
+	onsets := o.rdates
+	rule := *o.rule
+	if rule.Count == 0 && rule.Until.IsZero() {
+		// Without UNTIL, rules end at most 292 years after DTSTART.
+		rule.Until = transitionHorizon
+	}
+	rec, err := New(rule)
+	if err != nil {
+		return nil, fmt.Errorf("RRULE: %w", err)
+	}
+	for t := range rec.Occurrences() {
+		if t.After(transitionHorizon) {
+			break
+		}
+		onsets = append(onsets, t)
+	}
+	return onsets, nil
+}
+
+// yearly returns the POSIX TZ date of a rule that falls on the nth weekday of
+// a month every year, such as "M3.2.0" for the second Sunday of March.
+func (o observance) yearly() (string, bool) {
+	r := o.rule
+	if r == nil || r.Freq != YEARLY || r.Interval > 1 || r.Count != 0 || !r.Until.IsZero() ||
+		len(r.Bymonth) != 1 || len(r.Byweekday) != 1 || len(r.Bymonthday) != 0 || len(r.Byyearday) != 0 ||
+		len(r.Byweekno) != 0 || len(r.Bysetpos) != 0 || len(r.Byhour) != 0 || len(r.Byminute) != 0 ||
+		len(r.Bysecond) != 0 || r.Rscale != "" || len(o.rdates) != 0 {
+		return "", false
+	}
+	week := r.Byweekday[0].N()
+	switch {
+	case week == -1:
+		week = 5
+	case week < 1 || week > 4:
+		return "", false
+	}
+	h, m, s := o.start.Clock()
+	return fmt.Sprintf("M%d.%d.%d/%d:%02d:%02d", r.Bymonth[0], week, (r.Byweekday[0].Day()+1)%7, h, m, s), true
+}
+
+// posixRule returns the POSIX TZ string of the yearly daylight saving time
+// rules that never end, or "" when there are no such rules.
+func posixRule(observances []observance) string {
+	var std, dst *observance
+	for i := range observances {
+		o := &observances[i]
+		if o.rule == nil || o.rule.Count != 0 || !o.rule.Until.IsZero() {
+			continue
+		}
+		if o.isDST && dst == nil {
+			dst = o
+		} else if !o.isDST && std == nil {
+			std = o
+		} else {
+			return ""
+		}
+	}
+	if std == nil || dst == nil || std.from != dst.offset || dst.from != std.offset {
+		return ""
+	}
+	stdDate, ok1 := std.yearly()
+	dstDate, ok2 := dst.yearly()
+	if !ok1 || !ok2 {
+		return ""
+	}
+	return fmt.Sprintf("%s%s%s%s,%s,%s", posixName(std.zoneType), posixOffset(std.offset),
+		posixName(dst.zoneType), posixOffset(dst.offset), dstDate, stdDate)
+}
+
+var (
+	alphaName  = regexp.MustCompile(`^[A-Za-z]{3,}$`)
+	quotedName = regexp.MustCompile(`^[A-Za-z0-9+-]{3,}$`)
+)
+
+// posixName returns the abbreviation of z as written in a POSIX TZ string.
+func posixName(z zoneType) string {
+	switch {
+	case alphaName.MatchString(z.name):
+		return z.name
+	case quotedName.MatchString(z.name):
+		return "<" + z.name + ">"
+	}
+	return "<" + offsetName(z.offset) + ">"
+}
+
+// posixOffset returns an offset as written in a POSIX TZ string, which counts
+// west of UTC.
+func posixOffset(offset int) string {
+	sign := ""
+	if offset > 0 {
+		sign = "-"
+	} else {
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%d:%02d:%02d", sign, offset/3600, offset/60%60, offset%60)
+}
+
+// offsetName returns a name for an offset without TZNAME, such as "+0530".
+func offsetName(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+// utcOffsetString returns an offset as an RFC 5545 UTC-OFFSET value, such as
+// "-0500" or "+053000".
+func utcOffsetString(offset int) string {
+	s := offsetName(offset)
+	if offset%60 != 0 {
+		if offset < 0 {
+			offset = -offset
+		}
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
+
+// parseUTCOffset parses an RFC 5545 UTC-OFFSET value such as "-0500" or
+// "+053000".
+func parseUTCOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || s[0] != '+' && s[0] != '-' {
+		return 0, fmt.Errorf("bad UTC offset %q", s)
+	}
+	var parts [3]int
+	for i := 0; 1+2*i < len(s); i++ {
+		n, err := strconv.Atoi(s[1+2*i : 3+2*i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("bad UTC offset %q", s)
+		}
+		parts[i] = n
+	}
+	offset := parts[0]*3600 + parts[1]*60 + parts[2]
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// zoneTransition is a change of the zone type in effect in a location.
+type zoneTransition struct {
+	at   time.Time
+	from int // offset in effect before at
+	zone zoneType
+}
+
+// zoneTransitions returns the zone type of loc at from and its transitions
+// until to.
+func zoneTransitions(loc *time.Location, from, to time.Time) (zoneType, []zoneTransition) {
+	t := from.In(loc)
+	name, offset := t.Zone()
+	initial := zoneType{offset: offset, isDST: t.IsDST(), name: name}
+	current := initial
+	var transitions []zoneTransition
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(to) {
+			return initial, transitions
+		}
+		if !end.After(t) {
+			// Past the last transition of its data, a location may end
+			// its zones at the turn of the year.
+			end = t.Add(time.Second)
+		}
+		t = end
+		name, offset := t.Zone()
+		zone := zoneType{offset: offset, isDST: t.IsDST(), name: name}
+		if zone != current {
+			transitions = append(transitions, zoneTransition{at: t, from: current.offset, zone: zone})
+			current = zone
+		}
+	}
+}
+
+// vtimezoneStart is the DTSTART of the observance in effect when a generated
+// VTIMEZONE begins, as written by Outlook.
+var vtimezoneStart = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// VTimezone returns the content lines of a minimal VTIMEZONE component that
+// defines loc as tzid between from and to: an observance in effect at from,
+// then the transitions of loc until to. Transitions falling on the same
+// weekday of the same month in consecutive years are written as a yearly
+// RRULE, which ends with UNTIL unless it runs to the end of the range; the
+// others are written as DTSTART and RDATEs.
+func VTimezone(tzid string, loc *time.Location, from, to time.Time) []string {
+	initial, transitions := zoneTransitions(loc, from, to)
+	type key struct {
+		zone zoneType
+		from int
+	}
+	var keys []key
+	onsets := map[key][]time.Time{}
+	for _, t := range transitions {
+		k := key{t.zone, t.from}
+		if _, ok := onsets[k]; !ok {
+			keys = append(keys, k)
+		}
+		onsets[k] = append(onsets[k], t.at.In(time.FixedZone("", t.from)))
+	}
+
+	type written struct {
+		key
+		start  time.Time // first onset, in the offset from
+		rule   string
+		rdates []string
+	}
+	start := vtimezoneStart.Add(-time.Duration(initial.offset) * time.Second)
+	if from.Before(start) {
+		start = from
+	}
+	observances := []written{{key: key{initial, initial.offset}, start: start.In(time.FixedZone("", initial.offset))}}
+	for _, k := range keys {
+		var single *written
+		for _, run := range yearlyRuns(onsets[k]) {
+			if len(run.onsets) > 1 {
+				rule := fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", run.onsets[0].Month(), run.week, weekdayNames[run.onsets[0].Weekday()])
+				last := run.onsets[len(run.onsets)-1]
+				if !last.AddDate(1, 0, 0).After(to) {
+					rule += ";UNTIL=" + timeToUTCStr(last)
+				}
+				observances = append(observances, written{key: k, start: run.onsets[0], rule: rule})
+				continue
+			}
+			if single == nil {
+				observances = append(observances, written{key: k, start: run.onsets[0]})
+				single = &observances[len(observances)-1]
+				continue
+			}
+			single.rdates = append(single.rdates, run.onsets[0].Format(LocalDateTimeFormat))
+		}
+	}
+	sort.SliceStable(observances[1:], func(i, j int) bool {
+		return observances[1+i].start.Before(observances[1+j].start)
+	})
+
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + textEscaper.Replace(tzid)}
+	for _, o := range observances {
+		kind := "STANDARD"
+		if o.zone.isDST {
+			kind = "DAYLIGHT"
+		}
+		lines = append(lines, "BEGIN:"+kind,
+			"DTSTART:"+o.start.Format(LocalDateTimeFormat),
+			"TZOFFSETFROM:"+utcOffsetString(o.from),
+			"TZOFFSETTO:"+utcOffsetString(o.zone.offset))
+		// LoadVTimezone names the zones without TZNAME after their offset.
+		if o.zone.name != "" && o.zone.name != offsetName(o.zone.offset) {
+			lines = append(lines, "TZNAME:"+textEscaper.Replace(o.zone.name))
+		}
+		if o.rule != "" {
+			lines = append(lines, "RRULE:"+o.rule)
+		}
+		if len(o.rdates) > 0 {
+			lines = append(lines, "RDATE:"+strings.Join(o.rdates, ","))
+		}
+		lines = append(lines, "END:"+kind)
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// yearlyRun is a run of onsets falling on the week-th weekday of the same
+// month, at the same time, in consecutive years. A week of -1 stands for the
+// last weekday of the month.
+type yearlyRun struct {
+	week   int
+	onsets []time.Time
+}
+
+// yearlyRuns splits onsets, in ascending order, into the longest yearly runs.
+func yearlyRuns(onsets []time.Time) []yearlyRun {
+	// weeks returns the weeks of the month t falls in: its nth weekday, and
+	// -1 when it is also the last.
+	weeks := func(t time.Time) (nth int, last bool) {
+		return (t.Day()-1)/7 + 1, t.Day()+7 > daysIn(t.Month(), t.Year())
+	}
+	var runs []yearlyRun
+	for i := 0; i < len(onsets); {
+		first := onsets[i]
+		nth, last := weeks(first)
+		nthOK := nth <= 4
+		j := i + 1
+		for ; j < len(onsets); j++ {
+			t := onsets[j]
+			prev := onsets[j-1]
+			if t.Year() != prev.Year()+1 || t.Month() != first.Month() || t.Weekday() != first.Weekday() ||
+				t.Hour() != first.Hour() || t.Minute() != first.Minute() || t.Second() != first.Second() {
+				break
+			}
+			n, l := weeks(t)
+			if !(nthOK && n == nth) && !(last && l) {
+				break
+			}
+			nthOK = nthOK && n == nth
+			last = last && l
+		}
+		run := yearlyRun{week: -1, onsets: onsets[i:j]}
+		if nthOK {
+			run.week = nth
+		}
+		runs = append(runs, run)
+		i = j
+	}
+	return runs
+}
+
+// splitVTimezones removes the VTIMEZONE components from lines and returns
+// the locations they define, by TZID.
+func splitVTimezones(lines []ContentLine) ([]ContentLine, locationMap, error) {
+	var rest []ContentLine
+	var locations locationMap
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if l.Name != "BEGIN" || !strings.EqualFold(l.Value, "VTIMEZONE") {
+			rest = append(rest, l)
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !(lines[end].Name == "END" && strings.EqualFold(lines[end].Value, "VTIMEZONE")) {
+			end++
+		}
+		if end == len(lines) {
+			return nil, nil, l.errorf("BEGIN:VTIMEZONE without END")
+		}
+		loc, err := LoadVTimezone(lines[i : end+1]...)
+		if err != nil {
+			return nil, nil, l.errorf("VTIMEZONE: %v", err)
+		}
+		if locations == nil {
+			locations = locationMap{}
+		}
+		locations[loc.String()] = loc
+		i = end
+	}
+	return rest, locations, nil
+}
@@ -0,0 +1,163 @@
+package rrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadVTimezones loads the VTIMEZONE components of lines, by TZID.
+func loadVTimezones(t *testing.T, lines []string) map[string]*time.Location {
+	t.Helper()
+	contentLines, err := ParseContentLines(strings.Join(lines, "\r\n"))
+	require.NoError(t, err)
+	rest, locations, err := splitVTimezones(contentLines)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	return locations
+}
+
+// assertSameOffsets checks that got has the offsets of want, hourly from
+// from to to.
+func assertSameOffsets(t *testing.T, want, got *time.Location, from, to time.Time) {
+	t.Helper()
+	for at := from; at.Before(to); at = at.Add(time.Hour) {
+		_, wantOffset := at.In(want).Zone()
+		_, gotOffset := at.In(got).Zone()
+		if !assert.Equal(t, wantOffset, gotOffset, "%v in %s", at, want) {
+			return
+		}
+	}
+}
+
+func TestVTimezoneYearlyRules(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2005, 1, 1, 0, 0, 0, 0, ny)
+	to := time.Date(2009, 1, 1, 0, 0, 0, 0, ny)
+	lines := VTimezone("America/New_York", ny, from, to)
+	assert.Equal(t, []string{
+		"BEGIN:VTIMEZONE",
+		"TZID:America/New_York",
+		"BEGIN:STANDARD",
+		"DTSTART:16010101T000000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0500",
+		"TZNAME:EST",
+		"END:STANDARD",
+		// The rules changed in 2007.
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20050403T020000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0400",
+		"TZNAME:EDT",
+		"RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T070000Z",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20051030T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"TZNAME:EST",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20061029T060000Z",
+		"END:STANDARD",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20070311T020000",
+		"TZOFFSETFROM:-0500",
+		"TZOFFSETTO:-0400",
+		"TZNAME:EDT",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20071104T020000",
+		"TZOFFSETFROM:-0400",
+		"TZOFFSETTO:-0500",
+		"TZNAME:EST",
+		"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+	}, lines)
+
+	loc := loadVTimezones(t, lines)["America/New_York"]
+	require.NotNil(t, loc)
+	assertSameOffsets(t, ny, loc, from, to)
+	// The rules without UNTIL go on past the range.
+	assertSameOffsets(t, ny, loc, time.Date(2150, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2151, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestVTimezoneIrregular(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		from, to time.Time
+	}{
+		// Permanent summer time from 2011, then standard time from 2014.
+		{"Europe/Moscow", time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Australia/Sydney", time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Asia/Tehran", time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Europe/London", time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		want, err := time.LoadLocation(c.name)
+		require.NoError(t, err)
+		lines := VTimezone(c.name, want, c.from, c.to)
+		loc := loadVTimezones(t, lines)[c.name]
+		require.NotNil(t, loc, c.name)
+		assertSameOffsets(t, want, loc, c.from, c.to)
+	}
+}
+
+func TestVTimezoneFixed(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	assert.Equal(t, []string{
+		"BEGIN:VTIMEZONE",
+		"TZID:Asia/Shanghai",
+		"BEGIN:STANDARD",
+		"DTSTART:16010101T000000",
+		"TZOFFSETFROM:+0800",
+		"TZOFFSETTO:+0800",
+		"TZNAME:CST",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+	}, VTimezone("Asia/Shanghai", shanghai, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), transitionHorizon))
+}
+
+func TestRecurrenceVTimezones(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	paris, _ := time.LoadLocation("Europe/Paris")
+	fixed := time.FixedZone("", 5*3600+30*60)
+	s, err := New(ROption{Freq: MONTHLY, Until: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Dtstart: time.Date(2024, 1, 15, 9, 0, 0, 0, ny)})
+	require.NoError(t, err)
+	s.RDate(time.Date(2024, 2, 1, 9, 0, 0, 0, paris))
+	s.ExDate(time.Date(2024, 3, 15, 18, 30, 0, 0, fixed))
+
+	properties, err := s.Properties()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DTSTART;TZID=America/New_York:20240115T090000",
+		"RRULE:FREQ=MONTHLY;UNTIL=20250601T000000Z",
+		"RDATE;TZID=Europe/Paris:20240201T090000",
+		"EXDATE;TZID=UTC+0530:20240315T183000",
+	}, properties)
+	lines, err := s.Lines()
+	require.NoError(t, err)
+	assert.Equal(t, properties, lines[:len(properties)])
+
+	vtimezones, err := s.VTimezones()
+	require.NoError(t, err)
+	locations := loadVTimezones(t, vtimezones)
+	assert.Len(t, locations, 3)
+	from, to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	assertSameOffsets(t, ny, locations["America/New_York"], from, to)
+	assertSameOffsets(t, paris, locations["Europe/Paris"], from, to)
+	assertSameOffsets(t, fixed, locations["UTC+0530"], from, to)
+	// The synthetic TZID is defined as in Lines.
+	assert.Equal(t, lines[len(properties):], vtimezones[len(vtimezones)-len(lines)+len(properties):])
+	// The rules go on to the end of the range, without UNTIL.
+	assert.Contains(t, vtimezones, "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU")
+
+	s.SetTZIDPolicy(TZIDError)
+	_, err = s.VTimezones()
+	assert.ErrorIs(t, err, ErrUnnamedLocation)
+	_, err = s.Properties()
+	assert.ErrorIs(t, err, ErrUnnamedLocation)
+}